@@ -0,0 +1,76 @@
+// Package goredis adapts github.com/go-redis/redis/v8 to redists.Doer.
+//
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	defer client.Close()
+//	tsclient := redists.NewClient(goredis.New(client))
+package goredis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/reply"
+	"github.com/go-redis/redis/v8"
+)
+
+// Doer is a redists.PipelineDoer which sends the commands with a go-redis
+// client. It is safe for concurrent use like the client.
+type Doer struct {
+	c redis.UniversalClient
+}
+
+var _ redists.PipelineDoer = (*Doer)(nil)
+
+// New returns a Doer which uses c. Closing c is up to the caller.
+func New(c redis.UniversalClient) *Doer {
+	return &Doer{c: c}
+}
+
+// serverError reports whether err is an error reply. redis.Nil is handled
+// before, because it is a null reply.
+func serverError(err error) (string, bool) {
+	var e redis.Error
+	if errors.As(err, &e) {
+		return e.Error(), true
+	}
+	return "", false
+}
+
+func result(v interface{}, err error) (interface{}, error) {
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, reply.Err(err, serverError)
+	}
+	return reply.Normalize(v, serverError), nil
+}
+
+func (d *Doer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return result(d.c.Do(ctx, append([]interface{}{cmd}, args...)...).Result())
+}
+
+// DoPipeline sends the commands with a go-redis pipeline.
+func (d *Doer) DoPipeline(ctx context.Context, cmds []redists.PipelineCmd) ([]interface{}, error) {
+	pipe := d.c.Pipeline()
+	gcmds := make([]*redis.Cmd, len(cmds))
+	for i, cmd := range cmds {
+		gcmds[i] = pipe.Do(ctx, append([]interface{}{cmd.Name}, cmd.Args...)...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		// error replies are returned by the commands
+		if _, ok := serverError(err); !ok {
+			return nil, err
+		}
+	}
+	res := make([]interface{}, len(gcmds))
+	for i, cmd := range gcmds {
+		v, err := result(cmd.Result())
+		if err != nil {
+			v = err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
@@ -0,0 +1,27 @@
+package goredis_test
+
+import (
+	"testing"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/goredis"
+	"github.com/coding-socks/redists/adapter/internal/adaptertest"
+	"github.com/coding-socks/redists/redistest"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestDoer(t *testing.T) {
+	s := redistest.NewServer(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+	adaptertest.Run(t, goredis.New(client))
+}
+
+func TestConformance(t *testing.T) {
+	redistest.RunDoerConformance(t, func(t *testing.T) redists.Doer {
+		s := redistest.NewServer(t)
+		client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return goredis.New(client)
+	})
+}
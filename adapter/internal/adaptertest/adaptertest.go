@@ -0,0 +1,104 @@
+// Package adaptertest tests the adapters against a redistest.Server.
+package adaptertest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+)
+
+// Run checks that d returns normalized replies and errors.
+func Run(t *testing.T, d redists.PipelineDoer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key := "adapter:" + t.Name()
+	tsclient := redists.NewClient(d)
+	if err := tsclient.Create(ctx, key, redists.CreateWithLabels(redists.Labels{"adapter": key})); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer d.Do(context.Background(), "DEL", key)
+
+	t.Run("bulk strings", func(t *testing.T) {
+		got, err := d.Do(ctx, "TS.QUERYINDEX", "adapter="+key)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if want := []interface{}{key}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Do() got = %#v, want %#v", got, want)
+		}
+	})
+	t.Run("null", func(t *testing.T) {
+		got, err := d.Do(ctx, "TS.MRANGE", "-", "+", "SELECTED_LABELS", "missing", "FILTER", "adapter="+key)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		// the label value is a null reply
+		resp2 := []interface{}{[]interface{}{key, []interface{}{[]interface{}{"missing", nil}}, []interface{}{}}}
+		resp3 := map[string]interface{}{key: []interface{}{map[string]interface{}{"missing": nil}, []interface{}{}}}
+		if !reflect.DeepEqual(got, resp2) && !reflect.DeepEqual(got, resp3) {
+			t.Errorf("Do() got = %#v, want %#v", got, resp2)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		_, err := d.Do(ctx, "TS.GET", key+":missing")
+		var se redists.ServerError
+		if !errors.As(err, &se) {
+			t.Errorf("Do() error = %#v, want a redists.ServerError", err)
+		}
+		if _, err := tsclient.Get(ctx, key+":missing"); !errors.Is(err, redists.ErrKeyNotExist) {
+			t.Errorf("Get() error = %v, want %v", err, redists.ErrKeyNotExist)
+		}
+	})
+	t.Run("pipeline", func(t *testing.T) {
+		got, err := d.DoPipeline(ctx, []redists.PipelineCmd{
+			{Name: "TS.ADD", Args: []interface{}{key, 1, 1.5}},
+			{Name: "TS.GET", Args: []interface{}{key + ":missing"}},
+			{Name: "TS.MADD", Args: []interface{}{key, 2, 2.5, key + ":missing", 2, 2.5}},
+			{Name: "PING"},
+		})
+		if err != nil {
+			t.Fatalf("DoPipeline() error = %v", err)
+		}
+		if len(got) != 4 {
+			t.Fatalf("DoPipeline() got = %#v", got)
+		}
+		if got[0] != int64(1) {
+			t.Errorf("DoPipeline() got[0] = %#v, want %#v", got[0], int64(1))
+		}
+		if _, ok := got[1].(redists.ServerError); !ok {
+			t.Errorf("DoPipeline() got[1] = %#v, want a redists.ServerError", got[1])
+		}
+		if madd, ok := got[2].([]interface{}); !ok || len(madd) != 2 || madd[0] != int64(2) {
+			t.Errorf("DoPipeline() got[2] = %#v", got[2])
+		} else if _, ok := madd[1].(redists.ServerError); !ok {
+			t.Errorf("DoPipeline() got[2][1] = %#v, want a redists.ServerError", madd[1])
+		}
+		if got[3] != "PONG" {
+			t.Errorf("DoPipeline() got[3] = %#v, want %#v", got[3], "PONG")
+		}
+	})
+	t.Run("concurrency", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 16)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := tsclient.Range(ctx, key, redists.TSMin(), redists.TSMax())
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Range() error = %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,53 @@
+// Package reply converts the replies of Redis clients into the types used by
+// redists.Pool.
+package reply
+
+import "github.com/coding-socks/redists"
+
+// Normalize converts bulk strings to string and error replies to
+// redists.ServerError, also inside arrays and maps. The message of an error
+// reply is returned by serverError, which reports false for other errors.
+func Normalize(v interface{}, serverError func(err error) (string, bool)) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		// e.g. radix decodes a null reply as []byte(nil)
+		if v == nil {
+			return nil
+		}
+		return string(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = Normalize(v[i], serverError)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[Normalize(k, serverError)] = Normalize(e, serverError)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = Normalize(e, serverError)
+		}
+		return v
+	case error:
+		if msg, ok := serverError(v); ok {
+			return redists.ServerError(msg)
+		}
+		return v
+	}
+	return v
+}
+
+// Err converts an error reply to redists.ServerError and returns other errors
+// as they are.
+func Err(err error, serverError func(err error) (string, bool)) error {
+	if err == nil {
+		return nil
+	}
+	if msg, ok := serverError(err); ok {
+		return redists.ServerError(msg)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Package radix adapts github.com/mediocregopher/radix/v4 to redists.Doer.
+// The package name is the same as the one of radix, so it is usually imported
+// with an alias:
+//
+//	import radixdoer "github.com/coding-socks/redists/adapter/radix"
+//
+//	client, err := (radix.PoolConfig{}).New(ctx, "tcp", "localhost:6379")
+//	if err != nil {
+//		// handle error
+//	}
+//	defer client.Close()
+//	tsclient := redists.NewClient(radixdoer.New(client))
+package radix
+
+import (
+	"context"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/reply"
+	"github.com/mediocregopher/radix/v4"
+	"github.com/mediocregopher/radix/v4/resp"
+	"github.com/mediocregopher/radix/v4/resp/resp3"
+)
+
+// Doer is a redists.PipelineDoer which sends the commands with a radix
+// client. Error replies are returned whether or not the client is configured
+// with resp.Opts.DisableErrorBubbling.
+type Doer struct {
+	c radix.Client
+}
+
+var _ redists.PipelineDoer = (*Doer)(nil)
+
+// New returns a Doer which uses c. Closing c is up to the caller.
+func New(c radix.Client) *Doer {
+	return &Doer{c: c}
+}
+
+// serverError reports true, because the errors in a reply are the error
+// replies.
+func serverError(err error) (string, bool) {
+	return err.Error(), true
+}
+
+// value receives a reply with the error replies as values, so an error reply
+// in a pipeline does not hide the replies of the other commands.
+type value struct {
+	v interface{}
+}
+
+func (r *value) UnmarshalRESP(br resp.BufferedReader, o *resp.Opts) error {
+	o1 := *o
+	o1.DisableErrorBubbling = true
+	return resp3.Unmarshal(br, &r.v, &o1)
+}
+
+func (d *Doer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	var r value
+	if err := d.c.Do(ctx, radix.FlatCmd(&r, cmd, args...)); err != nil {
+		return nil, err
+	}
+	v := reply.Normalize(r.v, serverError)
+	if err, ok := v.(redists.ServerError); ok {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DoPipeline sends the commands with a radix pipeline.
+func (d *Doer) DoPipeline(ctx context.Context, cmds []redists.PipelineCmd) ([]interface{}, error) {
+	rs := make([]value, len(cmds))
+	p := radix.NewPipeline()
+	for i, cmd := range cmds {
+		p.Append(radix.FlatCmd(&rs[i], cmd.Name, cmd.Args...))
+	}
+	if err := d.c.Do(ctx, p); err != nil {
+		return nil, err
+	}
+	res := make([]interface{}, len(rs))
+	for i := range rs {
+		res[i] = reply.Normalize(rs[i].v, serverError)
+	}
+	return res, nil
+}
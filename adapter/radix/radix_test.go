@@ -0,0 +1,34 @@
+package radix_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/adaptertest"
+	radixdoer "github.com/coding-socks/redists/adapter/radix"
+	"github.com/coding-socks/redists/redistest"
+	"github.com/mediocregopher/radix/v4"
+)
+
+func TestDoer(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := (radix.PoolConfig{}).New(context.Background(), "tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	adaptertest.Run(t, radixdoer.New(client))
+}
+
+func TestConformance(t *testing.T) {
+	redistest.RunDoerConformance(t, func(t *testing.T) redists.Doer {
+		s := redistest.NewServer(t)
+		client, err := (radix.PoolConfig{}).New(context.Background(), "tcp", s.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+		return radixdoer.New(client)
+	})
+}
@@ -0,0 +1,83 @@
+// Package redigo adapts github.com/gomodule/redigo to redists.Doer.
+//
+//	pool := &redis.Pool{
+//		DialContext: func(ctx context.Context) (redis.Conn, error) {
+//			return redis.DialContext(ctx, "tcp", "localhost:6379")
+//		},
+//	}
+//	defer pool.Close()
+//	tsclient := redists.NewClient(redigo.New(pool))
+package redigo
+
+import (
+	"context"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/reply"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Doer is a redists.PipelineDoer which sends the commands on connections of a
+// redigo pool. Unlike a single redis.Conn, it is safe for concurrent use.
+type Doer struct {
+	p *redis.Pool
+}
+
+var _ redists.PipelineDoer = (*Doer)(nil)
+
+// New returns a Doer which uses p. Closing p is up to the caller.
+func New(p *redis.Pool) *Doer {
+	return &Doer{p: p}
+}
+
+func serverError(err error) (string, bool) {
+	if e, ok := err.(redis.Error); ok {
+		return string(e), true
+	}
+	return "", false
+}
+
+// Do sends the command on a connection of the pool. The connection has to
+// implement redis.ConnWithContext, which the connections of redis.Dial and
+// redis.DialContext do.
+func (d *Doer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	c, err := d.p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	v, err := redis.DoContext(c, ctx, cmd, args...)
+	if err != nil {
+		return nil, reply.Err(err, serverError)
+	}
+	return reply.Normalize(v, serverError), nil
+}
+
+// DoPipeline sends the commands on a single connection of the pool.
+func (d *Doer) DoPipeline(ctx context.Context, cmds []redists.PipelineCmd) ([]interface{}, error) {
+	c, err := d.p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	for _, cmd := range cmds {
+		if err := c.Send(cmd.Name, cmd.Args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	res := make([]interface{}, len(cmds))
+	for i := range cmds {
+		v, err := redis.ReceiveContext(c, ctx)
+		if _, ok := serverError(err); err != nil && !ok {
+			return nil, err
+		}
+		if err != nil {
+			v = err
+		}
+		res[i] = reply.Normalize(v, serverError)
+	}
+	return res, nil
+}
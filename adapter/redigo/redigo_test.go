@@ -0,0 +1,36 @@
+package redigo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/adaptertest"
+	"github.com/coding-socks/redists/adapter/redigo"
+	"github.com/coding-socks/redists/redistest"
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestDoer(t *testing.T) {
+	s := redistest.NewServer(t)
+	pool := &redis.Pool{
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", s.Addr())
+		},
+	}
+	defer pool.Close()
+	adaptertest.Run(t, redigo.New(pool))
+}
+
+func TestConformance(t *testing.T) {
+	redistest.RunDoerConformance(t, func(t *testing.T) redists.Doer {
+		s := redistest.NewServer(t)
+		pool := &redis.Pool{
+			DialContext: func(ctx context.Context) (redis.Conn, error) {
+				return redis.DialContext(ctx, "tcp", s.Addr())
+			},
+		}
+		t.Cleanup(func() { pool.Close() })
+		return redigo.New(pool)
+	})
+}
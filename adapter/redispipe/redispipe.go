@@ -0,0 +1,67 @@
+// Package redispipe adapts github.com/joomcode/redispipe to redists.Doer.
+//
+//	sender, err := redisconn.Connect(ctx, "localhost:6379", redisconn.Opts{})
+//	if err != nil {
+//		// handle error
+//	}
+//	defer sender.Close()
+//	tsclient := redists.NewClient(redispipe.New(sender))
+package redispipe
+
+import (
+	"context"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/reply"
+	"github.com/joomcode/errorx"
+	"github.com/joomcode/redispipe/redis"
+)
+
+// Doer is a redists.PipelineDoer which sends the commands with a redispipe
+// Sender. Commands are pipelined by the Sender, so concurrent calls share the
+// connection.
+type Doer struct {
+	s redis.Sender
+}
+
+var _ redists.PipelineDoer = (*Doer)(nil)
+
+// New returns a Doer which uses s. Closing s is up to the caller.
+func New(s redis.Sender) *Doer {
+	return &Doer{s: s}
+}
+
+func serverError(err error) (string, bool) {
+	e := errorx.Cast(err)
+	if e == nil || !e.IsOfType(redis.ErrResult) {
+		return "", false
+	}
+	return e.Message(), true
+}
+
+func (d *Doer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	res := redis.SyncCtx{S: d.s}.Do(ctx, cmd, args...)
+	if err := redis.AsError(res); err != nil {
+		return nil, reply.Err(err, serverError)
+	}
+	return reply.Normalize(res, serverError), nil
+}
+
+// DoPipeline sends the commands with Sender.SendMany.
+func (d *Doer) DoPipeline(ctx context.Context, cmds []redists.PipelineCmd) ([]interface{}, error) {
+	reqs := make([]redis.Request, len(cmds))
+	for i, cmd := range cmds {
+		reqs[i] = redis.Req(cmd.Name, cmd.Args...)
+	}
+	res := redis.SyncCtx{S: d.s}.SendMany(ctx, reqs)
+	for i := range res {
+		if err := redis.AsError(res[i]); err != nil {
+			if _, ok := serverError(err); !ok {
+				// only error replies are returned per command
+				return nil, err
+			}
+		}
+		res[i] = reply.Normalize(res[i], serverError)
+	}
+	return res, nil
+}
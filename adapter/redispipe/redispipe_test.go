@@ -0,0 +1,34 @@
+package redispipe_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/adaptertest"
+	"github.com/coding-socks/redists/adapter/redispipe"
+	"github.com/coding-socks/redists/redistest"
+	"github.com/joomcode/redispipe/redisconn"
+)
+
+func TestDoer(t *testing.T) {
+	s := redistest.NewServer(t)
+	sender, err := redisconn.Connect(context.Background(), s.Addr(), redisconn.Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	adaptertest.Run(t, redispipe.New(sender))
+}
+
+func TestConformance(t *testing.T) {
+	redistest.RunDoerConformance(t, func(t *testing.T) redists.Doer {
+		s := redistest.NewServer(t)
+		sender, err := redisconn.Connect(context.Background(), s.Addr(), redisconn.Opts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(sender.Close)
+		return redispipe.New(sender)
+	})
+}
@@ -0,0 +1,15 @@
+module github.com/coding-socks/redists/adapter/rueidis
+
+go 1.25.0
+
+require (
+	github.com/coding-socks/redists v0.1.0
+	github.com/redis/rueidis v1.0.78
+)
+
+require golang.org/x/sys v0.47.0 // indirect
+
+// The adapter is developed and tested against the redists in this
+// repository. Modules which import the adapter ignore the replace directive
+// and use the version required above.
+replace github.com/coding-socks/redists => ../..
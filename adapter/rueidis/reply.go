@@ -0,0 +1,53 @@
+package rueidis
+
+import "github.com/coding-socks/redists"
+
+// The helpers below are a copy of adapter/internal/reply. This module is
+// versioned separately, so it must not depend on internal packages, which
+// can change in any release of redists.
+
+// normalize converts bulk strings to string and error replies to
+// redists.ServerError, also inside arrays and maps.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		if v == nil {
+			return nil
+		}
+		return string(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[normalize(k)] = normalize(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+		return v
+	case error:
+		if msg, ok := serverError(v); ok {
+			return redists.ServerError(msg)
+		}
+		return v
+	}
+	return v
+}
+
+// replyErr converts an error reply to redists.ServerError and returns other
+// errors as they are.
+func replyErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg, ok := serverError(err); ok {
+		return redists.ServerError(msg)
+	}
+	return err
+}
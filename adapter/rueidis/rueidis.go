@@ -0,0 +1,220 @@
+// Package rueidis adapts github.com/redis/rueidis to redists.Doer. The package
+// name is the same as the one of rueidis, so it is usually imported with an
+// alias:
+//
+//	import rueidisdoer "github.com/coding-socks/redists/adapter/rueidis"
+//
+//	client, err := rueidis.NewClient(rueidis.ClientOption{InitAddress: []string{"localhost:6379"}})
+//	if err != nil {
+//		// handle error
+//	}
+//	defer client.Close()
+//	tsclient := redists.NewClient(rueidisdoer.New(client))
+//
+// The package is a separate module, because rueidis needs a newer Go version
+// than redists.
+package rueidis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coding-socks/redists"
+	"github.com/redis/rueidis"
+)
+
+// Doer is a redists.PipelineDoer which sends the commands with a rueidis
+// client.
+//
+// TS.GET, TS.INFO, and TS.RANGE and TS.REVRANGE over closed windows use the
+// server-assisted client-side cache of rueidis, so they are served from
+// memory until the server invalidates the key. A window is closed when its
+// end is a timestamp in the past and LATEST is not used. Ranges which end
+// with "+" or in the future are not cached, because every new sample would
+// invalidate them.
+//
+// The other commands, such as TS.ADD and TS.MADD, are sent with Client.Do, so
+// concurrent calls are automatically pipelined by rueidis.
+type Doer struct {
+	c   rueidis.Client
+	ttl time.Duration
+	now func() time.Time
+}
+
+var _ redists.PipelineDoer = (*Doer)(nil)
+
+type OptionDoer func(d *Doer)
+
+// New returns a Doer which uses c. Closing c is up to the caller.
+func New(c rueidis.Client, options ...OptionDoer) *Doer {
+	d := &Doer{c: c, ttl: time.Minute, now: time.Now}
+	for i := range options {
+		options[i](d)
+	}
+	return d
+}
+
+// DoerWithCacheTTL sets the client-side TTL of cached replies. The default is
+// one minute, and zero disables the cache.
+func DoerWithCacheTTL(ttl time.Duration) OptionDoer {
+	return func(d *Doer) {
+		d.ttl = ttl
+	}
+}
+
+// DoerWithClock sets the clock which decides whether a window is closed.
+func DoerWithClock(now func() time.Time) OptionDoer {
+	return func(d *Doer) {
+		d.now = now
+	}
+}
+
+func serverError(err error) (string, bool) {
+	if e, ok := rueidis.IsRedisErr(err); ok {
+		return e.Error(), true
+	}
+	return "", false
+}
+
+func result(r rueidis.RedisResult) (interface{}, error) {
+	v, err := r.ToAny()
+	if rueidis.IsRedisNil(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, replyErr(err)
+	}
+	return normalize(v), nil
+}
+
+// build returns the rueidis command, and whether its reply is cached.
+func (d *Doer) build(cmd string, args []interface{}) (rueidis.Completed, bool) {
+	name := strings.ToUpper(cmd)
+	sargs := make([]string, len(args))
+	for i := range args {
+		sargs[i] = str(args[i])
+	}
+	b := d.c.B().Arbitrary(cmd)
+	for i, arg := range sargs {
+		if isKey(name, i) {
+			b = b.Keys(arg)
+		} else {
+			b = b.Args(arg)
+		}
+	}
+	if redists.ClassifyCmd(cmd, args, "") != redists.CmdClassRead {
+		return b.Build(), false
+	}
+	return b.ReadOnly(), d.ttl > 0 && d.cacheable(name, sargs)
+}
+
+// isKey reports whether the i-th argument of a command is a key, which
+// rueidis needs to find the slot of the command in a cluster.
+func isKey(name string, i int) bool {
+	switch name {
+	case "PING", "TS.MRANGE", "TS.MREVRANGE", "TS.MGET", "TS.QUERYINDEX":
+		return false
+	case "TS.MADD":
+		return i%3 == 0
+	case "TS.CREATERULE", "TS.DELETERULE":
+		return i < 2
+	case "DEL", "EXISTS":
+		return true
+	}
+	return i == 0
+}
+
+func (d *Doer) cacheable(name string, args []string) bool {
+	switch name {
+	case "TS.GET", "TS.INFO":
+		return true
+	case "TS.RANGE", "TS.REVRANGE":
+		if len(args) < 3 {
+			return false
+		}
+		to, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil || to >= d.now().UnixMilli() {
+			return false
+		}
+		for _, arg := range args[3:] {
+			// LATEST reads the open bucket of a compaction from its source
+			if strings.EqualFold(arg, "LATEST") {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (d *Doer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	c, cache := d.build(cmd, args)
+	if cache {
+		return result(d.c.DoCache(ctx, rueidis.Cacheable(c), d.ttl))
+	}
+	return result(d.c.Do(ctx, c))
+}
+
+// DoPipeline sends the commands with Client.DoMultiCache when all of them are
+// cached, and with Client.DoMulti otherwise, so the commands run in order.
+func (d *Doer) DoPipeline(ctx context.Context, cmds []redists.PipelineCmd) ([]interface{}, error) {
+	multi := make([]rueidis.Completed, len(cmds))
+	cache := len(cmds) > 0
+	for i, cmd := range cmds {
+		var ok bool
+		multi[i], ok = d.build(cmd.Name, cmd.Args)
+		cache = cache && ok
+	}
+	var rs []rueidis.RedisResult
+	if cache {
+		cached := make([]rueidis.CacheableTTL, len(multi))
+		for i := range multi {
+			cached[i] = rueidis.CT(rueidis.Cacheable(multi[i]), d.ttl)
+		}
+		rs = d.c.DoMultiCache(ctx, cached...)
+	} else if len(multi) > 0 {
+		rs = d.c.DoMulti(ctx, multi...)
+	}
+	res := make([]interface{}, len(cmds))
+	for i, r := range rs {
+		v, err := result(r)
+		if err != nil {
+			// only error replies are returned per command
+			if _, ok := err.(redists.ServerError); !ok {
+				return nil, err
+			}
+			v = err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// str formats an argument like the other clients do.
+func str(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return "+inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(arg)
+}
@@ -0,0 +1,151 @@
+package rueidis_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/adapter/internal/adaptertest"
+	rueidisdoer "github.com/coding-socks/redists/adapter/rueidis"
+	"github.com/coding-socks/redists/redistest"
+	"github.com/redis/rueidis"
+)
+
+func newClient(t *testing.T, s *redistest.Server) rueidis.Client {
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:       []string{s.Addr()},
+		ForceSingleClient: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestDoer(t *testing.T) {
+	s := redistest.NewServer(t)
+	adaptertest.Run(t, rueidisdoer.New(newClient(t, s)))
+}
+
+func TestDoer_cache(t *testing.T) {
+	ctx := context.Background()
+	s := redistest.NewServer(t)
+	now := time.UnixMilli(10000)
+	d := rueidisdoer.New(newClient(t, s), rueidisdoer.DoerWithClock(func() time.Time { return now }))
+	tsclient := redists.NewClient(d)
+	for _, ts := range []int64{1000, 2000} {
+		if _, err := tsclient.Add(ctx, redists.NewSample("key:cache", time.UnixMilli(ts), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmds := [][]interface{}{
+		{"key:cache"},
+		{"key:cache", 0, 5000},
+		{"key:cache", 0, "+"},
+		{"key:cache", 0, 20000},
+	}
+	get := func() []interface{} {
+		t.Helper()
+		res := make([]interface{}, len(cmds))
+		for i, args := range cmds {
+			name := "TS.RANGE"
+			if i == 0 {
+				name = "TS.GET"
+			}
+			v, err := d.Do(ctx, name, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res[i] = v
+		}
+		return res
+	}
+	before := get()
+	// writes through the Doer of redistest.Server bypass tracking, so the
+	// cached replies do not see the new sample
+	if _, err := s.Doer().Do(ctx, "TS.ADD", "key:cache", 3000, 1); err != nil {
+		t.Fatal(err)
+	}
+	after := get()
+	tests := []struct {
+		name   string
+		i      int
+		cached bool
+	}{
+		{name: "TS.GET", i: 0, cached: true},
+		{name: "closed window", i: 1, cached: true},
+		{name: "open window", i: 2, cached: false},
+		{name: "future window", i: 3, cached: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reflect.DeepEqual(before[tt.i], after[tt.i]); got != tt.cached {
+				t.Errorf("cached = %v, want %v; before = %v, after = %v", got, tt.cached, before[tt.i], after[tt.i])
+			}
+		})
+	}
+}
+
+func TestDoer_invalidation(t *testing.T) {
+	ctx := context.Background()
+	s := redistest.NewServer(t)
+	now := time.UnixMilli(10000)
+	d := rueidisdoer.New(newClient(t, s), rueidisdoer.DoerWithClock(func() time.Time { return now }))
+	tsclient := redists.NewClient(d)
+	if _, err := tsclient.Add(ctx, redists.NewSample("key:invalidation", time.UnixMilli(1000), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tsclient.Range(ctx, "key:invalidation", time.UnixMilli(0), time.UnixMilli(5000)); err != nil {
+		t.Fatal(err)
+	}
+	// the server invalidates the cached window before it replies to TS.ADD
+	if _, err := tsclient.Add(ctx, redists.NewSample("key:invalidation", time.UnixMilli(2000), 2)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tsclient.Range(ctx, "key:invalidation", time.UnixMilli(0), time.UnixMilli(5000))
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	want := []redists.DataPoint{{Timestamp: time.UnixMilli(1000), Value: 1}, {Timestamp: time.UnixMilli(2000), Value: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Range() got = %v, want %v", got, want)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	redistest.RunDoerConformance(t, func(t *testing.T) redists.Doer {
+		return rueidisdoer.New(newClient(t, redistest.NewServer(t)))
+	})
+}
+
+func TestDoer_pipelineOrder(t *testing.T) {
+	ctx := context.Background()
+	s := redistest.NewServer(t)
+	now := time.UnixMilli(10000)
+	d := rueidisdoer.New(newClient(t, s), rueidisdoer.DoerWithClock(func() time.Time { return now }))
+	if _, err := redists.NewClient(d).Add(ctx, redists.NewSample("key:order", time.UnixMilli(1000), 1)); err != nil {
+		t.Fatal(err)
+	}
+	// TS.GET and the closed window are cached, TS.ADD is not, and the
+	// commands must run in the order of the pipeline
+	got, err := d.DoPipeline(ctx, []redists.PipelineCmd{
+		{Name: "TS.GET", Args: []interface{}{"key:order"}},
+		{Name: "TS.ADD", Args: []interface{}{"key:order", 2000, 2}},
+		{Name: "TS.RANGE", Args: []interface{}{"key:order", 0, 5000}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		[]interface{}{int64(1000), 1.0},
+		int64(2000),
+		[]interface{}{[]interface{}{int64(1000), 1.0}, []interface{}{int64(2000), 2.0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DoPipeline() got = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package redists
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Appender encodes the arguments of a command as RESP bulk strings. Its
+// buffer is reused after Reset, so once it is large enough encoding the
+// arguments does not allocate.
+type Appender struct {
+	buf []byte
+	n   int
+}
+
+// Reset removes the arguments, and keeps the buffer.
+func (a *Appender) Reset() {
+	a.buf = a.buf[:0]
+	a.n = 0
+}
+
+// Len returns the number of arguments.
+func (a *Appender) Len() int {
+	return a.n
+}
+
+func (a *Appender) AppendString(s string) {
+	a.buf = appendBulkString(a.buf, s)
+	a.n++
+}
+
+func (a *Appender) AppendBytes(b []byte) {
+	a.buf = appendBulkBytes(a.buf, b)
+	a.n++
+}
+
+func (a *Appender) AppendInt(i int64) {
+	var buf [20]byte
+	a.AppendBytes(strconv.AppendInt(buf[:0], i, 10))
+}
+
+// AppendFloat formats f like appendArg does.
+func (a *Appender) AppendFloat(f float64) {
+	var buf [32]byte
+	a.AppendBytes(appendFloat(buf[:0], f, 64))
+}
+
+// AppendTimestamp appends "-", "+", "*" or the milliseconds of ts like
+// timestampArg, without converting the milliseconds to an interface value.
+func (a *Appender) AppendTimestamp(ts Timestamp) {
+	if v, ok := ts.(TimestampMin); ok && v.Min() {
+		a.AppendString("-")
+	} else if v, ok := ts.(TimestampMax); ok && v.Max() {
+		a.AppendString("+")
+	} else if v, ok := ts.(TimestampAuto); ok && v.Auto() {
+		a.AppendString("*")
+	} else {
+		a.AppendInt(ts.UnixMilli())
+	}
+}
+
+// AppendCommand appends cmd and the arguments to b as a RESP array.
+func (a *Appender) AppendCommand(b []byte, cmd string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(a.n+1), 10)
+	b = append(b, '\r', '\n')
+	b = appendBulkString(b, cmd)
+	return append(b, a.buf...)
+}
+
+// ArgsAppender is implemented by commands which can encode their arguments
+// with an Appender, e.g. CmdAdd and CmdMAdd.
+type ArgsAppender interface {
+	AppendArgs(a *Appender)
+}
+
+// AppendDoer is implemented by Doers which send the arguments encoded by an
+// Appender as they are, e.g. Pool. Client uses it instead of Do for the
+// commands which implement ArgsAppender when it has no hooks, so the
+// arguments are not converted to interface values.
+type AppendDoer interface {
+	DoAppender(ctx context.Context, cmd string, a *Appender) (interface{}, error)
+}
+
+var appenders = sync.Pool{
+	New: func() interface{} { return new(Appender) },
+}
+
+// LabelSet is a set of labels which is sorted and encoded once, so the writes
+// of a series can reuse it.
+type LabelSet struct {
+	args []interface{}
+	enc  Appender
+}
+
+// NewLabelSet returns the encoded ls. Later changes of ls are not reflected
+// by the returned LabelSet. It returns nil for nil ls, so no LABELS are sent,
+// like CreateWithLabels(nil).
+func NewLabelSet(ls Labels) *LabelSet {
+	if ls == nil {
+		return nil
+	}
+	s := &LabelSet{args: encodeLabels(ls)}
+	for _, arg := range s.args {
+		s.enc.AppendString(arg.(string))
+	}
+	return s
+}
+
+// appendTo appends the labels to a.
+func (s *LabelSet) appendTo(a *Appender) {
+	a.buf = append(a.buf, s.enc.buf...)
+	a.n += s.enc.n
+}
+
+func encodeLabels(ls map[string]string) []interface{} {
+	args := make([]interface{}, 0, 2*len(ls))
+	// keep order consistent for testing
+	keys := make([]string, 0, len(ls))
+	for key := range ls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, key, ls[key])
+	}
+	return args
+}
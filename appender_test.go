@@ -0,0 +1,178 @@
+package redists
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAppender(t *testing.T) {
+	ls := Labels{"b": "2", "a": "1"}
+	tests := []struct {
+		name string
+		cmd  interface {
+			Cmd
+			ArgsAppender
+		}
+	}{
+		{name: "add", cmd: NewCmdAdd(NewSample("key:any", time.UnixMilli(1000), 1.5))},
+		{name: "add auto", cmd: NewCmdAdd(NewSample("key:any", TSAuto(), math.Inf(-1)))},
+		{name: "add options", cmd: NewCmdAdd(NewSample("key:any", time.UnixMilli(1000), 1),
+			AddWithRetention(time.Second), AddWithEncoding(EncodingUncompressed), AddWithChunkSize(128),
+			AddWithOnDuplicate(DuplicatePolicyMax), AddWithLabels(ls))},
+		{name: "madd", cmd: NewCmdMAdd([]Sample{
+			NewSample("key:a", time.UnixMilli(1000), 1),
+			NewSample("key:b", TSAuto(), -2.25),
+		})},
+		{name: "madd empty", cmd: NewCmdMAdd(nil)},
+		{name: "incrby", cmd: NewCmdIncrBy("key:any", 2, CounterWithTimestamp(time.UnixMilli(1000)),
+			CounterWithRetention(time.Second), CounterWithEncoding(EncodingUncompressed), CounterWithChunkSize(128),
+			CounterWithLabels(ls))},
+		{name: "nil labels", cmd: NewCmdAdd(NewSample("key:any", time.UnixMilli(1000), 1), AddWithLabels(nil))},
+		{name: "empty labels", cmd: NewCmdIncrBy("key:any", 2, CounterWithLabels(Labels{}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Appender
+			a.AppendString("stale")
+			a.Reset()
+			tt.cmd.AppendArgs(&a)
+			if got, want := a.Len(), len(tt.cmd.Args()); got != want {
+				t.Errorf("Len() = %v, want %v", got, want)
+			}
+			got := a.AppendCommand(nil, tt.cmd.Name())
+			want := appendCommand(nil, tt.cmd.Name(), tt.cmd.Args())
+			if !bytes.Equal(got, want) {
+				t.Errorf("AppendCommand() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNewLabelSet(t *testing.T) {
+	ls := Labels{"b": "2", "a": "1"}
+	opt := AddWithLabels(ls)
+	ls["c"] = "3"
+	cmd := NewCmdAdd(NewSample("key:any", time.UnixMilli(1000), 1), opt)
+	want := []interface{}{"key:any", int64(1000), 1.0, "LABELS", "a", "1", "b", "2"}
+	if got := cmd.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+}
+
+// replyConn replies to every write with the same reply.
+type replyConn struct {
+	net.Conn
+	reply []byte
+	r     bytes.Reader
+}
+
+func (c *replyConn) Write(b []byte) (int, error) {
+	c.r.Reset(c.reply)
+	return len(b), nil
+}
+
+func (c *replyConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *replyConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (c *replyConn) Close() error {
+	return nil
+}
+
+// benchPool returns a Pool with a single connection which replies with reply.
+func benchPool(b *testing.B, reply []byte) *Pool {
+	p, err := Dial(context.Background(), "redis://", DialWithMaxConns(1), DialWithHealthCheck(0),
+		DialWithDialer(func(context.Context, string, string) (net.Conn, error) {
+			return &replyConn{reply: reply}, nil
+		}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { p.Close() })
+	return p
+}
+
+func BenchmarkClient_Add(b *testing.B) {
+	ctx := context.Background()
+	s := NewSample("key:bench", time.UnixMilli(1640995200000), 21.5)
+	opt := AddWithLabels(Labels{"sensor": "2", "room": "kitchen"})
+	b.Run("args", func(b *testing.B) {
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd := NewCmdAdd(s, opt)
+			buf = appendCommand(buf[:0], cmd.Name(), cmd.Args())
+		}
+	})
+	b.Run("appender", func(b *testing.B) {
+		var a Appender
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd := NewCmdAdd(s, opt)
+			a.Reset()
+			cmd.AppendArgs(&a)
+			buf = a.AppendCommand(buf[:0], cmd.Name())
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		c := NewClient(benchPool(b, []byte(":1640995200000\r\n")))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.Add(ctx, s, opt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkClient_MAdd(b *testing.B) {
+	ctx := context.Background()
+	samples := make([]Sample, 1000)
+	reply := []byte("*1000\r\n")
+	for i := range samples {
+		ts := int64(1640995200000 + i)
+		samples[i] = NewSample("key:bench:"+strconv.Itoa(i%10), time.UnixMilli(ts), float64(i))
+		reply = append(reply, ':')
+		reply = strconv.AppendInt(reply, ts, 10)
+		reply = append(reply, '\r', '\n')
+	}
+	b.Run("args", func(b *testing.B) {
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd := NewCmdMAdd(samples)
+			buf = appendCommand(buf[:0], cmd.Name(), cmd.Args())
+		}
+	})
+	b.Run("appender", func(b *testing.B) {
+		var a Appender
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd := NewCmdMAdd(samples)
+			a.Reset()
+			cmd.AppendArgs(&a)
+			buf = a.AppendCommand(buf[:0], cmd.Name())
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		c := NewClient(benchPool(b, reply))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.MAdd(ctx, samples); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
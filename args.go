@@ -1,7 +1,6 @@
 package redists
 
 import (
-	"sort"
 	"strings"
 	"time"
 )
@@ -37,8 +36,9 @@ const (
 
 type Encoding string
 
-func parseEncoding(i interface{}) Encoding {
-	return Encoding(strings.ToUpper(parseString(i)))
+func parseEncoding(field string, i interface{}) (Encoding, error) {
+	s, err := parseString(field, i)
+	return Encoding(strings.ToUpper(s)), err
 }
 
 const (
@@ -58,8 +58,9 @@ const (
 
 type DuplicatePolicy string
 
-func parseDuplicatePolicy(i interface{}) DuplicatePolicy {
-	return DuplicatePolicy(strings.ToUpper(parseString(i)))
+func parseDuplicatePolicy(field string, i interface{}) (DuplicatePolicy, error) {
+	s, err := parseString(field, i)
+	return DuplicatePolicy(strings.ToUpper(s)), err
 }
 
 const (
@@ -106,8 +107,9 @@ const (
 
 type AggregationType string
 
-func parseAggregationType(i interface{}) AggregationType {
-	return AggregationType(strings.ToUpper(parseString(i)))
+func parseAggregationType(field string, i interface{}) (AggregationType, error) {
+	s, err := parseString(field, i)
+	return AggregationType(strings.ToUpper(s)), err
 }
 
 type Aggregation struct {
@@ -117,27 +119,47 @@ type Aggregation struct {
 
 type Labels map[string]string
 
-func parseLabels(is []interface{}) Labels {
+func parseLabels(field string, val interface{}) (Labels, error) {
+	// RESP3 replies contain a map instead of [name, value] pairs
+	if es, ok, err := parseMap(field, val); ok {
+		if err != nil {
+			return nil, err
+		}
+		ls := make(Labels, len(es))
+		for _, e := range es {
+			if ls[e.key], err = parseLabelValue(field+"."+e.key, e.val); err != nil {
+				return nil, err
+			}
+		}
+		return ls, nil
+	}
+	is, err := parseArray(field, val)
+	if err != nil {
+		return nil, err
+	}
 	ls := make(Labels, len(is))
 	for i := range is {
-		v := is[i].([]interface{})
-		ls[parseString(v[0])] = parseString(v[1])
+		v, err := parseTuple(field, is[i], 2)
+		if err != nil {
+			return nil, err
+		}
+		name, err := parseString(field+".name", v[0])
+		if err != nil {
+			return nil, err
+		}
+		if ls[name], err = parseLabelValue(field+"."+name, v[1]); err != nil {
+			return nil, err
+		}
 	}
-	return ls
+	return ls, nil
 }
 
-func encodeLabels(ls map[string]string) []interface{} {
-	var args []interface{}
-	// keep order consistent for testing
-	keys := make([]string, 0, len(ls))
-	for key := range ls {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	for _, key := range keys {
-		args = append(args, key, ls[key])
+func parseLabelValue(field string, val interface{}) (string, error) {
+	// a label selected with SELECTED_LABELS which the series does not have is nil
+	if val == nil {
+		return "", nil
 	}
-	return args
+	return parseString(field, val)
 }
 
 type Sample struct {
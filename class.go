@@ -0,0 +1,120 @@
+package redists
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CmdClass classifies commands by their effect on the data, so middleware
+// can decide whether a command is safe to send again.
+type CmdClass int
+
+const (
+	// CmdClassRead commands do not modify data, e.g. TS.RANGE.
+	CmdClassRead CmdClass = iota
+	// CmdClassWrite commands modify data, but sending them again leaves the
+	// same data and does not fail, e.g. TS.ADD with an explicit timestamp and
+	// the LAST duplicate policy.
+	CmdClassWrite
+	// CmdClassNonIdempotent commands modify data, and sending them again
+	// modifies it again or fails, e.g. TS.INCRBY. Unknown commands are in
+	// this class too.
+	CmdClassNonIdempotent
+)
+
+func (c CmdClass) String() string {
+	switch c {
+	case CmdClassRead:
+		return "read"
+	case CmdClassWrite:
+		return "write"
+	case CmdClassNonIdempotent:
+		return "non-idempotent"
+	}
+	return fmt.Sprintf("CmdClass(%d)", int(c))
+}
+
+// readCmds are the commands in CmdClassRead.
+var readCmds = map[string]bool{
+	string(nameRange):     true,
+	string(nameRevRange):  true,
+	string(nameMRange):    true,
+	string(nameMRevRange): true,
+	"TS.GET":              true,
+	"TS.MGET":             true,
+	"TS.INFO":             true,
+	"TS.QUERYINDEX":       true,
+	"PING":                true,
+	"EXISTS":              true,
+	"TYPE":                true,
+}
+
+// writeCmds are the commands in CmdClassWrite. TS.ADD and TS.MADD are only
+// in the class when their duplicate policy is idempotent.
+var writeCmds = map[string]bool{
+	"TS.ALTER": true,
+	"TS.DEL":   true,
+	"TS.ADD":   true,
+	"TS.MADD":  true,
+}
+
+// idempotentDuplicatePolicies are the duplicate policies where adding the
+// same sample again leaves the same value.
+var idempotentDuplicatePolicies = map[DuplicatePolicy]bool{
+	DuplicatePolicyFirst: true,
+	DuplicatePolicyLast:  true,
+	DuplicatePolicyMin:   true,
+	DuplicatePolicyMax:   true,
+}
+
+// Idempotent reports whether adding the same sample again under dp leaves the
+// same value. An empty dp means DuplicatePolicyBlock.
+func (dp DuplicatePolicy) Idempotent() bool {
+	return idempotentDuplicatePolicies[dp]
+}
+
+// ClassifyCmd returns the class of a command. TS.ADD and TS.MADD are writes
+// when every sample has an explicit timestamp and the duplicate policy is
+// idempotent. The ON_DUPLICATE option of TS.ADD takes precedence over dp,
+// which is the duplicate policy of the time-series. An empty dp means
+// DuplicatePolicyBlock, the default of RedisTimeSeries.
+func ClassifyCmd(name string, args []interface{}, dp DuplicatePolicy) CmdClass {
+	name = strings.ToUpper(name)
+	switch {
+	case readCmds[name]:
+		return CmdClassRead
+	case !writeCmds[name]:
+		return CmdClassNonIdempotent
+	}
+	switch name {
+	case "TS.ADD":
+		if len(args) < 3 || isAutoTimestamp(args[1]) {
+			return CmdClassNonIdempotent
+		}
+		// the labels are the last option, and they may contain any string
+		for i := 3; i+1 < len(args) && args[i] != optionNameLabels; i++ {
+			if s, ok := args[i].(string); ok && strings.EqualFold(s, optionNameOnDuplicate) {
+				dp = DuplicatePolicy(strings.ToUpper(fmt.Sprint(args[i+1])))
+				break
+			}
+		}
+		if !dp.Idempotent() {
+			return CmdClassNonIdempotent
+		}
+	case "TS.MADD":
+		for i := 1; i < len(args); i += 3 {
+			if isAutoTimestamp(args[i]) {
+				return CmdClassNonIdempotent
+			}
+		}
+		if !dp.Idempotent() {
+			return CmdClassNonIdempotent
+		}
+	}
+	return CmdClassWrite
+}
+
+func isAutoTimestamp(arg interface{}) bool {
+	s, ok := arg.(string)
+	return ok && s == "*"
+}
@@ -0,0 +1,53 @@
+package redists
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyCmd(t *testing.T) {
+	ts := time.UnixMilli(1000)
+	tests := []struct {
+		name string
+		cmd  Cmd
+		dp   DuplicatePolicy
+		want CmdClass
+	}{
+		{"range", NewCmdRange("key:any", TSMin(), TSMax()), "", CmdClassRead},
+		{"mget", NewCmdMGet([]Filter{FilterEqual("env", "prod")}), "", CmdClassRead},
+		{"info", NewCmdInfo("key:any"), "", CmdClassRead},
+		{"queryindex", NewCmdQueryIndex([]Filter{FilterEqual("env", "prod")}), "", CmdClassRead},
+		{"add block", NewCmdAdd(NewSample("key:any", ts, 1)), "", CmdClassNonIdempotent},
+		{"add last", NewCmdAdd(NewSample("key:any", ts, 1)), DuplicatePolicyLast, CmdClassWrite},
+		{"add on duplicate", NewCmdAdd(NewSample("key:any", ts, 1), AddWithOnDuplicate(DuplicatePolicyMax)), "", CmdClassWrite},
+		{"add on duplicate sum", NewCmdAdd(NewSample("key:any", ts, 1), AddWithOnDuplicate(DuplicatePolicySum)), DuplicatePolicyLast, CmdClassNonIdempotent},
+		{"add auto", NewCmdAdd(NewSample("key:any", TSAuto(), 1)), DuplicatePolicyLast, CmdClassNonIdempotent},
+		{"madd", NewCmdMAdd([]Sample{NewSample("key:any", ts, 1)}), DuplicatePolicyFirst, CmdClassWrite},
+		{"madd auto", NewCmdMAdd([]Sample{NewSample("key:any", ts, 1), NewSample("key:any", TSAuto(), 1)}), DuplicatePolicyFirst, CmdClassNonIdempotent},
+		{"incrby", NewCmdIncrBy("key:any", 1), DuplicatePolicyLast, CmdClassNonIdempotent},
+		{"create", NewCmdCreate("key:any"), "", CmdClassNonIdempotent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyCmd(tt.cmd.Name(), tt.cmd.Args(), tt.dp); got != tt.want {
+				t.Errorf("ClassifyCmd() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicatePolicy_Idempotent(t *testing.T) {
+	for dp, want := range map[DuplicatePolicy]bool{
+		"":                   false,
+		DuplicatePolicyBlock: false,
+		DuplicatePolicySum:   false,
+		DuplicatePolicyFirst: true,
+		DuplicatePolicyLast:  true,
+		DuplicatePolicyMin:   true,
+		DuplicatePolicyMax:   true,
+	} {
+		if got := dp.Idempotent(); got != want {
+			t.Errorf("%q.Idempotent() = %v, want %v", dp, got, want)
+		}
+	}
+}
@@ -2,16 +2,119 @@ package redists
 
 import (
 	"context"
+	"strings"
 )
 
 type Doer interface {
 	Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error)
 }
 
+// Cmd is a RedisTimeSeries command which can be sent with Client.DoCmd.
+type Cmd interface {
+	Name() string
+	Args() []interface{}
+}
+
 type Client struct {
-	d Doer
+	d     Doer
+	hooks hooks
+}
+
+type OptionClient func(c *Client)
+
+func NewClient(d Doer, options ...OptionClient) *Client {
+	c := &Client{d: d}
+	for i := range options {
+		options[i](c)
+	}
+	return c
+}
+
+// ClientWithHooks adds hooks which are called around every command.
+func ClientWithHooks(hs ...Hook) OptionClient {
+	return func(c *Client) {
+		c.hooks = append(c.hooks, hs...)
+	}
+}
+
+// do sends cmd and wraps the returned error in a CmdError.
+func (c *Client) do(ctx context.Context, cmd Cmd) (interface{}, error) {
+	if ad, ok := c.d.(AppendDoer); ok && len(c.hooks) == 0 {
+		if aa, ok := cmd.(ArgsAppender); ok {
+			return c.doAppender(ctx, ad, cmd, aa)
+		}
+	}
+	res, err := c.hooks.do(ctx, c.d, cmd.Name(), cmd.Args())
+	if err != nil {
+		return res, newCmdError(cmd.Name(), cmd.Args(), err)
+	}
+	return res, nil
+}
+
+// doAppender sends cmd with the arguments encoded by a pooled Appender.
+func (c *Client) doAppender(ctx context.Context, d AppendDoer, cmd Cmd, aa ArgsAppender) (interface{}, error) {
+	a := appenders.Get().(*Appender)
+	a.Reset()
+	aa.AppendArgs(a)
+	res, err := d.DoAppender(ctx, cmd.Name(), a)
+	appenders.Put(a)
+	if err != nil {
+		return res, newCmdError(cmd.Name(), cmd.Args(), err)
+	}
+	return res, nil
+}
+
+var replyParsers = map[string]func(res interface{}) (interface{}, error){
+	"TS.CREATE":     parseStatus,
+	"TS.ALTER":      parseStatus,
+	"TS.CREATERULE": parseStatus,
+	"TS.DELETERULE": parseStatus,
+	"TS.ADD":        func(res interface{}) (interface{}, error) { return parseTime(res) },
+	"TS.INCRBY":     func(res interface{}) (interface{}, error) { return parseTime(res) },
+	"TS.DECRBY":     func(res interface{}) (interface{}, error) { return parseTime(res) },
+	"TS.MADD":       func(res interface{}) (interface{}, error) { return parseMultiResults(res) },
+	"TS.DEL":        func(res interface{}) (interface{}, error) { return parseInt64("deleted", res) },
+	"TS.RANGE":      func(res interface{}) (interface{}, error) { return parseDataPoints(res) },
+	"TS.REVRANGE":   func(res interface{}) (interface{}, error) { return parseDataPoints(res) },
+	"TS.MRANGE":     func(res interface{}) (interface{}, error) { return parseTimeSeriesList(res) },
+	"TS.MREVRANGE":  func(res interface{}) (interface{}, error) { return parseTimeSeriesList(res) },
+	"TS.GET":        func(res interface{}) (interface{}, error) { return parseLastDataPoint(res) },
+	"TS.MGET":       func(res interface{}) (interface{}, error) { return parseLastDatapoints(res) },
+	"TS.INFO":       func(res interface{}) (interface{}, error) { return parseInfo(res) },
+	"TS.QUERYINDEX": func(res interface{}) (interface{}, error) { return parseKeys(res) },
+}
+
+func parseStatus(interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+// parseReply decodes res with the parser of the command with the same name.
+// Replies of unknown commands are returned as is.
+func parseReply(cmd Cmd, res interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd.Name())
+	parse, ok := replyParsers[name]
+	if !ok {
+		return res, nil
+	}
+	v, err := parse(res)
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := v.([]MultiResult); ok {
+		wrapMultiResults(cmd, rs)
+	}
+	return v, nil
 }
 
-func NewClient(d Doer) *Client {
-	return &Client{d: d}
+// DoCmd sends cmd and decodes its reply with the same parser as the typed
+// method of the command with the same name. This means TS.RANGE returns
+// []DataPoint, TS.INFO returns Info, and so on; commands which only report
+// an error return nil. The reply of an unknown command is returned as is.
+func (c *Client) DoCmd(ctx context.Context, cmd Cmd) (interface{}, error) {
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseReply(cmd, res)
+	return v, wrapError(cmd, err)
 }
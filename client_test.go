@@ -10,6 +10,7 @@ import (
 	"github.com/mediocregopher/radix/v4"
 	"github.com/mediocregopher/radix/v4/resp"
 	"io"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -23,6 +24,28 @@ func (f goredisDoer) Do(ctx context.Context, cmd string, args ...interface{}) (i
 	return f.UniversalClient.Do(ctx, args...).Result()
 }
 
+func (f goredisDoer) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	pipe := f.UniversalClient.Pipeline()
+	gcmds := make([]*goredis.Cmd, len(cmds))
+	for i, cmd := range cmds {
+		gcmds[i] = pipe.Do(ctx, append([]interface{}{cmd.Name}, cmd.Args...)...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		if _, ok := err.(goredis.Error); !ok {
+			return nil, err
+		}
+	}
+	res := make([]interface{}, len(gcmds))
+	for i, cmd := range gcmds {
+		if err := cmd.Err(); err != nil {
+			res[i] = err
+			continue
+		}
+		res[i] = cmd.Val()
+	}
+	return res, nil
+}
+
 type redigoDoer struct {
 	redigo.Conn
 }
@@ -53,7 +76,7 @@ type redispipeDoer struct {
 }
 
 func (f redispipeDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
-	res := redispipe.SyncCtx{f.Sender}.Do(ctx, cmd, args...)
+	res := redispipe.SyncCtx{S: f.Sender}.Do(ctx, cmd, args...)
 	if err := redispipe.AsError(res); err != nil {
 		return nil, err
 	}
@@ -70,6 +93,10 @@ type doCloser interface {
 	io.Closer
 }
 
+// redisAddr is the address of the Redis server used by the client tests.
+// TestMain sets it.
+var redisAddr = "localhost:6379"
+
 var doerTests = []struct {
 	name string
 	doer func(ctx context.Context) (doCloser, error)
@@ -77,7 +104,7 @@ var doerTests = []struct {
 	{
 		name: "goredis",
 		doer: func(ctx context.Context) (doCloser, error) {
-			client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379"})
+			client := goredis.NewClient(&goredis.Options{Addr: redisAddr})
 			if err := client.Ping(ctx).Err(); err != nil {
 				return nil, err
 			}
@@ -87,7 +114,7 @@ var doerTests = []struct {
 	{
 		name: "redigo",
 		doer: func(ctx context.Context) (doCloser, error) {
-			conn, err := redigo.DialContext(ctx, "tcp", "localhost:6379")
+			conn, err := redigo.DialContext(ctx, "tcp", redisAddr)
 			if err != nil {
 				return nil, err
 			}
@@ -104,7 +131,7 @@ var doerTests = []struct {
 					return opts
 				},
 			}
-			client, err := (radix.PoolConfig{Dialer: d}).New(ctx, "tcp", "localhost:6379")
+			client, err := (radix.PoolConfig{Dialer: d}).New(ctx, "tcp", redisAddr)
 			if err != nil {
 				return nil, err
 			}
@@ -114,13 +141,25 @@ var doerTests = []struct {
 	{
 		name: "redispipe",
 		doer: func(ctx context.Context) (doCloser, error) {
-			sender, err := redisconn.Connect(ctx, "localhost:6379", redisconn.Opts{})
+			sender, err := redisconn.Connect(ctx, redisAddr, redisconn.Opts{})
 			if err != nil {
 				return nil, err
 			}
 			return redispipeDoer{sender}, nil
 		},
 	},
+	{
+		name: "native",
+		doer: func(ctx context.Context) (doCloser, error) {
+			return Dial(ctx, "redis://"+redisAddr)
+		},
+	},
+	{
+		name: "native resp3",
+		doer: func(ctx context.Context) (doCloser, error) {
+			return Dial(ctx, "redis://"+redisAddr+"?protocol=3")
+		},
+	},
 }
 
 func TestNewClient(t *testing.T) {
@@ -148,3 +187,54 @@ func TestNewClient(t *testing.T) {
 		})
 	}
 }
+
+type rawCmd struct {
+	name string
+	args []interface{}
+}
+
+func (c rawCmd) Name() string {
+	return c.name
+}
+
+func (c rawCmd) Args() []interface{} {
+	return c.args
+}
+
+func TestClient_DoCmd(t *testing.T) {
+	d := &replyDoer{replies: []interface{}{
+		[]interface{}{[]interface{}{int64(1000), "0.5"}},
+		int64(2000),
+		"PONG",
+	}}
+	tsclient := NewClient(d)
+	got, err := tsclient.DoCmd(context.Background(), NewCmdRange("key:any", TSMin(), TSMax(), RangerWithCount(1)))
+	if err != nil {
+		t.Fatalf("DoCmd() error = %v", err)
+	}
+	if want := []DataPoint{{time.UnixMilli(1000), 0.5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DoCmd() got = %v, want %v", got, want)
+	}
+	got, err = tsclient.DoCmd(context.Background(), NewCmdIncrBy("key:any", 0.5))
+	if err != nil {
+		t.Fatalf("DoCmd() error = %v", err)
+	}
+	if want := time.UnixMilli(2000); got != want {
+		t.Errorf("DoCmd() got = %v, want %v", got, want)
+	}
+	got, err = tsclient.DoCmd(context.Background(), rawCmd{name: "PING"})
+	if err != nil {
+		t.Fatalf("DoCmd() error = %v", err)
+	}
+	if want := "PONG"; got != want {
+		t.Errorf("DoCmd() got = %v, want %v", got, want)
+	}
+	wantCmds := []PipelineCmd{
+		{Name: "TS.RANGE", Args: []interface{}{"key:any", "-", "+", "COUNT", int64(1)}},
+		{Name: "TS.INCRBY", Args: []interface{}{"key:any", 0.5}},
+		{Name: "PING"},
+	}
+	if got := d.cmds; !reflect.DeepEqual(got, wantCmds) {
+		t.Errorf("cmds = %v, want %v", got, wantCmds)
+	}
+}
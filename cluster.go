@@ -0,0 +1,362 @@
+package redists
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClusterDoer is an optional extension of Doer for Redis Cluster. Do sends a
+// command to the node which serves the slot of its key.
+//
+// When the Doer of a Client is a ClusterDoer, the Client runs in cluster
+// mode: MRange, MRevRange, MGet and QueryIndex are sent to every primary and
+// the replies are merged, and MAdd is split into a TS.MADD per hash slot.
+type ClusterDoer interface {
+	Doer
+	// Primaries returns a Doer for each primary of the cluster.
+	Primaries(ctx context.Context) ([]Doer, error)
+}
+
+// mAddDoer is implemented by ClusterDoers which split a TS.MADD across their
+// primaries themselves, e.g. the Doer of ShardedClient. Client sends the
+// samples to them in a single TS.MADD instead of one for each hash slot.
+type mAddDoer interface {
+	ClusterDoer
+	mAdd(ctx context.Context, cmd string, args []interface{}) (interface{}, error)
+}
+
+// HashSlot returns the Redis Cluster hash slot of a key. When the key
+// contains a hash tag (e.g. "{sensor:1}:temp"), only the tag is hashed.
+func HashSlot(key string) int {
+	return int(crc16(hashTag(key)) % 16384)
+}
+
+// hashTag returns the part of the key which is hashed: the content of the
+// first non-empty {...} section, or the whole key.
+func hashTag(key string) string {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			return key[i+1 : i+1+j]
+		}
+	}
+	return key
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// parallel calls f with 0 <= i < n concurrently and waits for the calls.
+func parallel(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// fanOut sends cmd to every primary of the cluster and returns the replies.
+func (c *Client) fanOut(ctx context.Context, cd ClusterDoer, cmd Cmd) ([]interface{}, error) {
+	ds, err := cd.Primaries(ctx)
+	if err != nil {
+		return nil, wrapError(cmd, err)
+	}
+	name, args := cmd.Name(), cmd.Args()
+	res := make([]interface{}, len(ds))
+	errs := make([]error, len(ds))
+	parallel(len(ds), func(i int) {
+		res[i], errs[i] = c.hooks.do(ctx, ds[i], name, args)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, wrapError(cmd, err)
+		}
+	}
+	return res, nil
+}
+
+// clusterMRanger sends cmd to every primary. The GROUPBY option is applied by
+// the client, because each primary can only group its own time-series. COUNT
+// together with GROUPBY is rejected, because each primary would apply it
+// before the reduce.
+func (c *Client) clusterMRanger(ctx context.Context, cd ClusterDoer, cmd *CmdMRanger) ([]TimeSeries, error) {
+	shardCmd := *cmd
+	if cmd.groupBy != nil {
+		if cmd.count != nil {
+			return nil, wrapError(cmd, fmt.Errorf("%w: COUNT with GROUPBY in cluster mode", ErrInvalidArgument))
+		}
+		shardCmd.groupBy = nil
+		shardCmd.withLabels = withLabel(cmd.withLabels, cmd.groupBy.Label)
+	}
+	res, err := c.fanOut(ctx, cd, &shardCmd)
+	if err != nil {
+		return nil, err
+	}
+	var ds []TimeSeries
+	for i := range res {
+		d, err := parseTimeSeriesList(res[i])
+		if err != nil {
+			return nil, wrapError(cmd, err)
+		}
+		ds = append(ds, d...)
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].Key < ds[j].Key
+	})
+	if cmd.groupBy == nil {
+		return ds, nil
+	}
+	ds, err = groupTimeSeries(ds, *cmd.groupBy, cmd.name == nameMRevRange)
+	return ds, wrapError(cmd, err)
+}
+
+// withLabel returns the label selection of ls which includes label.
+func withLabel(ls []string, label string) []string {
+	if ls != nil && len(ls) == 0 {
+		return ls
+	}
+	for _, l := range ls {
+		if l == label {
+			return ls
+		}
+	}
+	return append(append([]string{}, ls...), label)
+}
+
+// clientReducers implement the reducers of GROUPBY for cluster mode.
+var clientReducers = map[ReducerType]func(vs []float64) float64{
+	ReducerSum: sumOf,
+	ReducerMin: minOf,
+	ReducerMax: maxOf,
+	"AVG":      meanOf,
+	"RANGE": func(vs []float64) float64 {
+		return maxOf(vs) - minOf(vs)
+	},
+	"COUNT": func(vs []float64) float64 {
+		return float64(len(vs))
+	},
+	"STD.P": func(vs []float64) float64 {
+		return math.Sqrt(variance(vs, 0))
+	},
+	"STD.S": func(vs []float64) float64 {
+		return math.Sqrt(variance(vs, 1))
+	},
+	"VAR.P": func(vs []float64) float64 {
+		return variance(vs, 0)
+	},
+	"VAR.S": func(vs []float64) float64 {
+		return variance(vs, 1)
+	},
+}
+
+func sumOf(vs []float64) float64 {
+	var s float64
+	for _, v := range vs {
+		s += v
+	}
+	return s
+}
+
+func minOf(vs []float64) float64 {
+	m := vs[0]
+	for _, v := range vs[1:] {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+func maxOf(vs []float64) float64 {
+	m := vs[0]
+	for _, v := range vs[1:] {
+		m = math.Max(m, v)
+	}
+	return m
+}
+
+func meanOf(vs []float64) float64 {
+	return sumOf(vs) / float64(len(vs))
+}
+
+// variance returns the variance of vs with ddof delta degrees of freedom.
+func variance(vs []float64, ddof int) float64 {
+	if len(vs) <= ddof {
+		return 0
+	}
+	m := meanOf(vs)
+	var s float64
+	for _, v := range vs {
+		s += (v - m) * (v - m)
+	}
+	return s / float64(len(vs)-ddof)
+}
+
+// groupTimeSeries groups ds by the value of the label and reduces the data
+// points with the same timestamp in each group, like GROUPBY ... REDUCE.
+// Time-series without the label are ignored.
+func groupTimeSeries(ds []TimeSeries, g GroupBy, rev bool) ([]TimeSeries, error) {
+	reduce, ok := clientReducers[ReducerType(strings.ToUpper(string(g.Reducer)))]
+	if !ok {
+		return nil, fmt.Errorf("redists: unknown reducer %q", g.Reducer)
+	}
+	groups := map[string][]TimeSeries{}
+	var values []string
+	for _, d := range ds {
+		v := d.Labels[g.Label]
+		if v == "" {
+			continue
+		}
+		if _, ok := groups[v]; !ok {
+			values = append(values, v)
+		}
+		groups[v] = append(groups[v], d)
+	}
+	sort.Strings(values)
+	res := make([]TimeSeries, len(values))
+	for i, v := range values {
+		byTS := map[int64][]float64{}
+		var tss []int64
+		keys := make([]string, len(groups[v]))
+		for j, d := range groups[v] {
+			keys[j] = d.Key
+			for _, p := range d.DataPoints {
+				ts := p.Timestamp.UnixMilli()
+				if _, ok := byTS[ts]; !ok {
+					tss = append(tss, ts)
+				}
+				byTS[ts] = append(byTS[ts], p.Value)
+			}
+		}
+		sort.Slice(tss, func(i, j int) bool {
+			if rev {
+				return tss[i] > tss[j]
+			}
+			return tss[i] < tss[j]
+		})
+		dps := make([]DataPoint, len(tss))
+		for j, ts := range tss {
+			dps[j] = DataPoint{Timestamp: time.UnixMilli(ts), Value: reduce(byTS[ts])}
+		}
+		res[i] = TimeSeries{
+			Key: g.Label + "=" + v,
+			Labels: Labels{
+				g.Label:       v,
+				"__reducer__": strings.ToLower(string(g.Reducer)),
+				"__source__":  strings.Join(keys, ","),
+			},
+			DataPoints: dps,
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) clusterMGet(ctx context.Context, cd ClusterDoer, cmd *CmdMGet) ([]LastDatapoint, error) {
+	res, err := c.fanOut(ctx, cd, cmd)
+	if err != nil {
+		return nil, err
+	}
+	var ds []LastDatapoint
+	for i := range res {
+		d, err := parseLastDatapoints(res[i])
+		if err != nil {
+			return nil, wrapError(cmd, err)
+		}
+		ds = append(ds, d...)
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].Key < ds[j].Key
+	})
+	return ds, nil
+}
+
+func (c *Client) clusterQueryIndex(ctx context.Context, cd ClusterDoer, cmd *CmdQueryIndex) ([]string, error) {
+	res, err := c.fanOut(ctx, cd, cmd)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for i := range res {
+		k, err := parseKeys(res[i])
+		if err != nil {
+			return nil, wrapError(cmd, err)
+		}
+		keys = append(keys, k...)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// clusterMAdd sends a TS.MADD for each hash slot of the samples, and it maps
+// the results back to the order of the samples. When the TS.MADD of a slot
+// fails, its samples fail with the error. The error is only returned when
+// every TS.MADD failed.
+func (c *Client) clusterMAdd(ctx context.Context, cmd *CmdMAdd) ([]MultiResult, error) {
+	bySlot := map[int][]int{}
+	var slots []int
+	for i, s := range cmd.samples {
+		slot := HashSlot(s.Key)
+		if _, ok := bySlot[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		bySlot[slot] = append(bySlot[slot], i)
+	}
+	rs := make([]MultiResult, len(cmd.samples))
+	errs := make([]error, len(slots))
+	parallel(len(slots), func(i int) {
+		idx := bySlot[slots[i]]
+		ss := make([]Sample, len(idx))
+		for j := range idx {
+			ss[j] = cmd.samples[idx[j]]
+		}
+		sub := NewCmdMAdd(ss)
+		res, err := c.do(ctx, sub)
+		var srs []MultiResult
+		if err == nil {
+			if srs, err = parseMultiResults(res); err != nil {
+				err = wrapError(sub, err)
+			} else if len(srs) != len(idx) {
+				err = wrapError(sub, fmt.Errorf("redists: got %d results for %d samples", len(srs), len(idx)))
+			}
+		}
+		if err != nil {
+			errs[i] = err
+			for _, j := range idx {
+				rs[j] = MultiResult{err: err}
+			}
+			return
+		}
+		wrapMultiResults(sub, srs)
+		for j := range idx {
+			rs[idx[j]] = srs[j]
+		}
+	})
+	for _, err := range errs {
+		if err == nil {
+			return rs, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return rs, nil
+}
@@ -0,0 +1,154 @@
+package redists_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/redistest"
+)
+
+// testCluster routes the commands to its shards by hash slot, and it rejects
+// TS.MADD with keys in different slots like Redis Cluster.
+type testCluster struct {
+	shards []redists.Doer
+}
+
+func newTestCluster(n int) *testCluster {
+	c := &testCluster{}
+	for i := 0; i < n; i++ {
+		c.shards = append(c.shards, redistest.NewDoer())
+	}
+	return c
+}
+
+func (c *testCluster) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	key, _ := args[0].(string)
+	slot := redists.HashSlot(key)
+	if cmd == "TS.MADD" {
+		for i := 0; i < len(args); i += 3 {
+			if redists.HashSlot(args[i].(string)) != slot {
+				return nil, redistest.Error("CROSSSLOT Keys in request don't hash to the same slot")
+			}
+		}
+	}
+	return c.shards[slot%len(c.shards)].Do(ctx, cmd, args...)
+}
+
+func (c *testCluster) Primaries(context.Context) ([]redists.Doer, error) {
+	return c.shards, nil
+}
+
+func TestHashSlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"123456789", 12739},
+		{"foo", 12182},
+		{"{user1000}.following", redists.HashSlot("user1000")},
+		{"foo{}{bar}", redists.HashSlot("foo{}{bar}")},
+		{"foo{{bar}}", redists.HashSlot("{bar")},
+	}
+	for _, tt := range tests {
+		if got := redists.HashSlot(tt.key); got != tt.want {
+			t.Errorf("HashSlot(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestClient_cluster(t *testing.T) {
+	ctx := context.Background()
+	cluster := newTestCluster(3)
+	single := redistest.NewDoer()
+	cc, sc := redists.NewClient(cluster), redists.NewClient(single)
+
+	var samples []redists.Sample
+	for i, key := range []string{"cpu:a", "cpu:b", "cpu:c", "cpu:d", "mem:a", "mem:b"} {
+		labels := redists.Labels{"metric": key[:3], "host": key[4:]}
+		for _, c := range []*redists.Client{cc, sc} {
+			if err := c.Create(ctx, key, redists.CreateWithLabels(labels)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for j := 0; j < 3; j++ {
+			samples = append(samples, redists.NewSample(key, time.UnixMilli(int64(1000*(j+1))), float64(i+j)))
+		}
+	}
+	// a sample which fails in the middle of the batch
+	samples = append(samples[:4], append([]redists.Sample{redists.NewSample("cpu:a", time.UnixMilli(1000), 1)}, samples[4:]...)...)
+
+	rs, err := cc.MAdd(ctx, samples)
+	if err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	if _, err := sc.MAdd(ctx, samples); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range rs {
+		if i == 4 {
+			if !errors.Is(r.Err(), redists.ErrDuplicateBlocked) {
+				t.Errorf("MAdd()[%d] error = %v, want %v", i, r.Err(), redists.ErrDuplicateBlocked)
+			}
+			continue
+		}
+		if r.Err() != nil || !r.Time().Equal(samples[i].Timestamp.(time.Time)) {
+			t.Errorf("MAdd()[%d] = %v, %v, want %v", i, r.Time(), r.Err(), samples[i].Timestamp)
+		}
+	}
+
+	filters := []redists.Filter{redists.FilterEqual("metric", "cpu", "mem")}
+	for _, q := range []struct {
+		name string
+		f    func(c *redists.Client) (interface{}, error)
+	}{
+		{"QueryIndex", func(c *redists.Client) (interface{}, error) {
+			return c.QueryIndex(ctx, filters)
+		}},
+		{"MGet", func(c *redists.Client) (interface{}, error) {
+			return c.MGet(ctx, filters, redists.MGetWithLabels())
+		}},
+		{"MRange", func(c *redists.Client) (interface{}, error) {
+			return c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithLabels("host"))
+		}},
+		{"MRange GROUPBY", func(c *redists.Client) (interface{}, error) {
+			return c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithGroupBy("metric", redists.ReducerSum))
+		}},
+		{"MRevRange GROUPBY", func(c *redists.Client) (interface{}, error) {
+			return c.MRevRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithGroupBy("host", redists.ReducerMax))
+		}},
+		{"MRange GROUPBY AVG", func(c *redists.Client) (interface{}, error) {
+			return c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithGroupBy("host", "AVG"))
+		}},
+		{"MRange WITHLABELS GROUPBY", func(c *redists.Client) (interface{}, error) {
+			return c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithLabels(), redists.MRangerWithGroupBy("metric", redists.ReducerSum))
+		}},
+		{"MRange SELECTED_LABELS GROUPBY", func(c *redists.Client) (interface{}, error) {
+			return c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithLabels("host"), redists.MRangerWithGroupBy("metric", redists.ReducerMax))
+		}},
+	} {
+		t.Run(q.name, func(t *testing.T) {
+			got, err := q.f(cc)
+			if err != nil {
+				t.Fatalf("%s() error = %v", q.name, err)
+			}
+			want, err := q.f(sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s() got = %v, want %v", q.name, got, want)
+			}
+		})
+	}
+
+	t.Run("MRange COUNT GROUPBY", func(t *testing.T) {
+		_, err := cc.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithCount(1), redists.MRangerWithGroupBy("metric", redists.ReducerSum))
+		if !errors.Is(err, redists.ErrInvalidArgument) {
+			t.Errorf("MRange() error = %v, want %v", err, redists.ErrInvalidArgument)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package redists
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// Columns holds data points column by column. Timestamps are Unix time in
+// milliseconds like in the replies of the server, and Values[i] is the value
+// at Timestamps[i]. It needs 16 bytes per data point, and the columns can be
+// passed to numeric code as they are.
+type Columns struct {
+	Timestamps []int64
+	Values     []float64
+}
+
+// ColumnsFromDataPoints returns the columns of ds. Converting the data points
+// of a reply back and forth is lossless, because their timestamps have
+// millisecond precision.
+func ColumnsFromDataPoints(ds []DataPoint) Columns {
+	c := Columns{
+		Timestamps: make([]int64, len(ds)),
+		Values:     make([]float64, len(ds)),
+	}
+	for i := range ds {
+		c.Timestamps[i] = ds[i].Timestamp.UnixMilli()
+		c.Values[i] = ds[i].Value
+	}
+	return c
+}
+
+// Len returns the number of data points.
+func (c Columns) Len() int {
+	return len(c.Timestamps)
+}
+
+// DataPoint returns the i-th data point.
+func (c Columns) DataPoint(i int) DataPoint {
+	return DataPoint{Timestamp: time.UnixMilli(c.Timestamps[i]), Value: c.Values[i]}
+}
+
+// DataPoints returns the data points like Range does.
+func (c Columns) DataPoints() []DataPoint {
+	ds := make([]DataPoint, c.Len())
+	for i := range ds {
+		ds[i] = c.DataPoint(i)
+	}
+	return ds
+}
+
+// Slice returns the data points from i to j without copying them. The
+// capacity of the columns is limited, so appending to them copies.
+func (c Columns) Slice(i, j int) Columns {
+	return Columns{
+		Timestamps: c.Timestamps[i:j:j],
+		Values:     c.Values[i:j:j],
+	}
+}
+
+// Between returns the data points with a timestamp from from to to, both
+// inclusive, without copying them. TSMin and TSMax leave the window open. The
+// timestamps must be in ascending or descending order, like the replies of
+// Range and RevRange.
+func (c Columns) Between(from Timestamp, to Timestamp) Columns {
+	f, t := from.UnixMilli(), to.UnixMilli()
+	if v, ok := from.(TimestampMin); ok && v.Min() {
+		f = math.MinInt64
+	}
+	if v, ok := to.(TimestampMax); ok && v.Max() {
+		t = math.MaxInt64
+	}
+	ts := c.Timestamps
+	n := len(ts)
+	if n > 1 && ts[0] > ts[n-1] {
+		i := sort.Search(n, func(i int) bool { return ts[i] <= t })
+		j := sort.Search(n, func(i int) bool { return ts[i] < f })
+		if j < i {
+			j = i
+		}
+		return c.Slice(i, j)
+	}
+	i := sort.Search(n, func(i int) bool { return ts[i] >= f })
+	j := sort.Search(n, func(i int) bool { return ts[i] > t })
+	if j < i {
+		j = i
+	}
+	return c.Slice(i, j)
+}
+
+// appendReply appends a data point of a reply.
+func (c *Columns) appendReply(val interface{}) error {
+	ts, v, err := parseRawDataPoint(val)
+	if err != nil {
+		return err
+	}
+	c.Timestamps = append(c.Timestamps, ts)
+	c.Values = append(c.Values, v)
+	return nil
+}
+
+func parseColumns(res interface{}) (Columns, error) {
+	is, err := parseArray("DataPoints", res)
+	if err != nil {
+		return Columns{}, err
+	}
+	c := Columns{
+		Timestamps: make([]int64, 0, len(is)),
+		Values:     make([]float64, 0, len(is)),
+	}
+	for i := range is {
+		if err := c.appendReply(is[i]); err != nil {
+			return Columns{}, err
+		}
+	}
+	return c, nil
+}
+
+// RangeColumns queries a range in forward direction like Range, and returns
+// the data points as Columns.
+func (c *Client) RangeColumns(ctx context.Context, key string, from Timestamp, to Timestamp, options ...OptionRanger) (Columns, error) {
+	return c.rangerColumns(ctx, NewCmdRange(key, from, to, options...))
+}
+
+// RevRangeColumns queries a range in reverse direction like RevRange, and
+// returns the data points as Columns.
+func (c *Client) RevRangeColumns(ctx context.Context, key string, from Timestamp, to Timestamp, options ...OptionRanger) (Columns, error) {
+	return c.rangerColumns(ctx, NewCmdRevRange(key, from, to, options...))
+}
+
+func (c *Client) rangerColumns(ctx context.Context, cmd *CmdRanger) (Columns, error) {
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return Columns{}, err
+	}
+	cs, err := parseColumns(res)
+	return cs, wrapError(cmd, err)
+}
+
+// TimeSeriesColumns is a TimeSeries with the data points as Columns.
+type TimeSeriesColumns struct {
+	Key    string
+	Labels Labels
+	Columns
+}
+
+func parseTimeSeriesColumns(key, val interface{}) (TimeSeriesColumns, error) {
+	is, err := timeSeriesTuple(key, val)
+	if err != nil {
+		return TimeSeriesColumns{}, err
+	}
+	k, err := parseString("TimeSeries.Key", is[0])
+	if err != nil {
+		return TimeSeriesColumns{}, err
+	}
+	ls, err := parseLabels("TimeSeries.Labels", is[1])
+	if err != nil {
+		return TimeSeriesColumns{}, err
+	}
+	cs, err := parseColumns(is[2])
+	if err != nil {
+		return TimeSeriesColumns{}, err
+	}
+	return TimeSeriesColumns{Key: k, Labels: ls, Columns: cs}, nil
+}
+
+// MRangeColumns queries a range across multiple time-series like MRange, and
+// returns the data points of each time-series as Columns. The reply is
+// decoded like with MRangeFunc, so the data points are never in memory as
+// []DataPoint, except with a ClusterDoer.
+func (c *Client) MRangeColumns(ctx context.Context, from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) ([]TimeSeriesColumns, error) {
+	return c.mRangerColumns(ctx, NewCmdMRange(from, to, filters, options...))
+}
+
+// MRevRangeColumns queries a range across multiple time-series like
+// MRevRange, and returns the data points of each time-series as Columns.
+func (c *Client) MRevRangeColumns(ctx context.Context, from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) ([]TimeSeriesColumns, error) {
+	return c.mRangerColumns(ctx, NewCmdMRevRange(from, to, filters, options...))
+}
+
+func (c *Client) mRangerColumns(ctx context.Context, cmd *CmdMRanger) ([]TimeSeriesColumns, error) {
+	if cd, ok := c.d.(ClusterDoer); ok {
+		ds, err := c.clusterMRanger(ctx, cd, cmd)
+		if err != nil {
+			return nil, err
+		}
+		tss := make([]TimeSeriesColumns, len(ds))
+		for i := range ds {
+			tss[i] = TimeSeriesColumns{Key: ds[i].Key, Labels: ds[i].Labels, Columns: ColumnsFromDataPoints(ds[i].DataPoints)}
+		}
+		return tss, nil
+	}
+	tss := []TimeSeriesColumns{}
+	err := c.stream(ctx, cmd, "TimeSeries", func(key, val interface{}) error {
+		ts, err := parseTimeSeriesColumns(key, val)
+		if err != nil {
+			return wrapError(cmd, err)
+		}
+		tss = append(tss, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tss, nil
+}
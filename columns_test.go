@@ -0,0 +1,142 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColumns(t *testing.T) {
+	ds := []DataPoint{
+		{time.UnixMilli(1000), 1},
+		{time.UnixMilli(2000), 2.5},
+		{time.UnixMilli(3000), -3},
+		{time.UnixMilli(4000), 4},
+	}
+	c := ColumnsFromDataPoints(ds)
+	want := Columns{Timestamps: []int64{1000, 2000, 3000, 4000}, Values: []float64{1, 2.5, -3, 4}}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("ColumnsFromDataPoints() = %v, want %v", c, want)
+	}
+	if got := c.DataPoints(); !reflect.DeepEqual(got, ds) {
+		t.Errorf("DataPoints() = %v, want %v", got, ds)
+	}
+	if got := c.DataPoint(2); got != ds[2] {
+		t.Errorf("DataPoint() = %v, want %v", got, ds[2])
+	}
+
+	s := c.Slice(1, 3)
+	if got := s.DataPoints(); !reflect.DeepEqual(got, ds[1:3]) {
+		t.Errorf("Slice() = %v, want %v", got, ds[1:3])
+	}
+	s.Values[0] = 20
+	if c.Values[1] != 20 {
+		t.Errorf("Slice() copied the values")
+	}
+	s.Values = append(s.Values, 30)
+	if c.Values[3] != 4 {
+		t.Errorf("append() to Slice() modified the columns")
+	}
+
+	rev := Columns{Timestamps: []int64{4000, 3000, 2000, 1000}, Values: []float64{4, 3, 2, 1}}
+	tests := []struct {
+		name     string
+		c        Columns
+		from, to Timestamp
+		want     []int64
+	}{
+		{name: "inner", c: c, from: time.UnixMilli(2000), to: time.UnixMilli(3000), want: []int64{2000, 3000}},
+		{name: "between samples", c: c, from: time.UnixMilli(1500), to: time.UnixMilli(3500), want: []int64{2000, 3000}},
+		{name: "open", c: c, from: TSMin(), to: TSMax(), want: []int64{1000, 2000, 3000, 4000}},
+		{name: "empty", c: c, from: time.UnixMilli(5000), to: time.UnixMilli(6000), want: []int64{}},
+		{name: "inverted", c: c, from: time.UnixMilli(3000), to: time.UnixMilli(2000), want: []int64{}},
+		{name: "reverse", c: rev, from: time.UnixMilli(2000), to: time.UnixMilli(3000), want: []int64{3000, 2000}},
+		{name: "reverse open", c: rev, from: TSMin(), to: time.UnixMilli(1000), want: []int64{1000}},
+		{name: "nil", c: Columns{}, from: TSMin(), to: TSMax(), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.c.Between(tt.from, tt.to)
+			if !reflect.DeepEqual(got.Timestamps, tt.want) || got.Len() != len(got.Values) {
+				t.Errorf("Between() = %v, want timestamps %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_RangeColumns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip client test")
+	}
+	for _, tt := range doerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("example:%s", t.Name())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			doer, err := tt.doer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doer.Close()
+			defer doer.Do(context.Background(), "DEL", key)
+
+			tsclient := NewClient(doer)
+			if err = tsclient.Create(ctx, key, CreateWithLabels(Labels{"test": t.Name()})); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			_, err = tsclient.MAdd(ctx, []Sample{
+				NewSample(key, secondMillennium, 1),
+				NewSample(key, thirdMillennium, 2.5),
+			})
+			if err != nil {
+				t.Fatalf("MAdd() error = %v", err)
+			}
+			for _, rev := range []bool{false, true} {
+				rangeFunc, rangeColumns := tsclient.Range, tsclient.RangeColumns
+				if rev {
+					rangeFunc, rangeColumns = tsclient.RevRange, tsclient.RevRangeColumns
+				}
+				want, err := rangeFunc(ctx, key, TSMin(), TSMax())
+				if err != nil {
+					t.Fatalf("Range() error = %v", err)
+				}
+				got, err := rangeColumns(ctx, key, TSMin(), TSMax())
+				if err != nil {
+					t.Errorf("RangeColumns() error = %v", err)
+				}
+				if len(want) != 2 || !reflect.DeepEqual(got.DataPoints(), want) || !reflect.DeepEqual(got, ColumnsFromDataPoints(want)) {
+					t.Errorf("RangeColumns() rev = %v, got = %v, want %v", rev, got, want)
+				}
+			}
+
+			ts, err := tsclient.MRangeColumns(ctx, TSMin(), TSMax(), []Filter{FilterEqual("test", t.Name())}, MRangerWithLabels())
+			if err != nil {
+				t.Errorf("MRangeColumns() error = %v", err)
+			}
+			want := []TimeSeriesColumns{{
+				Key:     key,
+				Labels:  Labels{"test": t.Name()},
+				Columns: Columns{Timestamps: []int64{secondMillennium.UnixMilli(), thirdMillennium.UnixMilli()}, Values: []float64{1, 2.5}},
+			}}
+			if !reflect.DeepEqual(ts, want) {
+				t.Errorf("MRangeColumns() got = %v, want %v", ts, want)
+			}
+			ts, err = tsclient.MRevRangeColumns(ctx, TSMin(), TSMax(), []Filter{FilterEqual("test", t.Name())})
+			if err != nil {
+				t.Errorf("MRevRangeColumns() error = %v", err)
+			}
+			if len(ts) != 1 || !reflect.DeepEqual(ts[0].Timestamps, []int64{thirdMillennium.UnixMilli(), secondMillennium.UnixMilli()}) {
+				t.Errorf("MRevRangeColumns() got = %v", ts)
+			}
+
+			if _, err := tsclient.RangeColumns(ctx, key+":missing", TSMin(), TSMax()); !errors.Is(err, ErrKeyNotExist) {
+				t.Errorf("RangeColumns() error = %v, want %v", err, ErrKeyNotExist)
+			}
+		})
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 )
 
-type cmdCreate struct {
+// CmdCreate is the TS.CREATE command.
+type CmdCreate struct {
 	key             string
 	retention       Duration
 	encoding        *Encoding
@@ -13,15 +14,24 @@ type cmdCreate struct {
 	labels          map[string]string
 }
 
-func newCmdCreate(key string) *cmdCreate {
-	return &cmdCreate{key: key}
+func newCmdCreate(key string) *CmdCreate {
+	return &CmdCreate{key: key}
 }
 
-func (c *cmdCreate) Name() string {
+// NewCmdCreate returns a TS.CREATE command with the given options applied.
+func NewCmdCreate(key string, options ...OptionCreate) *CmdCreate {
+	cmd := newCmdCreate(key)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdCreate) Name() string {
 	return "TS.CREATE"
 }
 
-func (c *cmdCreate) Args() []interface{} {
+func (c *CmdCreate) Args() []interface{} {
 	args := []interface{}{c.key}
 	if c.retention != nil {
 		args = append(args, optionNameRetention, c.retention.Milliseconds())
@@ -42,44 +52,41 @@ func (c *cmdCreate) Args() []interface{} {
 	return args
 }
 
-type OptionCreate func(cmd *cmdCreate)
+type OptionCreate func(cmd *CmdCreate)
 
 // Create creates a new time-series.
 func (c *Client) Create(ctx context.Context, key string, options ...OptionCreate) error {
-	cmd := newCmdCreate(key)
-	for i := range options {
-		options[i](cmd)
-	}
-	_, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdCreate(key, options...)
+	_, err := c.do(ctx, cmd)
 	return err
 }
 
 func CreateWithRetention(r Duration) OptionCreate {
-	return func(cmd *cmdCreate) {
+	return func(cmd *CmdCreate) {
 		cmd.retention = r
 	}
 }
 
 func CreateWithEncoding(e Encoding) OptionCreate {
-	return func(cmd *cmdCreate) {
+	return func(cmd *CmdCreate) {
 		cmd.encoding = &e
 	}
 }
 
 func CreateWithChunkSize(cs int) OptionCreate {
-	return func(cmd *cmdCreate) {
+	return func(cmd *CmdCreate) {
 		cmd.chunkSize = &cs
 	}
 }
 
 func CreateWithDuplicatePolicy(dp DuplicatePolicy) OptionCreate {
-	return func(cmd *cmdCreate) {
+	return func(cmd *CmdCreate) {
 		cmd.duplicatePolicy = &dp
 	}
 }
 
 func CreateWithLabels(ls Labels) OptionCreate {
-	return func(cmd *cmdCreate) {
+	return func(cmd *CmdCreate) {
 		cmd.labels = ls
 	}
 }
@@ -5,30 +5,37 @@ import (
 	"time"
 )
 
-type cmdDel struct {
+// CmdDel is the TS.DEL command.
+type CmdDel struct {
 	key  string
 	from time.Time
 	to   time.Time
 }
 
-func newCmdDel(key string, from time.Time, to time.Time) *cmdDel {
-	return &cmdDel{key: key, from: from, to: to}
+func newCmdDel(key string, from time.Time, to time.Time) *CmdDel {
+	return &CmdDel{key: key, from: from, to: to}
 }
 
-func (c *cmdDel) Name() string {
+// NewCmdDel returns a TS.DEL command.
+func NewCmdDel(key string, from time.Time, to time.Time) *CmdDel {
+	return newCmdDel(key, from, to)
+}
+
+func (c *CmdDel) Name() string {
 	return "TS.DEL"
 }
 
-func (c *cmdDel) Args() []interface{} {
+func (c *CmdDel) Args() []interface{} {
 	return []interface{}{c.key, c.from.UnixMilli(), c.to.UnixMilli()}
 }
 
 // Del deletes samples between two timestamps for a given key.
 func (c *Client) Del(ctx context.Context, key string, from time.Time, to time.Time) (int64, error) {
-	cmd := newCmdDel(key, from, to)
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdDel(key, from, to)
+	res, err := c.do(ctx, cmd)
 	if err != nil {
 		return 0, err
 	}
-	return res.(int64), err
+	n, err := parseInt64("deleted", res)
+	return n, wrapError(cmd, err)
 }
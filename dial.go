@@ -0,0 +1,208 @@
+package redists
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type dialConfig struct {
+	network     string
+	addr        string
+	username    string
+	password    string
+	db          int
+	protocol    int
+	tlsConfig   *tls.Config
+	maxConns    int
+	maxIdle     int
+	healthCheck time.Duration
+	idleTimeout time.Duration
+	dialer      func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// parseURL parses the URL of Dial.
+func parseURL(rawurl string) (dialConfig, error) {
+	var cfg dialConfig
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return cfg, err
+	}
+	if u.User != nil {
+		cfg.username = u.User.Username()
+		cfg.password, _ = u.User.Password()
+	}
+	q := u.Query()
+	switch u.Scheme {
+	case "redis", "rediss":
+		cfg.network = "tcp"
+		host, port := u.Hostname(), u.Port()
+		if host == "" {
+			host = "localhost"
+		}
+		if port == "" {
+			port = "6379"
+		}
+		cfg.addr = net.JoinHostPort(host, port)
+		if u.Scheme == "rediss" {
+			cfg.tlsConfig = &tls.Config{ServerName: host}
+		}
+		if p := strings.Trim(u.Path, "/"); p != "" {
+			if cfg.db, err = strconv.Atoi(p); err != nil {
+				return cfg, fmt.Errorf("redists: invalid database %q", p)
+			}
+		}
+	case "unix":
+		cfg.network = "unix"
+		cfg.addr = u.Path
+		if cfg.addr == "" {
+			return cfg, fmt.Errorf("redists: missing socket path in %q", rawurl)
+		}
+	default:
+		return cfg, fmt.Errorf("redists: unsupported scheme %q", u.Scheme)
+	}
+	for name, vs := range q {
+		v := vs[len(vs)-1]
+		switch name {
+		case "db":
+			if cfg.db, err = strconv.Atoi(v); err != nil {
+				return cfg, fmt.Errorf("redists: invalid database %q", v)
+			}
+		case "protocol":
+			if cfg.protocol, err = strconv.Atoi(v); err != nil || cfg.protocol < 2 || cfg.protocol > 3 {
+				return cfg, fmt.Errorf("redists: invalid protocol %q", v)
+			}
+		default:
+			return cfg, fmt.Errorf("redists: unknown parameter %q", name)
+		}
+	}
+	return cfg, nil
+}
+
+type OptionDial func(cfg *dialConfig)
+
+// DialWithTLSConfig enables TLS with the given configuration, also for
+// "redis://" URLs.
+func DialWithTLSConfig(c *tls.Config) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.tlsConfig = c
+	}
+}
+
+// DialWithProtocol sets the version of RESP, which is 2 or 3.
+func DialWithProtocol(p int) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.protocol = p
+	}
+}
+
+// DialWithMaxConns limits the number of open connections. Commands wait for a
+// connection when the limit is reached. The default is 10 per CPU.
+func DialWithMaxConns(n int) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.maxConns = n
+	}
+}
+
+// DialWithMaxIdleConns limits the number of idle connections. The default is
+// the maximum number of connections.
+func DialWithMaxIdleConns(n int) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.maxIdle = n
+	}
+}
+
+// DialWithHealthCheck sends a PING on connections which were idle for longer
+// than d before they are used, and replaces them when it fails. The default
+// is one minute, and zero disables the health checks.
+func DialWithHealthCheck(d time.Duration) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.healthCheck = d
+	}
+}
+
+// DialWithIdleTimeout closes connections which were idle for longer than d.
+// The default is five minutes, and zero keeps idle connections open.
+func DialWithIdleTimeout(d time.Duration) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.idleTimeout = d
+	}
+}
+
+// DialWithDialer sets the function which opens the network connections.
+func DialWithDialer(f func(ctx context.Context, network, addr string) (net.Conn, error)) OptionDial {
+	return func(cfg *dialConfig) {
+		cfg.dialer = f
+	}
+}
+
+// Dial returns a Pool of connections to the server at rawurl, so no
+// third-party Redis client is needed. It opens a connection to check the
+// configuration.
+//
+// The URL has one of the following forms:
+//
+//	redis://[user[:password]@][host][:port][/db][?protocol=3]
+//	rediss://[user[:password]@][host][:port][/db][?protocol=3]
+//	unix://[user[:password]@]/path/to/redis.sock[?db=db&protocol=3]
+//
+// The "rediss" scheme enables TLS. With a user, the connections authenticate
+// as an ACL user. The default protocol is RESP2, and with protocol=3 the
+// connections switch to RESP3 with HELLO.
+func Dial(ctx context.Context, rawurl string, options ...OptionDial) (*Pool, error) {
+	cfg, err := parseURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.protocol == 0 {
+		cfg.protocol = 2
+	}
+	cfg.maxConns = 10 * runtime.GOMAXPROCS(0)
+	cfg.healthCheck = time.Minute
+	cfg.idleTimeout = 5 * time.Minute
+	for i := range options {
+		options[i](&cfg)
+	}
+	if cfg.maxConns <= 0 {
+		cfg.maxConns = 1
+	}
+	if cfg.maxIdle <= 0 || cfg.maxIdle > cfg.maxConns {
+		cfg.maxIdle = cfg.maxConns
+	}
+	if cfg.dialer == nil {
+		var d net.Dialer
+		cfg.dialer = d.DialContext
+	}
+	if cfg.tlsConfig != nil {
+		cfg.dialer = tlsDialer(cfg.dialer, cfg.tlsConfig)
+	}
+	p := newPool(cfg)
+	c, err := p.get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("redists: dial %s: %w", cfg.addr, err)
+	}
+	p.put(c)
+	return p, nil
+}
+
+// tlsDialer wraps the connections of dial with TLS.
+func tlsDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), c *tls.Config) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		nc, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tc := tls.Client(nc, c)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+}
@@ -4,7 +4,7 @@ support multiple Redis clients, because applications probably already use one.
 
 Creating a client with GoRedis
 
-The following example shows how to create a Doer implementation with GoRedis:
+The adapter/goredis package implements Doer with GoRedis:
 
 	package main
 
@@ -12,18 +12,9 @@ The following example shows how to create a Doer implementation with GoRedis:
 		"context"
 		goredis "github.com/go-redis/redis/v8"
 		"github.com/coding-socks/redists"
-		"time"
+		goredisdoer "github.com/coding-socks/redists/adapter/goredis"
 	)
 
-	type goredisDoer struct {
-		c *goredis.Client
-	}
-
-	func (f goredisDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
-		args = append([]interface{}{cmd}, args...)
-		return f.c.Do(ctx, args...).Result()
-	}
-
 	func main() {
 		client := goredis.NewClient(&goredis.Options{
 			Addr:     "localhost:6379", // use default Addr
@@ -35,7 +26,7 @@ The following example shows how to create a Doer implementation with GoRedis:
 		if err != nil {
 			panic(err)
 		}
-		tsclient := redists.NewClient(goredisDoer{client})
+		tsclient := redists.NewClient(goredisdoer.New(client))
 
 		v := client.Exists(context.Background(), "example:goredis").Val()
 		if v == 0 {
@@ -53,7 +44,8 @@ The following example shows how to create a Doer implementation with GoRedis:
 
 Creating a client with Redigo
 
-The following example shows how to create a Doer implementation with Redigo:
+The adapter/redigo package implements Doer with a Redigo pool, because a
+single connection is not safe for concurrent use:
 
 	package main
 
@@ -61,37 +53,28 @@ The following example shows how to create a Doer implementation with Redigo:
 		"context"
 		redigo "github.com/gomodule/redigo/redis"
 		"github.com/coding-socks/redists"
-		"time"
+		redigodoer "github.com/coding-socks/redists/adapter/redigo"
 	)
 
-	type redigoDoer struct {
-		c redigo.Conn
-	}
-
-	func (f redigoDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
-		deadline, ok := ctx.Deadline()
-		if ok {
-			return redigo.DoWithTimeout(f.c, time.Now().Sub(deadline), cmd, args...)
-		}
-		return f.c.Do(cmd, args...)
-	}
-
 	func main() {
-		conn, err := redigo.Dial("tcp", "localhost:6379")
-		if err != nil {
-			panic(err)
+		pool := &redigo.Pool{
+			DialContext: func(ctx context.Context) (redigo.Conn, error) {
+				return redigo.DialContext(ctx, "tcp", "localhost:6379")
+			},
 		}
-		defer conn.Close()
-		tsclient := redists.NewClient(redigoDoer{conn})
+		defer pool.Close()
+		tsclient := redists.NewClient(redigodoer.New(pool))
 
+		conn := pool.Get()
 		v, _ := redigo.Int(conn.Do("EXISTS", "example:redigo"))
+		conn.Close()
 		if v == 0 {
-			err = tsclient.Create(context.Background(), "example:redigo")
+			err := tsclient.Create(context.Background(), "example:redigo")
 			if err != nil {
 				panic(err)
 			}
 		}
-		_, err = tsclient.Add(context.Background(), redists.NewSample("example:redigo", redists.TSAuto(), 0.5))
+		_, err := tsclient.Add(context.Background(), redists.NewSample("example:redigo", redists.TSAuto(), 0.5))
 		if err != nil {
 			panic(err)
 		}
@@ -99,7 +82,7 @@ The following example shows how to create a Doer implementation with Redigo:
 
 Creating a client with RedisPipe
 
-The following example shows how to create a Doer implementation with RedisPipe:
+The adapter/redispipe package implements Doer with RedisPipe:
 
 	package main
 
@@ -108,28 +91,16 @@ The following example shows how to create a Doer implementation with RedisPipe:
 		redispipe "github.com/joomcode/redispipe/redis"
 		"github.com/joomcode/redispipe/redisconn"
 		"github.com/coding-socks/redists"
-		"time"
+		redispipedoer "github.com/coding-socks/redists/adapter/redispipe"
 	)
 
-	type redispipeDoer struct {
-		s redispipe.Sender
-	}
-
-	func (f redispipeDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
-		res := redispipe.SyncCtx{f.s}.Do(ctx, cmd, args...)
-		if err := redispipe.AsError(res); err != nil {
-			return nil, err
-		}
-		return res, nil
-	}
-
 	func main() {
 		sender, err := redisconn.Connect(context.Background(), "localhost:6379", redisconn.Opts{})
-		defer sender.Close()
 		if err != nil {
 			panic(err)
 		}
-		tsclient := redists.NewClient(redispipeDoer{sender})
+		defer sender.Close()
+		tsclient := redists.NewClient(redispipedoer.New(sender))
 
 		sync := redispipe.SyncCtx{sender}
 		res := sync.Do(context.Background(), "EXISTS", "example:redispipe")
@@ -147,7 +118,7 @@ The following example shows how to create a Doer implementation with RedisPipe:
 
 Creating a client with Radix
 
-The following example shows how to create a Doer implementation with Radix:
+The adapter/radix package implements Doer with Radix:
 
 	package main
 
@@ -155,36 +126,16 @@ The following example shows how to create a Doer implementation with Radix:
 		"context"
 		"github.com/mediocregopher/radix/v4"
 		"github.com/coding-socks/redists"
-		"time"
+		radixdoer "github.com/coding-socks/redists/adapter/radix"
 	)
 
-	type radixDoer struct {
-		c radix.Client
-	}
-
-	func (f radixDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
-		var val interface{}
-		err := f.c.Do(ctx, radix.FlatCmd(&val, cmd, args...))
-		if e, ok := val.(error); err == nil && ok {
-			return nil, e
-		}
-		return val, err
-	}
-
 	func main() {
-		d := radix.Dialer{
-			NewRespOpts: func() *resp.Opts {
-				opts := resp.NewOpts()
-				opts.DisableErrorBubbling = true
-				return opts
-			},
-		}
-		client, err := (radix.PoolConfig{Dialer: d}).New(context.Background(), "tcp", "localhost:6379")
+		client, err := (radix.PoolConfig{}).New(context.Background(), "tcp", "localhost:6379")
 		if err != nil {
 			panic(err)
 		}
 		defer client.Close()
-		tsclient := redists.NewClient(radixDoer{client})
+		tsclient := redists.NewClient(radixdoer.New(client))
 
 		var v int
 		client.Do(context.Background(), radix.Cmd(&v, "EXISTS", "example:radix"))
@@ -200,5 +151,8 @@ The following example shows how to create a Doer implementation with Radix:
 		}
 	}
 
+The adapters return bulk strings as string and error replies as ServerError,
+like the Pool returned by Dial, and they implement PipelineDoer.
+
 */
 package redists
@@ -5,17 +5,18 @@ import (
 	"time"
 )
 
-type cmdCreateRule struct {
+// CmdCreateRule is the TS.CREATERULE command.
+type CmdCreateRule struct {
 	srcKey, destKey string
 	agg             Aggregation
 	alignTimestamp  *time.Time
 }
 
-func (c *cmdCreateRule) Name() string {
+func (c *CmdCreateRule) Name() string {
 	return "TS.CREATERULE"
 }
 
-func (c *cmdCreateRule) Args() []interface{} {
+func (c *CmdCreateRule) Args() []interface{} {
 	args := []interface{}{c.srcKey, c.destKey, optionNameAggregation, string(c.agg.Type), c.agg.Bucket.Milliseconds()}
 	if c.alignTimestamp != nil {
 		args = append(args, c.alignTimestamp.UnixMilli())
@@ -23,46 +24,58 @@ func (c *cmdCreateRule) Args() []interface{} {
 	return args
 }
 
-func newCmdCreateRule(srcKey, destKey string, t AggregationType, bucket Duration) *cmdCreateRule {
-	return &cmdCreateRule{srcKey: srcKey, destKey: destKey, agg: Aggregation{Type: t, Bucket: bucket}}
+func newCmdCreateRule(srcKey, destKey string, t AggregationType, bucket Duration) *CmdCreateRule {
+	return &CmdCreateRule{srcKey: srcKey, destKey: destKey, agg: Aggregation{Type: t, Bucket: bucket}}
 }
 
-type OptionCreateRule func(cmd *cmdCreateRule)
-
-// CreateRule creates a compaction rule.
-func (c *Client) CreateRule(ctx context.Context, srcKey, destKey string, a AggregationType, bucket Duration, options ...OptionCreateRule) error {
-	cmd := newCmdCreateRule(srcKey, destKey, a, bucket)
+// NewCmdCreateRule returns a TS.CREATERULE command with the given options applied.
+func NewCmdCreateRule(srcKey, destKey string, t AggregationType, bucket Duration, options ...OptionCreateRule) *CmdCreateRule {
+	cmd := newCmdCreateRule(srcKey, destKey, t, bucket)
 	for i := range options {
 		options[i](cmd)
 	}
-	_, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	return cmd
+}
+
+type OptionCreateRule func(cmd *CmdCreateRule)
+
+// CreateRule creates a compaction rule.
+func (c *Client) CreateRule(ctx context.Context, srcKey, destKey string, a AggregationType, bucket Duration, options ...OptionCreateRule) error {
+	cmd := NewCmdCreateRule(srcKey, destKey, a, bucket, options...)
+	_, err := c.do(ctx, cmd)
 	return err
 }
 
 func CreateRuleWithAlignTimestamp(t time.Time) OptionCreateRule {
-	return func(cmd *cmdCreateRule) {
+	return func(cmd *CmdCreateRule) {
 		cmd.alignTimestamp = &t
 	}
 }
 
-type cmdDeleteRule struct {
+// CmdDeleteRule is the TS.DELETERULE command.
+type CmdDeleteRule struct {
 	srcKey, destKey string
 }
 
-func (c *cmdDeleteRule) Name() string {
+func (c *CmdDeleteRule) Name() string {
 	return "TS.DELETERULE"
 }
-func (c *cmdDeleteRule) Args() []interface{} {
+func (c *CmdDeleteRule) Args() []interface{} {
 	return []interface{}{c.srcKey, c.destKey}
 }
 
-func newCmdDeleteRule(srcKey, destKey string) *cmdDeleteRule {
-	return &cmdDeleteRule{srcKey: srcKey, destKey: destKey}
+func newCmdDeleteRule(srcKey, destKey string) *CmdDeleteRule {
+	return &CmdDeleteRule{srcKey: srcKey, destKey: destKey}
+}
+
+// NewCmdDeleteRule returns a TS.DELETERULE command.
+func NewCmdDeleteRule(srcKey, destKey string) *CmdDeleteRule {
+	return newCmdDeleteRule(srcKey, destKey)
 }
 
 // DeleteRule deletes a compaction rule.
 func (c *Client) DeleteRule(ctx context.Context, srcKey, destKey string) error {
-	cmd := newCmdDeleteRule(srcKey, destKey)
-	_, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdDeleteRule(srcKey, destKey)
+	_, err := c.do(ctx, cmd)
 	return err
 }
@@ -0,0 +1,126 @@
+package redists
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrKeyNotExist is returned when the key does not exist.
+	ErrKeyNotExist = errors.New("redists: key does not exist")
+	// ErrKeyExists is returned when creating a time-series with a key which already exists.
+	ErrKeyExists = errors.New("redists: key already exists")
+	// ErrWrongType is returned when the key does not hold a time-series.
+	ErrWrongType = errors.New("redists: key is not a time-series")
+	// ErrTimestampTooOld is returned when the timestamp of a sample is older than the retention of the time-series.
+	ErrTimestampTooOld = errors.New("redists: timestamp is older than retention")
+	// ErrTimestampNotLatest is returned by IncrBy and DecrBy when the timestamp is older than the latest sample.
+	ErrTimestampNotLatest = errors.New("redists: timestamp is older than the latest timestamp")
+	// ErrDuplicateBlocked is returned when a sample already exists and the duplicate policy is DuplicatePolicyBlock.
+	ErrDuplicateBlocked = errors.New("redists: duplicate sample blocked")
+	// ErrInvalidFilter is returned when the filters of MRange, MGet or QueryIndex cannot be parsed.
+	ErrInvalidFilter = errors.New("redists: invalid filter")
+	// ErrRuleExists is returned when the destination key of a compaction rule already has a rule.
+	ErrRuleExists = errors.New("redists: compaction rule already exists")
+	// ErrRuleNotExist is returned when deleting a compaction rule which does not exist.
+	ErrRuleNotExist = errors.New("redists: compaction rule does not exist")
+	// ErrInvalidArgument is returned when the server rejects an argument of the command.
+	ErrInvalidArgument = errors.New("redists: invalid argument")
+)
+
+// errorPatterns maps lower case fragments of RedisTimeSeries error messages
+// to sentinel errors. Fragments are matched anywhere in the message, because
+// some clients (e.g. redispipe) prefix server errors.
+var errorPatterns = []struct {
+	fragment string
+	err      error
+}{
+	{"the key does not exist", ErrKeyNotExist},
+	{"key already exists", ErrKeyExists},
+	{"the key is not a tsdb key", ErrWrongType},
+	{"wrongtype", ErrWrongType},
+	{"older than retention", ErrTimestampTooOld},
+	{"timestamp must be equal to or higher than the maximum existing timestamp", ErrTimestampNotLatest},
+	{"duplicate_policy is set to block", ErrDuplicateBlocked},
+	{"please provide at least one matcher", ErrInvalidFilter},
+	{"missing filter", ErrInvalidFilter},
+	{"invalid filter", ErrInvalidFilter},
+	{"compaction rule does not exist", ErrRuleNotExist},
+	{"already has a rule", ErrRuleExists},
+	{"already has a src rule", ErrRuleExists},
+	{"compaction rule already exists", ErrRuleExists},
+	{"tsdb: invalid", ErrInvalidArgument},
+	{"tsdb: wrong", ErrInvalidArgument},
+	{"tsdb: couldn't parse", ErrInvalidArgument},
+	{"tsdb: unknown", ErrInvalidArgument},
+	{"should be different", ErrInvalidArgument},
+}
+
+// filterCmds are the commands which accept filters instead of a key.
+var filterCmds = map[string]bool{
+	string(nameMRange):    true,
+	string(nameMRevRange): true,
+	"TS.MGET":             true,
+	"TS.QUERYINDEX":       true,
+}
+
+func classifyError(cmd string, err error) error {
+	msg := strings.ToLower(err.Error())
+	if filterCmds[cmd] && strings.Contains(msg, "failed parsing labels") {
+		return ErrInvalidFilter
+	}
+	for _, p := range errorPatterns {
+		if strings.Contains(msg, p.fragment) {
+			return p.err
+		}
+	}
+	return nil
+}
+
+// CmdError is returned by Client when a command fails. It wraps the error
+// returned by the Doer, and it matches one of the sentinel errors (e.g.
+// ErrKeyNotExist) with errors.Is when the server error is recognised.
+type CmdError struct {
+	// Cmd is the name of the failed command.
+	Cmd string
+	// Key is the key the command failed on. It is empty for commands which
+	// select time-series with filters.
+	Key string
+	// Err is the error returned by the Doer.
+	Err error
+}
+
+func newCmdError(cmd string, args []interface{}, err error) *CmdError {
+	e := &CmdError{Cmd: strings.ToUpper(cmd), Err: err}
+	if !filterCmds[e.Cmd] && len(args) > 0 {
+		if key, ok := args[0].(string); ok {
+			e.Key = key
+		}
+	}
+	return e
+}
+
+// wrapError wraps err in a CmdError of cmd. It returns nil when err is nil.
+func wrapError(cmd Cmd, err error) error {
+	if err == nil {
+		return nil
+	}
+	return newCmdError(cmd.Name(), cmd.Args(), err)
+}
+
+func (e *CmdError) Error() string {
+	if e.Key == "" {
+		return e.Cmd + ": " + e.Err.Error()
+	}
+	return e.Cmd + " " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *CmdError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the server error is classified as target.
+func (e *CmdError) Is(target error) bool {
+	kind := classifyError(e.Cmd, e.Err)
+	return kind != nil && kind == target
+}
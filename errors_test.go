@@ -0,0 +1,120 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type stringError string
+
+func (e stringError) Error() string {
+	return string(e)
+}
+
+func TestCmdError_Is(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		msg  string
+		want error
+	}{
+		{"TS.ADD", "ERR TSDB: the key does not exist", ErrKeyNotExist},
+		{"TS.CREATE", "ERR TSDB: key already exists", ErrKeyExists},
+		{"TS.RANGE", "WRONGTYPE Operation against a key holding the wrong kind of value", ErrWrongType},
+		{"TS.INFO", "ERR TSDB: the key is not a TSDB key", ErrWrongType},
+		{"TS.ADD", "ERR TSDB: Timestamp is older than retention", ErrTimestampTooOld},
+		{"TS.INCRBY", "ERR TSDB: timestamp must be equal to or higher than the maximum existing timestamp", ErrTimestampNotLatest},
+		{"TS.ADD", "ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode", ErrDuplicateBlocked},
+		{"TS.MRANGE", "ERR TSDB: failed parsing labels", ErrInvalidFilter},
+		{"TS.QUERYINDEX", "ERR TSDB: please provide at least one matcher", ErrInvalidFilter},
+		{"TS.DELETERULE", "ERR TSDB: compaction rule does not exist", ErrRuleNotExist},
+		{"TS.CREATERULE", "ERR TSDB: the destination key already has a rule", ErrRuleExists},
+		{"TS.ADD", "ERR TSDB: invalid value", ErrInvalidArgument},
+		{"TS.ADD", "redispipe.result: ERR TSDB: the key does not exist", ErrKeyNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := newCmdError(tt.cmd, []interface{}{"key:any"}, stringError(tt.msg))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", err, tt.want)
+			}
+		})
+	}
+	t.Run("unknown", func(t *testing.T) {
+		err := newCmdError("TS.ADD", []interface{}{"key:any"}, context.DeadlineExceeded)
+		if errors.Is(err, ErrKeyNotExist) {
+			t.Errorf("errors.Is(%v, %v) = true, want false", err, ErrKeyNotExist)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("errors.Is(%v, %v) = false, want true", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestCmdError_Error(t *testing.T) {
+	err := newCmdError("TS.ADD", []interface{}{"key:any", int64(1), 0.5}, stringError("ERR any"))
+	if got, want := err.Error(), "TS.ADD key:any: ERR any"; got != want {
+		t.Errorf("Error() = %v, want %v", got, want)
+	}
+	err = newCmdError("TS.MGET", []interface{}{"FILTER", "l=v"}, stringError("ERR any"))
+	if got, want := err.Error(), "TS.MGET: ERR any"; got != want {
+		t.Errorf("Error() = %v, want %v", got, want)
+	}
+}
+
+func TestClient_MAdd_errors(t *testing.T) {
+	d := &replyDoer{replies: []interface{}{
+		[]interface{}{int64(1000), stringError("ERR TSDB: the key does not exist")},
+	}}
+	rs, err := NewClient(d).MAdd(context.Background(), []Sample{
+		NewSample("key:any", time.UnixMilli(1000), 0.5),
+		NewSample("key:other", time.UnixMilli(1000), 0.5),
+	})
+	if err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	if err := rs[0].Err(); err != nil {
+		t.Errorf("MAdd()[0] error = %v", err)
+	}
+	var cerr *CmdError
+	if err := rs[1].Err(); !errors.As(err, &cerr) || !errors.Is(err, ErrKeyNotExist) {
+		t.Fatalf("MAdd()[1] error = %v, want %v", err, ErrKeyNotExist)
+	}
+	if got, want := cerr.Key, "key:other"; got != want {
+		t.Errorf("CmdError.Key = %v, want %v", got, want)
+	}
+}
+
+func TestClient_Create_errors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip client test")
+	}
+	for _, tt := range doerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("example:%s", t.Name())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			doer, err := tt.doer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doer.Close()
+			defer doer.Do(context.Background(), "DEL", key)
+
+			tsclient := NewClient(doer)
+			if _, err := tsclient.Info(ctx, key); !errors.Is(err, ErrKeyNotExist) {
+				t.Errorf("Info() error = %v, want %v", err, ErrKeyNotExist)
+			}
+			if err := tsclient.Create(ctx, key); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if err := tsclient.Create(ctx, key); !errors.Is(err, ErrKeyExists) {
+				t.Errorf("Create() error = %v, want %v", err, ErrKeyExists)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package redists
+
+// SetRedisAddr sets the address of the Redis server used by the client tests.
+func SetRedisAddr(addr string) {
+	redisAddr = addr
+}
@@ -2,24 +2,63 @@ package redists
 
 import (
 	"context"
-	"fmt"
-	"reflect"
-	"strconv"
 	"time"
 )
 
 type Rules map[string]Aggregation
 
-func parseRules(is []interface{}) map[string]Aggregation {
-	rs := make(map[string]Aggregation)
+func parseRules(val interface{}) (Rules, error) {
+	// RESP3 replies map the destination key to [bucket, type, ...]
+	if es, ok, err := parseMap("Info.rules", val); ok {
+		if err != nil {
+			return nil, err
+		}
+		rs := make(Rules, len(es))
+		for _, e := range es {
+			is, err := parseTuple("Info.rules", e.val, 2)
+			if err != nil {
+				return nil, err
+			}
+			if rs[e.key], err = parseRule(is[0], is[1]); err != nil {
+				return nil, err
+			}
+		}
+		return rs, nil
+	}
+	is, err := parseArray("Info.rules", val)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(Rules)
 	for _, v := range is {
-		is := v.([]interface{})
-		rs[parseString(is[0])] = Aggregation{
-			Bucket: time.Duration(is[1].(int64)) * time.Millisecond,
-			Type:   parseAggregationType(is[2]),
+		is, err := parseTuple("Info.rules", v, 3)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseString("Info.rules.key", is[0])
+		if err != nil {
+			return nil, err
+		}
+		if rs[key], err = parseRule(is[1], is[2]); err != nil {
+			return nil, err
 		}
 	}
-	return rs
+	return rs, nil
+}
+
+func parseRule(bucketVal, typeVal interface{}) (Aggregation, error) {
+	bucket, err := parseInt64("Info.rules.bucket", bucketVal)
+	if err != nil {
+		return Aggregation{}, err
+	}
+	t, err := parseAggregationType("Info.rules.type", typeVal)
+	if err != nil {
+		return Aggregation{}, err
+	}
+	return Aggregation{
+		Bucket: time.Duration(bucket) * time.Millisecond,
+		Type:   t,
+	}, nil
 }
 
 type ChunkInfo struct {
@@ -30,28 +69,42 @@ type ChunkInfo struct {
 	BytesPerSample float64
 }
 
-func parseChunkInfo(is []interface{}) ChunkInfo {
+func parseChunkInfo(val interface{}) (ChunkInfo, error) {
 	var inf ChunkInfo
+	is, err := parsePairs("ChunkInfo", val)
+	if err != nil {
+		return inf, err
+	}
 	for i := 0; i < len(is); i += 2 {
-		key := parseString(is[i])
+		key, err := parseString("ChunkInfo.key", is[i])
+		if err != nil {
+			return inf, err
+		}
 		val := is[i+1]
-		if val == nil {
+		if isNil(val) {
 			continue
 		}
+		field := "ChunkInfo." + key
+		var n int64
 		switch key {
 		case "startTimestamp":
-			inf.StartTimestamp = time.UnixMilli(val.(int64))
+			n, err = parseInt64(field, val)
+			inf.StartTimestamp = time.UnixMilli(n)
 		case "endTimestamp":
-			inf.EndTimestamp = time.UnixMilli(val.(int64))
+			n, err = parseInt64(field, val)
+			inf.EndTimestamp = time.UnixMilli(n)
 		case "samples":
-			inf.Samples = val.(int64)
+			inf.Samples, err = parseInt64(field, val)
 		case "size":
-			inf.Size = val.(int64)
+			inf.Size, err = parseInt64(field, val)
 		case "bytesPerSample":
-			inf.BytesPerSample, _ = strconv.ParseFloat(parseString(val), 64)
+			inf.BytesPerSample, err = parseFloat64(field, val)
+		}
+		if err != nil {
+			return inf, err
 		}
 	}
-	return inf
+	return inf, nil
 }
 
 type Info struct {
@@ -70,79 +123,96 @@ type Info struct {
 	Chunks          []ChunkInfo
 }
 
-func parseInfo(is []interface{}) Info {
+func parseInfo(val interface{}) (Info, error) {
 	var inf Info
+	is, err := parsePairs("Info", val)
+	if err != nil {
+		return inf, err
+	}
 	for i := 0; i < len(is); i += 2 {
-		key := parseString(is[i])
-		val := is[i+1]
-		if val == nil {
-			continue
+		key, err := parseString("Info.key", is[i])
+		if err != nil {
+			return Info{}, err
 		}
-		// some clients (e.g. radix) decode nil as []uint8(nil) instead of nil(nil)
-		if v := reflect.ValueOf(val); v.Kind() == reflect.Slice && v.IsNil() {
+		val := is[i+1]
+		if isNil(val) {
 			continue
 		}
+		field := "Info." + key
+		var n int64
 		switch key {
 		case "totalSamples":
-			inf.TotalSamples = val.(int64)
+			inf.TotalSamples, err = parseInt64(field, val)
 		case "memoryUsage":
-			inf.MemoryUsage = val.(int64)
+			inf.MemoryUsage, err = parseInt64(field, val)
 		case "firstTimestamp":
-			inf.FirstTimestamp = time.UnixMilli(val.(int64))
+			n, err = parseInt64(field, val)
+			inf.FirstTimestamp = time.UnixMilli(n)
 		case "lastTimestamp":
-			inf.LastTimestamp = time.UnixMilli(val.(int64))
+			n, err = parseInt64(field, val)
+			inf.LastTimestamp = time.UnixMilli(n)
 		case "retentionTime":
-			inf.RetentionTime = time.Duration(val.(int64)) * time.Millisecond
+			n, err = parseInt64(field, val)
+			inf.RetentionTime = time.Duration(n) * time.Millisecond
 		case "chunkCount":
-			inf.ChunkCount = val.(int64)
+			inf.ChunkCount, err = parseInt64(field, val)
 		case "chunkSize":
-			inf.ChunkSize = val.(int64)
+			inf.ChunkSize, err = parseInt64(field, val)
 		case "chunkType":
-			inf.ChunkType = parseEncoding(val)
+			inf.ChunkType, err = parseEncoding(field, val)
 		case "duplicatePolicy":
-			policy := parseDuplicatePolicy(val)
+			var policy DuplicatePolicy
+			policy, err = parseDuplicatePolicy(field, val)
 			inf.DuplicatePolicy = &policy
 		case "labels":
-			inf.Labels = parseLabels(val.([]interface{}))
+			inf.Labels, err = parseLabels(field, val)
 		case "sourceKey":
-			inf.SourceKey = parseString(val)
+			inf.SourceKey, err = parseString(field, val)
 		case "rules":
-			inf.Rules = parseRules(val.([]interface{}))
+			inf.Rules, err = parseRules(val)
 		case "Chunks":
-			inf.Chunks = []ChunkInfo{}
-			for _, v := range val.([]interface{}) {
-				inf.Chunks = append(inf.Chunks, parseChunkInfo(v.([]interface{})))
+			var cs []interface{}
+			if cs, err = parseArray(field, val); err != nil {
+				break
+			}
+			inf.Chunks = make([]ChunkInfo, len(cs))
+			for j := range cs {
+				if inf.Chunks[j], err = parseChunkInfo(cs[j]); err != nil {
+					break
+				}
 			}
 		}
+		if err != nil {
+			return Info{}, err
+		}
 	}
-	return inf
-}
-
-func parseString(val interface{}) string {
-	switch val.(type) {
-	case []byte:
-		return string(val.([]byte))
-	case string: // some clients decodes values as string
-		return val.(string)
-	default:
-		panic(fmt.Sprintf("val %T not convertible to string", val))
-	}
+	return inf, nil
 }
 
-type cmdInfo struct {
+// CmdInfo is the TS.INFO command.
+type CmdInfo struct {
 	key   string
 	debug bool
 }
 
-func newCmdInfo(key string) *cmdInfo {
-	return &cmdInfo{key: key}
+func newCmdInfo(key string) *CmdInfo {
+	return &CmdInfo{key: key}
 }
 
-func (c *cmdInfo) Name() string {
+// NewCmdInfo returns a TS.INFO command with the given options applied.
+func NewCmdInfo(key string, options ...OptionInfo) *CmdInfo {
+	cmd := newCmdInfo(key)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdInfo) Name() string {
 	return "TS.INFO"
 }
 
-func (c *cmdInfo) Args() []interface{} {
+func (c *CmdInfo) Args() []interface{} {
 	args := []interface{}{c.key}
 	if c.debug {
 		args = append(args, optionNameDebug)
@@ -150,41 +220,44 @@ func (c *cmdInfo) Args() []interface{} {
 	return args
 }
 
-type OptionInfo func(cmd *cmdInfo)
+type OptionInfo func(cmd *CmdInfo)
 
 // Info returns information and statistics on the time-series.
 func (c *Client) Info(ctx context.Context, key string, options ...OptionInfo) (Info, error) {
-	cmd := newCmdInfo(key)
-	for i := range options {
-		options[i](cmd)
-	}
-	i, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
-	var inf Info
-	if is, ok := i.([]interface{}); ok {
-		inf = parseInfo(is)
+	cmd := NewCmdInfo(key, options...)
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return Info{}, err
 	}
-	return inf, err
+	inf, err := parseInfo(res)
+	return inf, wrapError(cmd, err)
 }
 
 func InfoWithDebug() OptionInfo {
-	return func(cmd *cmdInfo) {
+	return func(cmd *CmdInfo) {
 		cmd.debug = true
 	}
 }
 
-type cmdQueryIndex struct {
+// CmdQueryIndex is the TS.QUERYINDEX command.
+type CmdQueryIndex struct {
 	filters []Filter
 }
 
-func newCmdQueryIndex(filters []Filter) *cmdQueryIndex {
-	return &cmdQueryIndex{filters: filters}
+func newCmdQueryIndex(filters []Filter) *CmdQueryIndex {
+	return &CmdQueryIndex{filters: filters}
+}
+
+// NewCmdQueryIndex returns a TS.QUERYINDEX command.
+func NewCmdQueryIndex(filters []Filter) *CmdQueryIndex {
+	return newCmdQueryIndex(filters)
 }
 
-func (c *cmdQueryIndex) Name() string {
+func (c *CmdQueryIndex) Name() string {
 	return "TS.QUERYINDEX"
 }
 
-func (c *cmdQueryIndex) Args() []interface{} {
+func (c *CmdQueryIndex) Args() []interface{} {
 	args := []interface{}{}
 	for _, f := range c.filters {
 		args = append(args, f.Arg())
@@ -194,14 +267,28 @@ func (c *cmdQueryIndex) Args() []interface{} {
 
 // QueryIndex lists all the keys matching the filter list.
 func (c *Client) QueryIndex(ctx context.Context, filters []Filter) ([]string, error) {
-	cmd := newCmdQueryIndex(filters)
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
-	var keys []string
-	if is, ok := res.([]interface{}); ok {
-		keys = make([]string, len(is))
-		for i := range is {
-			keys[i] = parseString(is[i])
+	cmd := NewCmdQueryIndex(filters)
+	if cd, ok := c.d.(ClusterDoer); ok {
+		return c.clusterQueryIndex(ctx, cd, cmd)
+	}
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseKeys(res)
+	return keys, wrapError(cmd, err)
+}
+
+func parseKeys(res interface{}) ([]string, error) {
+	is, err := parseArray("keys", res)
+	if err != nil || is == nil {
+		return nil, err
+	}
+	keys := make([]string, len(is))
+	for i := range is {
+		if keys[i], err = parseString("keys", is[i]); err != nil {
+			return nil, err
 		}
 	}
-	return keys, err
+	return keys, nil
 }
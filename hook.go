@@ -0,0 +1,167 @@
+package redists
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// CmdEvent describes a command sent by Client. It is passed to the hooks of
+// the client.
+type CmdEvent struct {
+	// Name is the name of the command, e.g. "TS.ADD".
+	Name string
+	// Keys are the keys the command is sent to. It is empty for commands
+	// which select time-series with filters.
+	Keys []string
+	// Args are the arguments of the command. Hooks must not modify them.
+	Args []interface{}
+	// ArgCount is the number of arguments.
+	ArgCount int
+	// Start is the time the command is sent.
+	Start time.Time
+	// Duration is the time the Doer took to reply. It is set for AfterCmd.
+	Duration time.Duration
+	// ReplySize is the approximate size of the reply in bytes. It is set
+	// for AfterCmd.
+	ReplySize int
+	// Err is the error returned by the Doer. It is set for AfterCmd.
+	Err error
+}
+
+func newCmdEvent(name string, args []interface{}) *CmdEvent {
+	return &CmdEvent{
+		Name:     strings.ToUpper(name),
+		Keys:     cmdKeys(name, args),
+		Args:     args,
+		ArgCount: len(args),
+	}
+}
+
+// cmdKeys returns the keys in the arguments of the command.
+func cmdKeys(name string, args []interface{}) []string {
+	name = strings.ToUpper(name)
+	if filterCmds[name] || len(args) == 0 {
+		return nil
+	}
+	var keys []string
+	add := func(arg interface{}) {
+		if key, ok := arg.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	switch name {
+	case "TS.MADD":
+		seen := map[string]bool{}
+		for i := 0; i < len(args); i += 3 {
+			if key, ok := args[i].(string); ok && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	case "TS.CREATERULE", "TS.DELETERULE":
+		add(args[0])
+		if len(args) > 1 {
+			add(args[1])
+		}
+	default:
+		add(args[0])
+	}
+	return keys
+}
+
+// replySize returns the approximate size of a reply in bytes.
+func replySize(val interface{}) int {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case error:
+		return len(v.Error())
+	case []interface{}:
+		n := 0
+		for i := range v {
+			n += replySize(v[i])
+		}
+		return n
+	case map[interface{}]interface{}:
+		n := 0
+		for k, e := range v {
+			n += replySize(k) + replySize(e)
+		}
+		return n
+	case map[string]interface{}:
+		n := 0
+		for k, e := range v {
+			n += len(k) + replySize(e)
+		}
+		return n
+	default:
+		return 8
+	}
+}
+
+// Hook is called around every command sent by Client, including the
+// commands of pipelines.
+type Hook interface {
+	// BeforeCmd is called before the command is sent. The returned context
+	// is passed to the Doer and to AfterCmd.
+	BeforeCmd(ctx context.Context, e *CmdEvent) context.Context
+	// AfterCmd is called after the Doer returned.
+	AfterCmd(ctx context.Context, e *CmdEvent)
+}
+
+// HookFuncs is a Hook which calls the non-nil functions.
+type HookFuncs struct {
+	Before func(ctx context.Context, e *CmdEvent) context.Context
+	After  func(ctx context.Context, e *CmdEvent)
+}
+
+func (h HookFuncs) BeforeCmd(ctx context.Context, e *CmdEvent) context.Context {
+	if h.Before == nil {
+		return ctx
+	}
+	return h.Before(ctx, e)
+}
+
+func (h HookFuncs) AfterCmd(ctx context.Context, e *CmdEvent) {
+	if h.After != nil {
+		h.After(ctx, e)
+	}
+}
+
+type hooks []Hook
+
+// before calls BeforeCmd of the hooks in order.
+func (hs hooks) before(ctx context.Context, e *CmdEvent) context.Context {
+	e.Start = time.Now()
+	for _, h := range hs {
+		ctx = h.BeforeCmd(ctx, e)
+	}
+	return ctx
+}
+
+// after calls AfterCmd of the hooks in reverse order.
+func (hs hooks) after(ctx context.Context, e *CmdEvent, res interface{}, err error) {
+	e.Duration = time.Since(e.Start)
+	e.ReplySize = replySize(res)
+	e.Err = err
+	for i := len(hs) - 1; i >= 0; i-- {
+		hs[i].AfterCmd(ctx, e)
+	}
+}
+
+// do sends a command with d and runs the hooks around it.
+func (hs hooks) do(ctx context.Context, d Doer, name string, args []interface{}) (interface{}, error) {
+	if len(hs) == 0 {
+		return d.Do(ctx, name, args...)
+	}
+	e := newCmdEvent(name, args)
+	ctx = hs.before(ctx, e)
+	res, err := d.Do(ctx, name, args...)
+	hs.after(ctx, e, res, err)
+	return res, err
+}
@@ -0,0 +1,135 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClient_hooks(t *testing.T) {
+	ctx := context.Background()
+	errAny := errors.New("any error")
+	var log []string
+	var events []CmdEvent
+	hook := func(name string) Hook {
+		return HookFuncs{
+			Before: func(ctx context.Context, e *CmdEvent) context.Context {
+				log = append(log, name+" before "+e.Name)
+				return ctx
+			},
+			After: func(ctx context.Context, e *CmdEvent) {
+				log = append(log, name+" after "+e.Name)
+				if name == "a" {
+					events = append(events, *e)
+				}
+			},
+		}
+	}
+	check := func(t *testing.T, wantLog []string) {
+		if !reflect.DeepEqual(log, wantLog) {
+			t.Errorf("hooks = %q, want %q", log, wantLog)
+		}
+		if got, want := len(events), 2; got != want {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+		add, madd := events[0], events[1]
+		if got, want := add.Keys, []string{"key:any"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+		if got, want := add.ArgCount, 3; got != want {
+			t.Errorf("ArgCount = %v, want %v", got, want)
+		}
+		if got, want := add.ReplySize, 8; got != want {
+			t.Errorf("ReplySize = %v, want %v", got, want)
+		}
+		if add.Start.IsZero() || add.Duration < 0 {
+			t.Errorf("Start = %v, Duration = %v", add.Start, add.Duration)
+		}
+		if got, want := madd.Keys, []string{"key:any", "key:other"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+		if got := madd.Err; !errors.Is(got, errAny) {
+			t.Errorf("Err = %v, want %v", got, errAny)
+		}
+	}
+	samples := []Sample{
+		NewSample("key:any", time.UnixMilli(1000), 1),
+		NewSample("key:other", time.UnixMilli(1000), 1),
+		NewSample("key:any", time.UnixMilli(2000), 1),
+	}
+	t.Run("client", func(t *testing.T) {
+		log, events = nil, nil
+		c := NewClient(&replyDoer{replies: []interface{}{int64(1000), errAny}}, ClientWithHooks(hook("a"), hook("b")))
+		if _, err := c.Add(ctx, samples[0]); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if _, err := c.MAdd(ctx, samples); !errors.Is(err, errAny) {
+			t.Fatalf("MAdd() error = %v, want %v", err, errAny)
+		}
+		check(t, []string{
+			"a before TS.ADD", "b before TS.ADD", "b after TS.ADD", "a after TS.ADD",
+			"a before TS.MADD", "b before TS.MADD", "b after TS.MADD", "a after TS.MADD",
+		})
+	})
+	t.Run("pipeline", func(t *testing.T) {
+		log, events = nil, nil
+		d := &replyPipelineDoer{replyDoer: replyDoer{replies: []interface{}{int64(1000), errAny}}}
+		p := NewClient(d, ClientWithHooks(hook("a"), hook("b"))).Pipeline()
+		p.Add(samples[0])
+		p.MAdd(samples)
+		if err := p.Exec(ctx); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		check(t, []string{
+			"a before TS.ADD", "b before TS.ADD", "a before TS.MADD", "b before TS.MADD",
+			"b after TS.ADD", "a after TS.ADD", "b after TS.MADD", "a after TS.MADD",
+		})
+	})
+}
+
+func TestRedactLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		args []interface{}
+		want []interface{}
+	}{
+		{
+			name: "labels",
+			cmd:  "TS.CREATE",
+			args: []interface{}{"key:any", "RETENTION", int64(1000), "LABELS", "env", "prod", "team", "a"},
+			want: []interface{}{"key:any", "RETENTION", int64(1000), "LABELS", "env", redacted, "team", redacted},
+		},
+		{
+			name: "no labels",
+			cmd:  "TS.ADD",
+			args: []interface{}{"key:any", int64(1000), 0.5},
+			want: []interface{}{"key:any", int64(1000), 0.5},
+		},
+		{
+			name: "mrange",
+			cmd:  "TS.MRANGE",
+			args: []interface{}{"-", "+", "FILTER", "env=prod", "team!=(a,b)", "host=", "GROUPBY", "env", "REDUCE", "SUM"},
+			want: []interface{}{"-", "+", "FILTER", "env=" + redacted, "team!=" + redacted, "host=", "GROUPBY", "env", "REDUCE", "SUM"},
+		},
+		{
+			name: "queryindex",
+			cmd:  "TS.QUERYINDEX",
+			args: []interface{}{"env=prod"},
+			want: []interface{}{"env=" + redacted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]interface{}(nil), tt.args...)
+			if got := RedactLabels(tt.cmd, args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactLabels() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("RedactLabels() modified args = %v", args)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package redists_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/redistest"
+)
+
+// TestMain runs the client tests against the server at REDISTS_ADDR, or
+// against a redistest.Server when it is not set.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if addr := os.Getenv("REDISTS_ADDR"); addr != "" {
+		redists.SetRedisAddr(addr)
+		os.Exit(m.Run())
+	}
+	s, err := redistest.StartServer("127.0.0.1:0")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot start redistest server: %v\n", err)
+		os.Exit(1)
+	}
+	redists.SetRedisAddr(s.Addr())
+	code := m.Run()
+	s.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,194 @@
+package redists
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces label values in the arguments returned by RedactLabels.
+const redacted = "[REDACTED]"
+
+// RedactLabels returns a copy of the arguments of a command where the label
+// values are replaced, so they can be logged. It redacts the LABELS of
+// TS.CREATE, TS.ALTER, TS.ADD, TS.INCRBY and TS.DECRBY, and the values in
+// the filters of TS.MRANGE, TS.MGET and TS.QUERYINDEX.
+func RedactLabels(name string, args []interface{}) []interface{} {
+	res := append([]interface{}(nil), args...)
+	name = strings.ToUpper(name)
+	if filterCmds[name] {
+		inFilter := name == "TS.QUERYINDEX"
+		for i, arg := range res {
+			s, _ := arg.(string)
+			switch {
+			case s == optionNameFilter:
+				inFilter = true
+			case s == optionNameGroupBy:
+				inFilter = false
+			case inFilter:
+				res[i] = redactFilter(s)
+			}
+		}
+		return res
+	}
+	for i, arg := range res {
+		if arg != optionNameLabels {
+			continue
+		}
+		for j := i + 2; j < len(res); j += 2 {
+			res[j] = redacted
+		}
+		break
+	}
+	return res
+}
+
+// redactFilter replaces the value of a filter expression, e.g. "l=v" becomes
+// "l=[REDACTED]". Filters without values are kept, because they only test
+// the existence of a label.
+func redactFilter(f string) string {
+	i := strings.Index(f, "=")
+	if i < 0 || i == len(f)-1 {
+		return f
+	}
+	return f[:i+1] + redacted
+}
+
+// DefaultLatencyBuckets are the buckets of NewLatencyHistogram without
+// arguments.
+var DefaultLatencyBuckets = []time.Duration{
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// LatencyHistogram is a Hook which counts the duration of commands in
+// buckets per command name. It can be scraped by Prometheus, because it
+// serves the histograms in the text exposition format.
+type LatencyHistogram struct {
+	buckets []time.Duration
+	mu      sync.Mutex
+	cmds    map[string]*LatencySnapshot
+}
+
+var _ Hook = (*LatencyHistogram)(nil)
+
+// NewLatencyHistogram returns a LatencyHistogram with the given bucket upper
+// bounds. Without buckets, DefaultLatencyBuckets are used.
+func NewLatencyHistogram(buckets ...time.Duration) *LatencyHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	bs := append([]time.Duration(nil), buckets...)
+	sort.Slice(bs, func(i, j int) bool {
+		return bs[i] < bs[j]
+	})
+	return &LatencyHistogram{buckets: bs, cmds: map[string]*LatencySnapshot{}}
+}
+
+func (h *LatencyHistogram) BeforeCmd(ctx context.Context, _ *CmdEvent) context.Context {
+	return ctx
+}
+
+func (h *LatencyHistogram) AfterCmd(_ context.Context, e *CmdEvent) {
+	i := sort.Search(len(h.buckets), func(i int) bool {
+		return e.Duration <= h.buckets[i]
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.cmds[e.Name]
+	if !ok {
+		s = &LatencySnapshot{Cmd: e.Name, Buckets: h.buckets, Counts: make([]uint64, len(h.buckets)+1)}
+		h.cmds[e.Name] = s
+	}
+	s.Counts[i]++
+	s.Count++
+	s.Sum += e.Duration
+	if e.Err != nil {
+		s.Errors++
+	}
+}
+
+// LatencySnapshot is the histogram of a command.
+type LatencySnapshot struct {
+	// Cmd is the name of the command.
+	Cmd string
+	// Buckets are the upper bounds of the buckets.
+	Buckets []time.Duration
+	// Counts are the number of commands in each bucket. The last element is
+	// the number of commands slower than the last bucket.
+	Counts []uint64
+	// Count is the number of commands.
+	Count uint64
+	// Sum is the total duration of the commands.
+	Sum time.Duration
+	// Errors is the number of failed commands.
+	Errors uint64
+}
+
+// Snapshot returns the histograms sorted by command name.
+func (h *LatencyHistogram) Snapshot() []LatencySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ss := make([]LatencySnapshot, 0, len(h.cmds))
+	for _, s := range h.cmds {
+		c := *s
+		c.Counts = append([]uint64(nil), s.Counts...)
+		ss = append(ss, c)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].Cmd < ss[j].Cmd
+	})
+	return ss
+}
+
+// WriteTo writes the histograms in the Prometheus text exposition format.
+func (h *LatencyHistogram) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	ss := h.Snapshot()
+	b.WriteString("# HELP redists_cmd_duration_seconds Duration of RedisTimeSeries commands.\n")
+	b.WriteString("# TYPE redists_cmd_duration_seconds histogram\n")
+	for _, s := range ss {
+		cmd := strconv.Quote(s.Cmd)
+		var n uint64
+		for i, bucket := range s.Buckets {
+			n += s.Counts[i]
+			fmt.Fprintf(&b, "redists_cmd_duration_seconds_bucket{cmd=%s,le=\"%s\"} %d\n", cmd, formatSeconds(bucket), n)
+		}
+		fmt.Fprintf(&b, "redists_cmd_duration_seconds_bucket{cmd=%s,le=\"+Inf\"} %d\n", cmd, s.Count)
+		fmt.Fprintf(&b, "redists_cmd_duration_seconds_sum{cmd=%s} %s\n", cmd, formatSeconds(s.Sum))
+		fmt.Fprintf(&b, "redists_cmd_duration_seconds_count{cmd=%s} %d\n", cmd, s.Count)
+	}
+	b.WriteString("# HELP redists_cmd_errors_total Number of failed RedisTimeSeries commands.\n")
+	b.WriteString("# TYPE redists_cmd_errors_total counter\n")
+	for _, s := range ss {
+		fmt.Fprintf(&b, "redists_cmd_errors_total{cmd=%s} %d\n", strconv.Quote(s.Cmd), s.Errors)
+	}
+	return b.WriteTo(w)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+// ServeHTTP serves the histograms in the Prometheus text exposition format.
+func (h *LatencyHistogram) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteTo(w)
+}
@@ -0,0 +1,50 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	ctx := context.Background()
+	h := NewLatencyHistogram(10*time.Millisecond, time.Millisecond)
+	for _, e := range []CmdEvent{
+		{Name: "TS.ADD", Duration: 500 * time.Microsecond},
+		{Name: "TS.ADD", Duration: 5 * time.Millisecond},
+		{Name: "TS.ADD", Duration: time.Second, Err: errors.New("any error")},
+		{Name: "TS.GET", Duration: time.Millisecond},
+	} {
+		e := e
+		h.AfterCmd(ctx, &e)
+	}
+	buckets := []time.Duration{time.Millisecond, 10 * time.Millisecond}
+	want := []LatencySnapshot{
+		{Cmd: "TS.ADD", Buckets: buckets, Counts: []uint64{1, 1, 1}, Count: 3, Sum: 1005500 * time.Microsecond, Errors: 1},
+		{Cmd: "TS.GET", Buckets: buckets, Counts: []uint64{1, 0, 0}, Count: 1, Sum: time.Millisecond},
+	}
+	if got := h.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want %v", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`redists_cmd_duration_seconds_bucket{cmd="TS.ADD",le="0.001"} 1`,
+		`redists_cmd_duration_seconds_bucket{cmd="TS.ADD",le="0.01"} 2`,
+		`redists_cmd_duration_seconds_bucket{cmd="TS.ADD",le="+Inf"} 3`,
+		`redists_cmd_duration_seconds_sum{cmd="TS.ADD"} 1.0055`,
+		`redists_cmd_duration_seconds_count{cmd="TS.ADD"} 3`,
+		`redists_cmd_errors_total{cmd="TS.ADD"} 1`,
+		`redists_cmd_errors_total{cmd="TS.GET"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("ServeHTTP() body does not contain %q:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package redists
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mismatch is a read whose reply differs between the primary and the
+// secondary of a Migrator.
+type Mismatch struct {
+	// Cmd is the name of the command.
+	Cmd string
+	// Args are the arguments of the command.
+	Args []interface{}
+	// Primary is the reply of the primary.
+	Primary interface{}
+	// Secondary is the reply of the secondary.
+	Secondary interface{}
+	// SecondaryErr is the error of the secondary.
+	SecondaryErr error
+}
+
+// Migrator is a Doer which runs two backends side by side, e.g. while
+// RedisTimeSeries is moved to new hardware. Writes are sent to the primary
+// and then to the secondary, and reads are served by the primary.
+//
+// Automatic timestamps of TS.ADD, TS.MADD, TS.INCRBY and TS.DECRBY are
+// replaced with the timestamps assigned by the primary, so both backends
+// store the same samples. Because of this, the secondary is written only
+// after the primary replied, and a write takes the latency of both backends.
+// When a write fails on the primary, it is not sent to the secondary. When it
+// fails only on the secondary, the call succeeds, and the error is reported to
+// the function of MigratorWithSecondaryError.
+type Migrator struct {
+	primary       Doer
+	secondary     Doer
+	shadowRate    float64
+	shadowTimeout time.Duration
+	onMismatch    func(ctx context.Context, m Mismatch)
+	onSecondary   func(ctx context.Context, cmd string, args []interface{}, err error)
+
+	mu sync.Mutex
+	r  *rand.Rand
+	wg sync.WaitGroup
+}
+
+type OptionMigrator func(m *Migrator)
+
+// NewMigrator returns a Migrator which writes to primary and secondary. By
+// default, errors of the secondary are ignored, and shadow reads time out
+// after 10 seconds.
+func NewMigrator(primary, secondary Doer, options ...OptionMigrator) *Migrator {
+	m := &Migrator{
+		primary:       primary,
+		secondary:     secondary,
+		shadowTimeout: 10 * time.Second,
+		r:             rand.New(rand.NewSource(rand.Int63())),
+	}
+	for i := range options {
+		options[i](m)
+	}
+	return m
+}
+
+// MigratorWithShadowReads sends the given fraction of TS.RANGE, TS.REVRANGE,
+// TS.MRANGE, TS.MREVRANGE, TS.GET and TS.MGET to the secondary too, and it
+// calls f when the decoded replies differ. The shadow read is sent
+// concurrently, and the call returns the reply of the primary without waiting
+// for it. The replies are compared in the background, so f may be called
+// after the call returned, and concurrently. The context of f has the values
+// of the context of the call, but not its cancellation.
+func MigratorWithShadowReads(rate float64, f func(ctx context.Context, m Mismatch)) OptionMigrator {
+	return func(m *Migrator) {
+		m.shadowRate = rate
+		m.onMismatch = f
+	}
+}
+
+// MigratorWithShadowTimeout limits the time of a shadow read. A shadow read
+// which times out is reported as a Mismatch with the error.
+func MigratorWithShadowTimeout(d time.Duration) OptionMigrator {
+	return func(m *Migrator) {
+		m.shadowTimeout = d
+	}
+}
+
+// MigratorWithSecondaryError sets the function which is called when a write
+// fails only on the secondary. The error is a CmdError.
+func MigratorWithSecondaryError(f func(ctx context.Context, cmd string, args []interface{}, err error)) OptionMigrator {
+	return func(m *Migrator) {
+		m.onSecondary = f
+	}
+}
+
+func (m *Migrator) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if ClassifyCmd(cmd, args, "") != CmdClassRead {
+		return m.write(ctx, cmd, args)
+	}
+	if !m.shadow(cmd) {
+		return m.primary.Do(ctx, cmd, args...)
+	}
+	type reply struct {
+		res interface{}
+		err error
+	}
+	primary := make(chan reply, 1)
+	// the shadow read must not be canceled when the call returns
+	bctx := detachedContext{ctx}
+	sctx, cancel := context.WithTimeout(bctx, m.shadowTimeout)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+		sres, serr := m.secondary.Do(sctx, cmd, args...)
+		// the channel is closed without a reply when the primary panics
+		p, ok := <-primary
+		if ok && p.err == nil && !sameReply(cmd, p.res, sres, serr) {
+			m.onMismatch(bctx, Mismatch{Cmd: cmd, Args: args, Primary: p.res, Secondary: sres, SecondaryErr: serr})
+		}
+	}()
+	defer close(primary)
+	res, err := m.primary.Do(ctx, cmd, args...)
+	primary <- reply{res: res, err: err}
+	return res, err
+}
+
+// Wait waits until the shadow reads in flight are compared, e.g. before the
+// program exits.
+func (m *Migrator) Wait() {
+	m.wg.Wait()
+}
+
+// detachedContext has the values of a context without its deadline and
+// cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// shadow reports whether a read is sent to the secondary too.
+func (m *Migrator) shadow(cmd string) bool {
+	if m.onMismatch == nil || m.shadowRate <= 0 {
+		return false
+	}
+	switch strings.ToUpper(cmd) {
+	case string(nameRange), string(nameRevRange), string(nameMRange), string(nameMRevRange), "TS.GET", "TS.MGET":
+	default:
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.r.Float64() < m.shadowRate
+}
+
+func (m *Migrator) write(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	res, err := m.primary.Do(ctx, cmd, args...)
+	if err != nil {
+		return res, err
+	}
+	sargs := pinTimestamps(cmd, args, res)
+	sres, serr := m.secondary.Do(ctx, cmd, sargs...)
+	if m.onSecondary == nil {
+		return res, nil
+	}
+	if serr != nil {
+		m.onSecondary(ctx, cmd, sargs, newCmdError(cmd, sargs, serr))
+		return res, nil
+	}
+	// the samples of TS.MADD fail one by one
+	rs, _ := res.([]interface{})
+	srs, _ := sres.([]interface{})
+	for i := 0; i < len(rs) && i < len(srs) && 3*i < len(sargs); i++ {
+		_, failed := rs[i].(error)
+		if serr, ok := srs[i].(error); ok && !failed {
+			m.onSecondary(ctx, cmd, sargs, newCmdError(cmd, sargs[3*i:], serr))
+		}
+	}
+	return res, nil
+}
+
+// pinTimestamps returns the arguments of a write where automatic timestamps
+// are replaced with the ones in the reply of the primary.
+func pinTimestamps(cmd string, args []interface{}, res interface{}) []interface{} {
+	switch strings.ToUpper(cmd) {
+	case "TS.ADD":
+		if ts, ok := res.(int64); ok && len(args) > 1 && isAutoTimestamp(args[1]) {
+			sargs := append([]interface{}(nil), args...)
+			sargs[1] = ts
+			return sargs
+		}
+	case "TS.MADD":
+		rs, _ := res.([]interface{})
+		var sargs []interface{}
+		for i := 1; i < len(args) && (i-1)/3 < len(rs); i += 3 {
+			ts, ok := rs[(i-1)/3].(int64)
+			if !ok || !isAutoTimestamp(args[i]) {
+				continue
+			}
+			if sargs == nil {
+				sargs = append([]interface{}(nil), args...)
+			}
+			sargs[i] = ts
+		}
+		if sargs != nil {
+			return sargs
+		}
+	case string(nameIncrBy), string(nameDecrBy):
+		ts, ok := res.(int64)
+		if !ok || len(args) < 2 {
+			break
+		}
+		for _, arg := range args {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, optionNameTimestamp) {
+				return args
+			}
+		}
+		sargs := append([]interface{}{args[0], args[1], optionNameTimestamp, ts}, args[2:]...)
+		return sargs
+	}
+	return args
+}
+
+// sameReply reports whether the decoded replies of a read are equal. The
+// time-series of TS.MRANGE and TS.MGET are compared in the order of their
+// keys.
+func sameReply(cmd string, res interface{}, sres interface{}, serr error) bool {
+	if serr != nil {
+		return false
+	}
+	var a, b interface{}
+	var aerr, berr error
+	switch strings.ToUpper(cmd) {
+	case string(nameRange), string(nameRevRange):
+		a, aerr = parseDataPoints(res)
+		b, berr = parseDataPoints(sres)
+	case string(nameMRange), string(nameMRevRange):
+		a, aerr = sortedTimeSeries(res)
+		b, berr = sortedTimeSeries(sres)
+	case "TS.GET":
+		a, aerr = parseLastDataPoint(res)
+		b, berr = parseLastDataPoint(sres)
+	case "TS.MGET":
+		a, aerr = sortedLastDatapoints(res)
+		b, berr = sortedLastDatapoints(sres)
+	default:
+		return reflect.DeepEqual(res, sres)
+	}
+	return aerr == nil && berr == nil && reflect.DeepEqual(a, b)
+}
+
+func sortedTimeSeries(res interface{}) ([]TimeSeries, error) {
+	ds, err := parseTimeSeriesList(res)
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].Key < ds[j].Key
+	})
+	return ds, err
+}
+
+func sortedLastDatapoints(res interface{}) ([]LastDatapoint, error) {
+	ds, err := parseLastDatapoints(res)
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i].Key < ds[j].Key
+	})
+	return ds, err
+}
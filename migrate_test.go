@@ -0,0 +1,188 @@
+package redists_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/redistest"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	clock := func(ms int64) redistest.OptionDoer {
+		return redistest.DoerWithClock(func() time.Time { return time.UnixMilli(ms) })
+	}
+	primary, secondary := redistest.NewDoer(clock(1000)), redistest.NewDoer(clock(5000))
+	var secondaryErrs []error
+	var mismatches []redists.Mismatch
+	m := redists.NewMigrator(primary, secondary,
+		redists.MigratorWithSecondaryError(func(_ context.Context, _ string, _ []interface{}, err error) {
+			secondaryErrs = append(secondaryErrs, err)
+		}),
+		redists.MigratorWithShadowReads(1, func(_ context.Context, m redists.Mismatch) {
+			mismatches = append(mismatches, m)
+		}),
+	)
+	c := redists.NewClient(m)
+	pc, sc := redists.NewClient(primary), redists.NewClient(secondary)
+	labels := redists.Labels{"env": "prod"}
+
+	// the key already exists on the secondary, so only the secondary fails
+	if err := sc.Create(ctx, "key:a", redists.CreateWithLabels(labels)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Create(ctx, "key:a", redists.CreateWithLabels(labels)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if len(secondaryErrs) != 1 || !errors.Is(secondaryErrs[0], redists.ErrKeyExists) {
+		t.Errorf("secondary errors = %v, want %v", secondaryErrs, redists.ErrKeyExists)
+	}
+	if err := c.Create(ctx, "key:b"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := c.Add(ctx, redists.NewSample("key:a", redists.TSAuto(), 1)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, err := c.MAdd(ctx, []redists.Sample{redists.NewSample("key:a", time.UnixMilli(2000), 2), redists.NewSample("key:b", redists.TSAuto(), 3)}); err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	// the sample is blocked only on the secondary
+	if _, err := sc.Add(ctx, redists.NewSample("key:b", time.UnixMilli(4000), 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MAdd(ctx, []redists.Sample{redists.NewSample("key:b", time.UnixMilli(4000), 4)}); err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	if len(secondaryErrs) != 2 || !errors.Is(secondaryErrs[1], redists.ErrDuplicateBlocked) {
+		t.Errorf("secondary errors = %v, want %v", secondaryErrs, redists.ErrDuplicateBlocked)
+	}
+	if _, err := sc.Del(ctx, "key:b", time.UnixMilli(4000), time.UnixMilli(4000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.Del(ctx, "key:b", time.UnixMilli(4000), time.UnixMilli(4000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.IncrBy(ctx, "key:c", 1, redists.CounterWithLabels(labels)); err != nil {
+		t.Fatalf("IncrBy() error = %v", err)
+	}
+	for _, key := range []string{"key:a", "key:b", "key:c"} {
+		want, _ := pc.Range(ctx, key, redists.TSMin(), redists.TSMax())
+		got, err := sc.Range(ctx, key, redists.TSMin(), redists.TSMax())
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Range(%q) on the secondary = %v, %v, want %v", key, got, err, want)
+		}
+	}
+
+	filters := []redists.Filter{redists.FilterEqual("env", "prod")}
+	if _, err := c.MGet(ctx, filters); err != nil {
+		t.Fatalf("MGet() error = %v", err)
+	}
+	if _, err := c.Range(ctx, "key:a", redists.TSMin(), redists.TSMax()); err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	m.Wait()
+	if len(mismatches) != 0 {
+		t.Errorf("mismatches = %v, want none", mismatches)
+	}
+	if _, err := sc.Add(ctx, redists.NewSample("key:a", time.UnixMilli(3000), 4)); err != nil {
+		t.Fatal(err)
+	}
+	want, err := c.Range(ctx, "key:a", redists.TSMin(), redists.TSMax())
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	if len(want) != 2 {
+		t.Errorf("Range() = %v, want the samples of the primary", want)
+	}
+	m.Wait()
+	if _, err := c.MGet(ctx, filters); err != nil {
+		t.Fatalf("MGet() error = %v", err)
+	}
+	m.Wait()
+	if got := len(mismatches); got != 2 {
+		t.Fatalf("mismatches = %v, want 2", got)
+	}
+	if got, want := mismatches[0].Cmd, "TS.RANGE"; got != want {
+		t.Errorf("Mismatch.Cmd = %v, want %v", got, want)
+	}
+}
+
+// hungDoer never replies before the context is done.
+type hungDoer struct{}
+
+func (hungDoer) Do(ctx context.Context, _ string, _ ...interface{}) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestMigrator_slowSecondary(t *testing.T) {
+	ctx := context.Background()
+	primary := redistest.NewDoer()
+	mismatches := make(chan redists.Mismatch, 1)
+	m := redists.NewMigrator(primary, hungDoer{},
+		redists.MigratorWithShadowReads(1, func(_ context.Context, m redists.Mismatch) {
+			mismatches <- m
+		}),
+		redists.MigratorWithShadowTimeout(50*time.Millisecond),
+	)
+	if _, err := primary.Do(ctx, "TS.CREATE", "key:a"); err != nil {
+		t.Fatal(err)
+	}
+	// the deadline of the call does not apply to the shadow read
+	cctx, cancel := context.WithTimeout(ctx, time.Second)
+	start := time.Now()
+	_, err := redists.NewClient(m).Range(cctx, "key:a", redists.TSMin(), redists.TSMax())
+	cancel()
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	if d := time.Since(start); d >= 50*time.Millisecond {
+		t.Errorf("Range() took %v, want it not to wait for the secondary", d)
+	}
+	m.Wait()
+	select {
+	case got := <-mismatches:
+		if !errors.Is(got.SecondaryErr, context.DeadlineExceeded) {
+			t.Errorf("Mismatch.SecondaryErr = %v, want %v", got.SecondaryErr, context.DeadlineExceeded)
+		}
+	default:
+		t.Errorf("mismatches = none, want the timed out shadow read")
+	}
+}
+
+// panicDoer panics on every command.
+type panicDoer struct{}
+
+func (panicDoer) Do(context.Context, string, ...interface{}) (interface{}, error) {
+	panic("redists_test: primary panicked")
+}
+
+func TestMigrator_primaryPanic(t *testing.T) {
+	m := redists.NewMigrator(panicDoer{}, redistest.NewDoer(),
+		redists.MigratorWithShadowReads(1, func(_ context.Context, m redists.Mismatch) {
+			t.Errorf("Mismatch = %v, want none", m)
+		}),
+	)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Do() did not panic")
+			}
+		}()
+		_, _ = m.Do(context.Background(), "TS.GET", "key:a")
+	}()
+	done := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait() blocked after the primary panicked")
+	}
+}
@@ -0,0 +1,473 @@
+package redists
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PipelineCmd is a single command sent by a PipelineDoer.
+type PipelineCmd struct {
+	Name string
+	Args []interface{}
+}
+
+// PipelineDoer is an optional extension of Doer which sends multiple
+// commands in a single round trip.
+type PipelineDoer interface {
+	Doer
+	// DoPipeline sends all commands at once and returns their replies in the
+	// same order. A command which failed is represented by an error value in
+	// the reply slice, while the returned error means that the whole pipeline
+	// failed.
+	DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error)
+}
+
+type pipelineCmd struct {
+	PipelineCmd
+	err    *error
+	decode func(res interface{}) error
+}
+
+func (c pipelineCmd) set(res interface{}) {
+	if err := c.decode(res); err != nil {
+		c.fail(err)
+	}
+}
+
+func (c pipelineCmd) fail(err error) {
+	*c.err = newCmdError(c.Name, c.Args, err)
+}
+
+// Pipeline queues commands and sends them to the server with Exec. The
+// results of the queued commands are available after Exec returns.
+type Pipeline struct {
+	d     Doer
+	hooks hooks
+	cmds  []pipelineCmd
+}
+
+// Pipeline creates a new Pipeline. It uses a single round trip when the
+// underlying Doer implements PipelineDoer, otherwise it falls back to
+// sequential Do calls.
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{d: c.d, hooks: c.hooks}
+}
+
+// queue adds cmd to the queue. On success decode is called with the reply,
+// otherwise the error is stored in err.
+func (p *Pipeline) queue(cmd Cmd, err *error, decode func(res interface{}) error) {
+	p.cmds = append(p.cmds, pipelineCmd{
+		PipelineCmd: PipelineCmd{Name: cmd.Name(), Args: cmd.Args()},
+		err:         err,
+		decode:      decode,
+	})
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends all queued commands and sets their results. The returned error
+// is only non-nil when the pipeline as a whole failed, in which case every
+// result holds the same error. The queue is emptied, so the Pipeline can be
+// reused.
+func (p *Pipeline) Exec(ctx context.Context) error {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil
+	}
+	pd, ok := p.d.(PipelineDoer)
+	if !ok {
+		for _, cmd := range cmds {
+			if err := ctx.Err(); err != nil {
+				cmd.fail(err)
+				continue
+			}
+			res, err := p.hooks.do(ctx, p.d, cmd.Name, cmd.Args)
+			if err != nil {
+				cmd.fail(err)
+				continue
+			}
+			cmd.set(res)
+		}
+		return nil
+	}
+	pcmds := make([]PipelineCmd, len(cmds))
+	for i := range cmds {
+		pcmds[i] = cmds[i].PipelineCmd
+	}
+	res, err := p.doPipeline(ctx, pd, pcmds)
+	if err == nil && len(res) != len(cmds) {
+		err = fmt.Errorf("pipeline returned %d replies for %d commands", len(res), len(cmds))
+	}
+	if err != nil {
+		for _, cmd := range cmds {
+			cmd.fail(err)
+		}
+		return err
+	}
+	for i, cmd := range cmds {
+		if e, ok := res[i].(error); ok {
+			cmd.fail(e)
+			continue
+		}
+		cmd.set(res[i])
+	}
+	return nil
+}
+
+// doPipeline sends cmds with pd and runs the hooks around every command. The
+// duration of every command is the duration of the pipeline.
+func (p *Pipeline) doPipeline(ctx context.Context, pd PipelineDoer, cmds []PipelineCmd) ([]interface{}, error) {
+	if len(p.hooks) == 0 {
+		return pd.DoPipeline(ctx, cmds)
+	}
+	es := make([]*CmdEvent, len(cmds))
+	ctxs := make([]context.Context, len(cmds))
+	for i, cmd := range cmds {
+		es[i] = newCmdEvent(cmd.Name, cmd.Args)
+		ctxs[i] = p.hooks.before(ctx, es[i])
+	}
+	res, err := pd.DoPipeline(ctx, cmds)
+	for i := range cmds {
+		var v interface{}
+		cerr := err
+		if err == nil && i < len(res) {
+			v = res[i]
+			cerr, _ = v.(error)
+		}
+		p.hooks.after(ctxs[i], es[i], v, cerr)
+	}
+	return res, err
+}
+
+// CmdResult is the result of a queued Cmd.
+type CmdResult struct {
+	v   interface{}
+	err error
+}
+
+// Val returns the reply decoded the same way as Client.DoCmd does.
+func (r *CmdResult) Val() interface{} {
+	return r.v
+}
+
+func (r *CmdResult) Err() error {
+	return r.err
+}
+
+// StatusResult is the result of a queued command which only reports an error.
+type StatusResult struct {
+	err error
+}
+
+func (r *StatusResult) Err() error {
+	return r.err
+}
+
+// TimeResult is the result of a queued command which returns a timestamp.
+type TimeResult struct {
+	t   time.Time
+	err error
+}
+
+func (r *TimeResult) Val() time.Time {
+	return r.t
+}
+
+func (r *TimeResult) Err() error {
+	return r.err
+}
+
+// IntResult is the result of a queued command which returns an integer.
+type IntResult struct {
+	n   int64
+	err error
+}
+
+func (r *IntResult) Val() int64 {
+	return r.n
+}
+
+func (r *IntResult) Err() error {
+	return r.err
+}
+
+// MultiResultsResult is the result of a queued MAdd.
+type MultiResultsResult struct {
+	rs  []MultiResult
+	err error
+}
+
+func (r *MultiResultsResult) Val() []MultiResult {
+	return r.rs
+}
+
+func (r *MultiResultsResult) Err() error {
+	return r.err
+}
+
+// DataPointsResult is the result of a queued Range or RevRange.
+type DataPointsResult struct {
+	ds  []DataPoint
+	err error
+}
+
+func (r *DataPointsResult) Val() []DataPoint {
+	return r.ds
+}
+
+func (r *DataPointsResult) Err() error {
+	return r.err
+}
+
+// TimeSeriesResult is the result of a queued MRange or MRevRange.
+type TimeSeriesResult struct {
+	ds  []TimeSeries
+	err error
+}
+
+func (r *TimeSeriesResult) Val() []TimeSeries {
+	return r.ds
+}
+
+func (r *TimeSeriesResult) Err() error {
+	return r.err
+}
+
+// DataPointResult is the result of a queued Get.
+type DataPointResult struct {
+	d   *DataPoint
+	err error
+}
+
+func (r *DataPointResult) Val() *DataPoint {
+	return r.d
+}
+
+func (r *DataPointResult) Err() error {
+	return r.err
+}
+
+// LastDatapointsResult is the result of a queued MGet.
+type LastDatapointsResult struct {
+	ds  []LastDatapoint
+	err error
+}
+
+func (r *LastDatapointsResult) Val() []LastDatapoint {
+	return r.ds
+}
+
+func (r *LastDatapointsResult) Err() error {
+	return r.err
+}
+
+// InfoResult is the result of a queued Info.
+type InfoResult struct {
+	inf Info
+	err error
+}
+
+func (r *InfoResult) Val() Info {
+	return r.inf
+}
+
+func (r *InfoResult) Err() error {
+	return r.err
+}
+
+// KeysResult is the result of a queued QueryIndex.
+type KeysResult struct {
+	keys []string
+	err  error
+}
+
+func (r *KeysResult) Val() []string {
+	return r.keys
+}
+
+func (r *KeysResult) Err() error {
+	return r.err
+}
+
+func (p *Pipeline) status(cmd Cmd) *StatusResult {
+	r := &StatusResult{}
+	p.queue(cmd, &r.err, func(interface{}) error {
+		return nil
+	})
+	return r
+}
+
+func (p *Pipeline) time(cmd Cmd) *TimeResult {
+	r := &TimeResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.t, err = parseTime(res)
+		return err
+	})
+	return r
+}
+
+// Create queues a TS.CREATE command.
+func (p *Pipeline) Create(key string, options ...OptionCreate) *StatusResult {
+	cmd := NewCmdCreate(key, options...)
+	return p.status(cmd)
+}
+
+// Alter queues a TS.ALTER command.
+func (p *Pipeline) Alter(key string, options ...OptionAlter) *StatusResult {
+	cmd := NewCmdAlter(key, options...)
+	return p.status(cmd)
+}
+
+// Add queues a TS.ADD command.
+func (p *Pipeline) Add(s Sample, options ...OptionAdd) *TimeResult {
+	cmd := NewCmdAdd(s, options...)
+	return p.time(cmd)
+}
+
+// MAdd queues a TS.MADD command.
+func (p *Pipeline) MAdd(s []Sample) *MultiResultsResult {
+	cmd := NewCmdMAdd(s)
+	r := &MultiResultsResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		if r.rs, err = parseMultiResults(res); err != nil {
+			return err
+		}
+		wrapMultiResults(cmd, r.rs)
+		return nil
+	})
+	return r
+}
+
+// IncrBy queues a TS.INCRBY command.
+func (p *Pipeline) IncrBy(key string, value float64, options ...OptionCounter) *TimeResult {
+	return p.counter(NewCmdIncrBy(key, value, options...))
+}
+
+// DecrBy queues a TS.DECRBY command.
+func (p *Pipeline) DecrBy(key string, value float64, options ...OptionCounter) *TimeResult {
+	return p.counter(NewCmdDecrBy(key, value, options...))
+}
+
+func (p *Pipeline) counter(cmd *CmdCounter) *TimeResult {
+	return p.time(cmd)
+}
+
+// Del queues a TS.DEL command.
+func (p *Pipeline) Del(key string, from time.Time, to time.Time) *IntResult {
+	cmd := NewCmdDel(key, from, to)
+	r := &IntResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.n, err = parseInt64("deleted", res)
+		return err
+	})
+	return r
+}
+
+// CreateRule queues a TS.CREATERULE command.
+func (p *Pipeline) CreateRule(srcKey, destKey string, a AggregationType, bucket Duration, options ...OptionCreateRule) *StatusResult {
+	cmd := NewCmdCreateRule(srcKey, destKey, a, bucket, options...)
+	return p.status(cmd)
+}
+
+// DeleteRule queues a TS.DELETERULE command.
+func (p *Pipeline) DeleteRule(srcKey, destKey string) *StatusResult {
+	cmd := NewCmdDeleteRule(srcKey, destKey)
+	return p.status(cmd)
+}
+
+// Range queues a TS.RANGE command.
+func (p *Pipeline) Range(key string, from Timestamp, to Timestamp, options ...OptionRanger) *DataPointsResult {
+	return p.ranger(NewCmdRange(key, from, to, options...))
+}
+
+// RevRange queues a TS.REVRANGE command.
+func (p *Pipeline) RevRange(key string, from Timestamp, to Timestamp, options ...OptionRanger) *DataPointsResult {
+	return p.ranger(NewCmdRevRange(key, from, to, options...))
+}
+
+func (p *Pipeline) ranger(cmd *CmdRanger) *DataPointsResult {
+	r := &DataPointsResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.ds, err = parseDataPoints(res)
+		return err
+	})
+	return r
+}
+
+// MRange queues a TS.MRANGE command.
+func (p *Pipeline) MRange(from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) *TimeSeriesResult {
+	return p.mRanger(NewCmdMRange(from, to, filters, options...))
+}
+
+// MRevRange queues a TS.MREVRANGE command.
+func (p *Pipeline) MRevRange(from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) *TimeSeriesResult {
+	return p.mRanger(NewCmdMRevRange(from, to, filters, options...))
+}
+
+func (p *Pipeline) mRanger(cmd *CmdMRanger) *TimeSeriesResult {
+	r := &TimeSeriesResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.ds, err = parseTimeSeriesList(res)
+		return err
+	})
+	return r
+}
+
+// Get queues a TS.GET command.
+func (p *Pipeline) Get(key string) *DataPointResult {
+	cmd := NewCmdGet(key)
+	r := &DataPointResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.d, err = parseLastDataPoint(res)
+		return err
+	})
+	return r
+}
+
+// MGet queues a TS.MGET command.
+func (p *Pipeline) MGet(filters []Filter, options ...OptionMGet) *LastDatapointsResult {
+	cmd := NewCmdMGet(filters, options...)
+	r := &LastDatapointsResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.ds, err = parseLastDatapoints(res)
+		return err
+	})
+	return r
+}
+
+// Info queues a TS.INFO command.
+func (p *Pipeline) Info(key string, options ...OptionInfo) *InfoResult {
+	cmd := NewCmdInfo(key, options...)
+	r := &InfoResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.inf, err = parseInfo(res)
+		return err
+	})
+	return r
+}
+
+// QueryIndex queues a TS.QUERYINDEX command.
+func (p *Pipeline) QueryIndex(filters []Filter) *KeysResult {
+	cmd := NewCmdQueryIndex(filters)
+	r := &KeysResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.keys, err = parseKeys(res)
+		return err
+	})
+	return r
+}
+
+// DoCmd queues cmd.
+func (p *Pipeline) DoCmd(cmd Cmd) *CmdResult {
+	r := &CmdResult{}
+	p.queue(cmd, &r.err, func(res interface{}) (err error) {
+		r.v, err = parseReply(cmd, res)
+		return err
+	})
+	return r
+}
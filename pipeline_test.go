@@ -0,0 +1,138 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type replyDoer struct {
+	cmds    []PipelineCmd
+	replies []interface{}
+}
+
+func (d *replyDoer) Do(_ context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	d.cmds = append(d.cmds, PipelineCmd{Name: cmd, Args: args})
+	res := d.replies[0]
+	d.replies = d.replies[1:]
+	if err, ok := res.(error); ok {
+		return nil, err
+	}
+	return res, nil
+}
+
+type replyPipelineDoer struct {
+	replyDoer
+	calls int
+}
+
+func (d *replyPipelineDoer) DoPipeline(_ context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	d.calls++
+	d.cmds = append(d.cmds, cmds...)
+	res := d.replies[:len(cmds)]
+	d.replies = d.replies[len(cmds):]
+	return res, nil
+}
+
+func TestPipeline_Exec(t *testing.T) {
+	errAny := errors.New("any error")
+	replies := []interface{}{
+		"OK",
+		int64(1000),
+		errAny,
+		[]interface{}{[]interface{}{int64(1000), "0.5"}},
+	}
+	wantCmds := []PipelineCmd{
+		{Name: "TS.CREATE", Args: []interface{}{"key:any"}},
+		{Name: "TS.ADD", Args: []interface{}{"key:any", int64(1000), 0.5}},
+		{Name: "TS.INCRBY", Args: []interface{}{"key:other", 0.5}},
+		{Name: "TS.RANGE", Args: []interface{}{"key:any", "-", "+"}},
+	}
+	check := func(t *testing.T, d Doer, cmds func() []PipelineCmd) {
+		p := NewClient(d).Pipeline()
+		r1 := p.Create("key:any")
+		r2 := p.Add(NewSample("key:any", time.UnixMilli(1000), 0.5))
+		r3 := p.IncrBy("key:other", 0.5)
+		r4 := p.Range("key:any", TSMin(), TSMax())
+		if got, want := p.Len(), 4; got != want {
+			t.Errorf("Len() = %v, want %v", got, want)
+		}
+		if err := p.Exec(context.Background()); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		if got := cmds(); !reflect.DeepEqual(got, wantCmds) {
+			t.Errorf("cmds = %v, want %v", got, wantCmds)
+		}
+		if err := r1.Err(); err != nil {
+			t.Errorf("Create() error = %v", err)
+		}
+		if got, want := r2.Val(), time.UnixMilli(1000); got != want {
+			t.Errorf("Add() got = %v, want %v", got, want)
+		}
+		if got, want := r3.Err(), errAny; !errors.Is(got, want) {
+			t.Errorf("IncrBy() error = %v, want %v", got, want)
+		}
+		if got, want := r4.Val(), []DataPoint{{time.UnixMilli(1000), 0.5}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Range() got = %v, want %v", got, want)
+		}
+		if got, want := p.Len(), 0; got != want {
+			t.Errorf("Len() = %v, want %v", got, want)
+		}
+	}
+	t.Run("sequential", func(t *testing.T) {
+		d := &replyDoer{replies: replies}
+		check(t, d, func() []PipelineCmd { return d.cmds })
+	})
+	t.Run("pipeline", func(t *testing.T) {
+		d := &replyPipelineDoer{replyDoer: replyDoer{replies: replies}}
+		check(t, d, func() []PipelineCmd { return d.cmds })
+		if got, want := d.calls, 1; got != want {
+			t.Errorf("DoPipeline() calls = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestClient_Pipeline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip client test")
+	}
+	for _, tt := range doerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("example:%s", t.Name())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			doer, err := tt.doer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doer.Close()
+			defer doer.Do(context.Background(), "DEL", key)
+
+			p := NewClient(doer).Pipeline()
+			create := p.Create(key, CreateWithDuplicatePolicy(DuplicatePolicyBlock))
+			add := p.Add(NewSample(key, secondMillennium, 1))
+			dup := p.Add(NewSample(key, secondMillennium, 2))
+			points := p.Range(key, TSMin(), TSMax())
+			if err := p.Exec(ctx); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			if err := create.Err(); err != nil {
+				t.Errorf("Create() error = %v", err)
+			}
+			if got, want := add.Val(), secondMillennium; got != want {
+				t.Errorf("Add() got = %v, want %v", got, want)
+			}
+			if err := dup.Err(); err == nil {
+				t.Errorf("Add() error = %v, wantErr %v", err, true)
+			}
+			if got, want := points.Val(), []DataPoint{{secondMillennium, 1}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Range() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package redists
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by Pool after Close.
+var ErrPoolClosed = errors.New("redists: pool is closed")
+
+// aLongTimeAgo is a deadline which interrupts the I/O of a connection.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// conn is a connection which speaks RESP.
+type conn struct {
+	nc       net.Conn
+	br       *bufio.Reader
+	buf      []byte
+	lastUsed time.Time
+	broken   bool
+}
+
+// roundTrip sends the commands and reads a reply for each of them.
+func (c *conn) roundTrip(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	c.buf = c.buf[:0]
+	for _, cmd := range cmds {
+		c.buf = appendCommand(c.buf, cmd.Name, cmd.Args)
+	}
+	res := make([]interface{}, len(cmds))
+	if err := c.send(ctx, func() error { return c.readAll(res) }); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// send writes the buffered commands and reads the replies with read.
+// Deadlines and the cancellation of ctx apply to the I/O.
+func (c *conn) send(ctx context.Context, read func() error) error {
+	deadline, _ := ctx.Deadline()
+	if err := c.nc.SetDeadline(deadline); err != nil {
+		c.broken = true
+		return err
+	}
+	if done := ctx.Done(); done != nil {
+		finished := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			select {
+			case <-done:
+				c.nc.SetDeadline(aLongTimeAgo)
+			case <-finished:
+			}
+		}()
+		// the watcher must not touch the deadline of the next round trip
+		defer func() {
+			close(finished)
+			<-exited
+		}()
+	}
+	_, err := c.nc.Write(c.buf)
+	if err == nil {
+		err = read()
+	}
+	if err != nil {
+		// the replies are out of sync after an I/O error
+		c.broken = true
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// the deadline of the connection may expire before the one of ctx
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() && !deadline.IsZero() && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
+		return err
+	}
+	c.lastUsed = time.Now()
+	return nil
+}
+
+// readAll reads a reply for each element of res.
+func (c *conn) readAll(res []interface{}) error {
+	for i := range res {
+		var err error
+		if res[i], err = c.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads the next reply. Push messages are discarded, because the
+// connection does not subscribe to anything.
+func (c *conn) read() (interface{}, error) {
+	for {
+		b, err := c.br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readReply(c.br)
+		if err != nil || b[0] != '>' {
+			return v, err
+		}
+	}
+}
+
+// stream reads an array, set or map reply, and calls fn for each element
+// like StreamDoer. Error replies are returned as the error.
+func (c *conn) stream(fn func(key, val interface{}) error) error {
+	for {
+		b, err := c.br.Peek(1)
+		if err != nil {
+			return err
+		}
+		switch b[0] {
+		case '>':
+			if _, err := readReply(c.br); err != nil {
+				return err
+			}
+			continue
+		case '*', '~', '%':
+		default:
+			v, err := readReply(c.br)
+			if err != nil {
+				return err
+			}
+			if err, ok := v.(ServerError); ok {
+				return err
+			}
+			// e.g. a null reply, or a map after an attribute
+			return eachElement("reply", v, fn)
+		}
+		line, err := readLine(c.br)
+		if err != nil {
+			return err
+		}
+		isMap := line[0] == '%'
+		n, err := parseInt(line[1:])
+		if err != nil {
+			return err
+		}
+		for i := int64(0); i < n; i++ {
+			var key interface{}
+			if isMap {
+				if key, err = readReply(c.br); err != nil {
+					return err
+				}
+			}
+			val, err := readReply(c.br)
+			if err != nil {
+				return err
+			}
+			if err := fn(key, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// do sends a single command. An error reply is returned as the error.
+func (c *conn) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	res, err := c.roundTrip(ctx, []PipelineCmd{{Name: cmd, Args: args}})
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := res[0].(ServerError); ok {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+// PoolStats are the statistics of a Pool.
+type PoolStats struct {
+	// Conns is the number of open connections.
+	Conns int
+	// Idle is the number of idle connections.
+	Idle int
+}
+
+// Pool is a concurrency-safe Doer with a pool of native RESP connections. It
+// is returned by Dial.
+type Pool struct {
+	cfg dialConfig
+	sem chan struct{}
+
+	mu     sync.Mutex
+	idle   []*conn
+	open   int
+	closed bool
+}
+
+var (
+	_ PipelineDoer = (*Pool)(nil)
+	_ AppendDoer   = (*Pool)(nil)
+	_ StreamDoer   = (*Pool)(nil)
+)
+
+func newPool(cfg dialConfig) *Pool {
+	return &Pool{cfg: cfg, sem: make(chan struct{}, cfg.maxConns)}
+}
+
+// get returns an idle connection or a new one. It waits when the limit of
+// connections is reached.
+func (p *Pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.sem
+			return nil, ErrPoolClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		idle := time.Since(c.lastUsed)
+		if p.cfg.idleTimeout > 0 && idle > p.cfg.idleTimeout {
+			p.closeConn(c)
+			continue
+		}
+		if p.cfg.healthCheck > 0 && idle > p.cfg.healthCheck {
+			if _, err := c.do(ctx, "PING"); err != nil {
+				p.closeConn(c)
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					<-p.sem
+					return nil, ctxErr
+				}
+				continue
+			}
+		}
+		return c, nil
+	}
+	c, err := p.dial(ctx)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return c, nil
+}
+
+// put returns a connection to the pool.
+func (p *Pool) put(c *conn) {
+	p.mu.Lock()
+	if c.broken || p.closed || len(p.idle) >= p.cfg.maxIdle {
+		p.mu.Unlock()
+		p.closeConn(c)
+	} else {
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+	}
+	<-p.sem
+}
+
+func (p *Pool) closeConn(c *conn) {
+	c.nc.Close()
+	p.mu.Lock()
+	p.open--
+	p.mu.Unlock()
+}
+
+// dial opens a connection and runs the handshake: HELLO for RESP3, AUTH and
+// SELECT.
+func (p *Pool) dial(ctx context.Context) (*conn, error) {
+	nc, err := p.cfg.dialer(ctx, p.cfg.network, p.cfg.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{nc: nc, br: bufio.NewReader(nc), lastUsed: time.Now()}
+	if err := p.handshake(ctx, c); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	p.mu.Lock()
+	p.open++
+	p.mu.Unlock()
+	return c, nil
+}
+
+func (p *Pool) handshake(ctx context.Context, c *conn) error {
+	cfg := p.cfg
+	if cfg.protocol == 3 {
+		args := []interface{}{"3"}
+		if cfg.password != "" {
+			user := cfg.username
+			if user == "" {
+				user = "default"
+			}
+			args = append(args, "AUTH", user, cfg.password)
+		}
+		if _, err := c.do(ctx, "HELLO", args...); err != nil {
+			return err
+		}
+	} else if cfg.password != "" {
+		args := []interface{}{cfg.password}
+		if cfg.username != "" {
+			args = []interface{}{cfg.username, cfg.password}
+		}
+		if _, err := c.do(ctx, "AUTH", args...); err != nil {
+			return err
+		}
+	}
+	if cfg.db != 0 {
+		if _, err := c.do(ctx, "SELECT", cfg.db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pool) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	c, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.put(c)
+	return c.do(ctx, cmd, args...)
+}
+
+// DoAppender sends cmd with the arguments encoded by a, without converting
+// them to interface values.
+func (p *Pool) DoAppender(ctx context.Context, cmd string, a *Appender) (interface{}, error) {
+	c, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.put(c)
+	c.buf = a.AppendCommand(c.buf[:0], cmd)
+	var res [1]interface{}
+	if err := c.send(ctx, func() error { return c.readAll(res[:]) }); err != nil {
+		return nil, err
+	}
+	if err, ok := res[0].(ServerError); ok {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+// DoStream sends cmd and reads the elements of an array or map reply one by
+// one. When fn returns an error, the rest of the reply is not read, and the
+// connection is closed.
+func (p *Pool) DoStream(ctx context.Context, cmd string, args []interface{}, fn func(key, val interface{}) error) error {
+	c, err := p.get(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.put(c)
+	c.buf = appendCommand(c.buf[:0], cmd, args)
+	var fnErr error
+	err = c.send(ctx, func() error {
+		return c.stream(func(key, val interface{}) error {
+			fnErr = fn(key, val)
+			return fnErr
+		})
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// DoPipeline sends the commands on a single connection with one write.
+func (p *Pool) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	c, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.put(c)
+	return c.roundTrip(ctx, cmds)
+}
+
+// Stats returns the statistics of the pool.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{Conns: p.open, Idle: len(p.idle)}
+}
+
+// Close closes the idle connections, and the connections in use when they
+// are returned. Commands sent after Close fail with ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, c := range idle {
+		p.closeConn(c)
+	}
+	return nil
+}
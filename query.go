@@ -2,7 +2,6 @@ package redists
 
 import (
 	"context"
-	"strconv"
 	"time"
 )
 
@@ -11,9 +10,30 @@ type DataPoint struct {
 	Value     float64
 }
 
-func parseDataPoint(is []interface{}) DataPoint {
-	v, _ := strconv.ParseFloat(parseString(is[1]), 64)
-	return DataPoint{Timestamp: time.UnixMilli(is[0].(int64)), Value: v}
+func parseDataPoint(val interface{}) (DataPoint, error) {
+	ts, v, err := parseRawDataPoint(val)
+	if err != nil {
+		return DataPoint{}, err
+	}
+	return DataPoint{Timestamp: time.UnixMilli(ts), Value: v}, nil
+}
+
+// parseRawDataPoint returns the timestamp in milliseconds and the value of a
+// data point.
+func parseRawDataPoint(val interface{}) (int64, float64, error) {
+	is, err := parseTuple("DataPoint", val, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	ts, err := parseInt64("DataPoint.Timestamp", is[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	v, err := parseFloat64("DataPoint.Value", is[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return ts, v, nil
 }
 
 const (
@@ -23,7 +43,8 @@ const (
 
 type nameRanger string
 
-type cmdRanger struct {
+// CmdRanger is the TS.RANGE or TS.REVRANGE command.
+type CmdRanger struct {
 	name        nameRanger
 	key         string
 	from        Timestamp
@@ -35,15 +56,33 @@ type cmdRanger struct {
 	aggregation *Aggregation
 }
 
-func newCmdRanger(name nameRanger, key string, from Timestamp, to Timestamp) *cmdRanger {
-	return &cmdRanger{name: name, key: key, from: from, to: to}
+func newCmdRanger(name nameRanger, key string, from Timestamp, to Timestamp) *CmdRanger {
+	return &CmdRanger{name: name, key: key, from: from, to: to}
+}
+
+// NewCmdRange returns a TS.RANGE command with the given options applied.
+func NewCmdRange(key string, from Timestamp, to Timestamp, options ...OptionRanger) *CmdRanger {
+	cmd := newCmdRanger(nameRange, key, from, to)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
 }
 
-func (c *cmdRanger) Name() string {
+// NewCmdRevRange returns a TS.REVRANGE command with the given options applied.
+func NewCmdRevRange(key string, from Timestamp, to Timestamp, options ...OptionRanger) *CmdRanger {
+	cmd := newCmdRanger(nameRevRange, key, from, to)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdRanger) Name() string {
 	return string(c.name)
 }
 
-func (c *cmdRanger) Args() []interface{} {
+func (c *CmdRanger) Args() []interface{} {
 	args := []interface{}{c.key, timestampArg(c.from), timestampArg(c.to)}
 	if len(c.tsFilter) > 0 {
 		args = append(args, optionNameFilterByTS)
@@ -66,60 +105,92 @@ func (c *cmdRanger) Args() []interface{} {
 	return args
 }
 
-type OptionRanger func(cmd *cmdRanger)
+type OptionRanger func(cmd *CmdRanger)
 
 // Range queries a range in forward direction.
 func (c *Client) Range(ctx context.Context, key string, from Timestamp, to Timestamp, options ...OptionRanger) ([]DataPoint, error) {
-	return c.ranger(ctx, nameRange, key, from, to, options...)
+	return c.ranger(ctx, NewCmdRange(key, from, to, options...))
 }
 
 // RevRange queries a range in reverse direction.
 func (c *Client) RevRange(ctx context.Context, key string, from Timestamp, to Timestamp, options ...OptionRanger) ([]DataPoint, error) {
-	return c.ranger(ctx, nameRevRange, key, from, to, options...)
+	return c.ranger(ctx, NewCmdRevRange(key, from, to, options...))
 }
 
-func (c *Client) ranger(ctx context.Context, name nameRanger, key string, from Timestamp, to Timestamp, options ...OptionRanger) ([]DataPoint, error) {
-	cmd := newCmdRanger(name, key, from, to)
-	for i := range options {
-		options[i](cmd)
+func (c *Client) ranger(ctx context.Context, cmd *CmdRanger) ([]DataPoint, error) {
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := parseDataPoints(res)
+	return ds, wrapError(cmd, err)
+}
+
+// RangeFunc queries a range in forward direction like Range, and calls fn
+// for each data point as soon as it is decoded instead of returning them in a
+// slice. With a StreamDoer such as Pool, the reply is read while fn runs, so a
+// slow fn slows down the read. When fn returns an error, RangeFunc stops and
+// returns it as is.
+func (c *Client) RangeFunc(ctx context.Context, key string, from Timestamp, to Timestamp, fn func(DataPoint) error, options ...OptionRanger) error {
+	return c.rangerFunc(ctx, NewCmdRange(key, from, to, options...), fn)
+}
+
+// RevRangeFunc queries a range in reverse direction like RevRange, and calls
+// fn for each data point like RangeFunc.
+func (c *Client) RevRangeFunc(ctx context.Context, key string, from Timestamp, to Timestamp, fn func(DataPoint) error, options ...OptionRanger) error {
+	return c.rangerFunc(ctx, NewCmdRevRange(key, from, to, options...), fn)
+}
+
+func (c *Client) rangerFunc(ctx context.Context, cmd *CmdRanger, fn func(DataPoint) error) error {
+	return c.stream(ctx, cmd, "DataPoints", func(_, val interface{}) error {
+		d, err := parseDataPoint(val)
+		if err != nil {
+			return wrapError(cmd, err)
+		}
+		return fn(d)
+	})
+}
+
+func parseDataPoints(res interface{}) ([]DataPoint, error) {
+	is, err := parseArray("DataPoints", res)
+	if err != nil || is == nil {
+		return nil, err
 	}
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
-	var ds []DataPoint
-	if is, ok := res.([]interface{}); ok {
-		ds = make([]DataPoint, len(is))
-		for i := range is {
-			ds[i] = parseDataPoint(is[i].([]interface{}))
+	ds := make([]DataPoint, len(is))
+	for i := range is {
+		if ds[i], err = parseDataPoint(is[i]); err != nil {
+			return nil, err
 		}
 	}
-	return ds, err
+	return ds, nil
 }
 
 func RangerWithTSFilter(tss ...time.Time) OptionRanger {
-	return func(cmd *cmdRanger) {
+	return func(cmd *CmdRanger) {
 		cmd.tsFilter = tss
 	}
 }
 
 func RangerWithValueFilter(min float64, max float64) OptionRanger {
-	return func(cmd *cmdRanger) {
+	return func(cmd *CmdRanger) {
 		cmd.valueFilter = &valueFilter{min: min, max: max}
 	}
 }
 
 func RangerWithCount(c int64) OptionRanger {
-	return func(cmd *cmdRanger) {
+	return func(cmd *CmdRanger) {
 		cmd.count = &c
 	}
 }
 
 func RangerWithAlign(a Timestamp) OptionRanger {
-	return func(cmd *cmdRanger) {
+	return func(cmd *CmdRanger) {
 		cmd.align = &a
 	}
 }
 
 func RangerWithAggregation(t AggregationType, bucket Duration) OptionRanger {
-	return func(cmd *cmdRanger) {
+	return func(cmd *CmdRanger) {
 		cmd.aggregation = &Aggregation{Type: t, Bucket: bucket}
 	}
 }
@@ -130,23 +201,28 @@ type TimeSeries struct {
 	DataPoints []DataPoint
 }
 
-func parseTimeSeries(is []interface{}) TimeSeries {
-	var ls Labels
-	if ils, ok := is[1].([]interface{}); ok {
-		ls = parseLabels(ils)
+func parseTimeSeries(val interface{}) (TimeSeries, error) {
+	is, err := parseTuple("TimeSeries", val, 3)
+	if err != nil {
+		return TimeSeries{}, err
 	}
-	var dps []DataPoint
-	if idps, ok := is[2].([]interface{}); ok {
-		dps = make([]DataPoint, len(idps))
-		for i := range idps {
-			dps[i] = parseDataPoint(idps[i].([]interface{}))
-		}
+	key, err := parseString("TimeSeries.Key", is[0])
+	if err != nil {
+		return TimeSeries{}, err
+	}
+	ls, err := parseLabels("TimeSeries.Labels", is[1])
+	if err != nil {
+		return TimeSeries{}, err
+	}
+	dps, err := parseDataPoints(is[2])
+	if err != nil {
+		return TimeSeries{}, err
 	}
 	return TimeSeries{
-		Key:        parseString(is[0]),
+		Key:        key,
 		Labels:     ls,
 		DataPoints: dps,
-	}
+	}, nil
 }
 
 const (
@@ -156,7 +232,8 @@ const (
 
 type nameMRanger string
 
-type cmdMRanger struct {
+// CmdMRanger is the TS.MRANGE or TS.MREVRANGE command.
+type CmdMRanger struct {
 	name        nameMRanger
 	from        Timestamp
 	to          Timestamp
@@ -170,15 +247,33 @@ type cmdMRanger struct {
 	groupBy     *GroupBy
 }
 
-func newCmdMRanger(name nameMRanger, from Timestamp, to Timestamp, filters []Filter) *cmdMRanger {
-	return &cmdMRanger{name: name, from: from, to: to, filters: filters}
+func newCmdMRanger(name nameMRanger, from Timestamp, to Timestamp, filters []Filter) *CmdMRanger {
+	return &CmdMRanger{name: name, from: from, to: to, filters: filters}
 }
 
-func (c *cmdMRanger) Name() string {
+// NewCmdMRange returns a TS.MRANGE command with the given options applied.
+func NewCmdMRange(from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) *CmdMRanger {
+	cmd := newCmdMRanger(nameMRange, from, to, filters)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+// NewCmdMRevRange returns a TS.MREVRANGE command with the given options applied.
+func NewCmdMRevRange(from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) *CmdMRanger {
+	cmd := newCmdMRanger(nameMRevRange, from, to, filters)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdMRanger) Name() string {
 	return string(c.name)
 }
 
-func (c *cmdMRanger) Args() []interface{} {
+func (c *CmdMRanger) Args() []interface{} {
 	args := []interface{}{timestampArg(c.from), timestampArg(c.to)}
 	if len(c.tsFilter) > 0 {
 		args = append(args, optionNameFilterByTS)
@@ -218,48 +313,134 @@ func (c *cmdMRanger) Args() []interface{} {
 	return args
 }
 
-type OptionMRanger func(cmd *cmdMRanger)
+type OptionMRanger func(cmd *CmdMRanger)
 
 // MRange queries a range across multiple time-series by filters in forward direction.
 func (c *Client) MRange(ctx context.Context, from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) ([]TimeSeries, error) {
-	return c.mRanger(ctx, nameMRange, from, to, filters, options...)
+	return c.mRanger(ctx, NewCmdMRange(from, to, filters, options...))
 }
 
 // MRevRange queries a range across multiple time-series by filters in reverse direction.
 func (c *Client) MRevRange(ctx context.Context, from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) ([]TimeSeries, error) {
-	return c.mRanger(ctx, nameMRevRange, from, to, filters, options...)
+	return c.mRanger(ctx, NewCmdMRevRange(from, to, filters, options...))
 }
 
-func (c *Client) mRanger(ctx context.Context, name nameMRanger, from Timestamp, to Timestamp, filters []Filter, options ...OptionMRanger) ([]TimeSeries, error) {
-	cmd := newCmdMRanger(name, from, to, filters)
-	for i := range options {
-		options[i](cmd)
+func (c *Client) mRanger(ctx context.Context, cmd *CmdMRanger) ([]TimeSeries, error) {
+	if cd, ok := c.d.(ClusterDoer); ok {
+		return c.clusterMRanger(ctx, cd, cmd)
 	}
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
-	var ds []TimeSeries
-	if is, ok := res.([]interface{}); ok {
-		ds = make([]TimeSeries, len(is))
-		for i := range is {
-			ds[i] = parseTimeSeries(is[i].([]interface{}))
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := parseTimeSeriesList(res)
+	return ds, wrapError(cmd, err)
+}
+
+// MRangeFunc queries a range across multiple time-series like MRange, and
+// calls fn for each time-series as soon as it is decoded instead of returning
+// them in a slice. With a StreamDoer such as Pool, the reply is read while fn
+// runs, so a slow fn slows down the read. When fn returns an error,
+// MRangeFunc stops and returns it as is.
+//
+// With a ClusterDoer, the replies of the shards are merged first like MRange.
+func (c *Client) MRangeFunc(ctx context.Context, from Timestamp, to Timestamp, filters []Filter, fn func(TimeSeries) error, options ...OptionMRanger) error {
+	return c.mRangerFunc(ctx, NewCmdMRange(from, to, filters, options...), fn)
+}
+
+// MRevRangeFunc queries a range across multiple time-series like MRevRange,
+// and calls fn for each time-series like MRangeFunc.
+func (c *Client) MRevRangeFunc(ctx context.Context, from Timestamp, to Timestamp, filters []Filter, fn func(TimeSeries) error, options ...OptionMRanger) error {
+	return c.mRangerFunc(ctx, NewCmdMRevRange(from, to, filters, options...), fn)
+}
+
+func (c *Client) mRangerFunc(ctx context.Context, cmd *CmdMRanger, fn func(TimeSeries) error) error {
+	if cd, ok := c.d.(ClusterDoer); ok {
+		ds, err := c.clusterMRanger(ctx, cd, cmd)
+		if err != nil {
+			return err
+		}
+		for i := range ds {
+			if err := fn(ds[i]); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
+	return c.stream(ctx, cmd, "TimeSeries", func(key, val interface{}) error {
+		ts, err := parseTimeSeriesElement(key, val)
+		if err != nil {
+			return wrapError(cmd, err)
+		}
+		return fn(ts)
+	})
+}
+
+// parseTimeSeriesElement decodes an element of a RESP2 array, or an entry of
+// a RESP3 map with its key.
+func parseTimeSeriesElement(key, val interface{}) (TimeSeries, error) {
+	is, err := timeSeriesTuple(key, val)
+	if err != nil {
+		return TimeSeries{}, err
+	}
+	return parseTimeSeries(is)
+}
+
+// timeSeriesTuple returns an element of a RESP2 array, or an entry of a RESP3
+// map with its key as [key, labels, samples].
+func timeSeriesTuple(key, val interface{}) ([]interface{}, error) {
+	if key == nil {
+		return parseTuple("TimeSeries", val, 3)
 	}
-	return ds, err
+	// RESP3 replies map each key to [labels, samples] or, when the query
+	// aggregates or groups, to [labels, metadata, samples]
+	is, err := parseTuple("TimeSeries", val, 2)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{key, is[0], is[len(is)-1]}, nil
+}
+
+func parseTimeSeriesList(res interface{}) ([]TimeSeries, error) {
+	if es, ok, err := parseMap("TimeSeries", res); ok {
+		if err != nil {
+			return nil, err
+		}
+		ds := make([]TimeSeries, len(es))
+		for i, e := range es {
+			if ds[i], err = parseTimeSeriesElement(e.key, e.val); err != nil {
+				return nil, err
+			}
+		}
+		return ds, nil
+	}
+	is, err := parseArray("TimeSeries", res)
+	if err != nil || is == nil {
+		return nil, err
+	}
+	ds := make([]TimeSeries, len(is))
+	for i := range is {
+		if ds[i], err = parseTimeSeries(is[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ds, nil
 }
 
 func MRangerWithTSFilter(tss ...time.Time) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		cmd.tsFilter = tss
 	}
 }
 
 func MRangerWithValueFilter(min float64, max float64) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		cmd.valueFilter = &valueFilter{min: min, max: max}
 	}
 }
 
 func MRangerWithLabels(labels ...string) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		if labels == nil {
 			cmd.withLabels = []string{}
 		} else {
@@ -269,25 +450,25 @@ func MRangerWithLabels(labels ...string) OptionMRanger {
 }
 
 func MRangerWithCount(c int64) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		cmd.count = &c
 	}
 }
 
 func MRangerWithAlign(a Timestamp) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		cmd.align = &a
 	}
 }
 
 func MRangerWithAggregation(t AggregationType, bucket Duration) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		cmd.aggregation = &Aggregation{Type: t, Bucket: bucket}
 	}
 }
 
 func MRangerWithGroupBy(label string, reducer ReducerType) OptionMRanger {
-	return func(cmd *cmdMRanger) {
+	return func(cmd *CmdMRanger) {
 		cmd.groupBy = &GroupBy{Label: label, Reducer: reducer}
 	}
 }
@@ -298,68 +479,100 @@ type LastDatapoint struct {
 	DataPoint *DataPoint
 }
 
-func parseLastDatapoint(is []interface{}) LastDatapoint {
-	var ls Labels
-	if ils, ok := is[1].([]interface{}); ok {
-		ls = parseLabels(ils)
+func parseLastDatapoint(val interface{}) (LastDatapoint, error) {
+	is, err := parseTuple("LastDatapoint", val, 3)
+	if err != nil {
+		return LastDatapoint{}, err
+	}
+	key, err := parseString("LastDatapoint.Key", is[0])
+	if err != nil {
+		return LastDatapoint{}, err
 	}
-	var point *DataPoint
-	if ils := is[2].([]interface{}); len(ils) > 0 {
-		p := parseDataPoint(ils)
-		point = &p
+	ls, err := parseLabels("LastDatapoint.Labels", is[1])
+	if err != nil {
+		return LastDatapoint{}, err
+	}
+	point, err := parseLastDataPoint(is[2])
+	if err != nil {
+		return LastDatapoint{}, err
 	}
 	return LastDatapoint{
-		Key:       parseString(is[0]),
+		Key:       key,
 		Labels:    ls,
 		DataPoint: point,
-	}
+	}, nil
 }
 
-type cmdGet struct {
+// CmdGet is the TS.GET command.
+type CmdGet struct {
 	key string
 }
 
-func newCmdGet(key string) *cmdGet {
-	return &cmdGet{key: key}
+func newCmdGet(key string) *CmdGet {
+	return &CmdGet{key: key}
+}
+
+// NewCmdGet returns a TS.GET command.
+func NewCmdGet(key string) *CmdGet {
+	return newCmdGet(key)
 }
 
-func (c *cmdGet) Name() string {
+func (c *CmdGet) Name() string {
 	return "TS.GET"
 }
 
-func (c *cmdGet) Args() []interface{} {
+func (c *CmdGet) Args() []interface{} {
 	return []interface{}{c.key}
 }
 
 // Get gets the last sample.
 func (c *Client) Get(ctx context.Context, key string) (*DataPoint, error) {
-	cmd := newCmdGet(key)
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdGet(key)
+	res, err := c.do(ctx, cmd)
 	if err != nil {
 		return nil, err
 	}
-	is := res.([]interface{})
-	if len(is) == 0 {
-		return nil, nil
+	point, err := parseLastDataPoint(res)
+	return point, wrapError(cmd, err)
+}
+
+// parseLastDataPoint returns nil when the time-series is empty.
+func parseLastDataPoint(res interface{}) (*DataPoint, error) {
+	is, err := parseArray("DataPoint", res)
+	if err != nil || len(is) == 0 {
+		return nil, err
+	}
+	point, err := parseDataPoint(is)
+	if err != nil {
+		return nil, err
 	}
-	point := parseDataPoint(is)
 	return &point, nil
 }
 
-type cmdMGet struct {
+// CmdMGet is the TS.MGET command.
+type CmdMGet struct {
 	filters    []Filter
 	withLabels []string
 }
 
-func newCmdMGet(filters []Filter) *cmdMGet {
-	return &cmdMGet{filters: filters}
+func newCmdMGet(filters []Filter) *CmdMGet {
+	return &CmdMGet{filters: filters}
+}
+
+// NewCmdMGet returns a TS.MGET command with the given options applied.
+func NewCmdMGet(filters []Filter, options ...OptionMGet) *CmdMGet {
+	cmd := newCmdMGet(filters)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
 }
 
-func (c *cmdMGet) Name() string {
+func (c *CmdMGet) Name() string {
 	return "TS.MGET"
 }
 
-func (c *cmdMGet) Args() []interface{} {
+func (c *CmdMGet) Args() []interface{} {
 	var args []interface{}
 	if c.withLabels != nil {
 		if len(c.withLabels) == 0 {
@@ -378,27 +591,55 @@ func (c *cmdMGet) Args() []interface{} {
 	return args
 }
 
-type OptionMGet func(cmd *cmdMGet)
+type OptionMGet func(cmd *CmdMGet)
 
 // MGet gets the last samples matching the specific filter.
 func (c *Client) MGet(ctx context.Context, filters []Filter, options ...OptionMGet) ([]LastDatapoint, error) {
-	cmd := newCmdMGet(filters)
-	for i := range options {
-		options[i](cmd)
+	cmd := NewCmdMGet(filters, options...)
+	if cd, ok := c.d.(ClusterDoer); ok {
+		return c.clusterMGet(ctx, cd, cmd)
+	}
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := parseLastDatapoints(res)
+	return ds, wrapError(cmd, err)
+}
+
+func parseLastDatapoints(res interface{}) ([]LastDatapoint, error) {
+	// RESP3 replies map each key to [labels, sample]
+	if es, ok, err := parseMap("LastDatapoints", res); ok {
+		if err != nil {
+			return nil, err
+		}
+		ds := make([]LastDatapoint, len(es))
+		for i, e := range es {
+			is, err := parseTuple("LastDatapoint", e.val, 2)
+			if err != nil {
+				return nil, err
+			}
+			if ds[i], err = parseLastDatapoint([]interface{}{e.key, is[0], is[1]}); err != nil {
+				return nil, err
+			}
+		}
+		return ds, nil
+	}
+	is, err := parseArray("LastDatapoints", res)
+	if err != nil || is == nil {
+		return nil, err
 	}
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
-	var ds []LastDatapoint
-	if is, ok := res.([]interface{}); ok {
-		ds = make([]LastDatapoint, len(is))
-		for i := range is {
-			ds[i] = parseLastDatapoint(is[i].([]interface{}))
+	ds := make([]LastDatapoint, len(is))
+	for i := range is {
+		if ds[i], err = parseLastDatapoint(is[i]); err != nil {
+			return nil, err
 		}
 	}
-	return ds, err
+	return ds, nil
 }
 
 func MGetWithLabels(labels ...string) OptionMGet {
-	return func(cmd *cmdMGet) {
+	return func(cmd *CmdMGet) {
 		if labels == nil {
 			cmd.withLabels = []string{}
 		} else {
@@ -2,8 +2,10 @@ package redists
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 )
@@ -149,6 +151,79 @@ func TestClient_Range(t *testing.T) {
 	}
 }
 
+func TestClient_RangeFunc(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip client test")
+	}
+	errStop := errors.New("stop")
+	for _, tt := range doerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("example:%s", t.Name())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			doer, err := tt.doer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doer.Close()
+			defer doer.Do(context.Background(), "DEL", key)
+
+			tsclient := NewClient(doer)
+			if err = tsclient.Create(ctx, key); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			_, err = tsclient.MAdd(ctx, []Sample{
+				NewSample(key, secondMillennium, 1),
+				NewSample(key, thirdMillennium, 2),
+				NewSample(key, thirdMillennium.Add(time.Minute), 3),
+			})
+			if err != nil {
+				t.Fatalf("MAdd() error = %v", err)
+			}
+			var got []DataPoint
+			err = tsclient.RevRangeFunc(ctx, key, TSMin(), TSMax(), func(d DataPoint) error {
+				got = append(got, d)
+				return nil
+			}, RangerWithCount(2))
+			if err != nil {
+				t.Errorf("RevRangeFunc() error = %v", err)
+			}
+			want := []DataPoint{
+				{thirdMillennium.Add(time.Minute), 3.0},
+				{thirdMillennium, 2.0},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("RevRangeFunc() got = %v, want %v", got, want)
+			}
+
+			got = nil
+			err = tsclient.RangeFunc(ctx, key, TSMin(), TSMax(), func(d DataPoint) error {
+				got = append(got, d)
+				return errStop
+			})
+			if err != errStop {
+				t.Errorf("RangeFunc() error = %v, want %v", err, errStop)
+			}
+			if want := []DataPoint{{secondMillennium, 1.0}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("RangeFunc() got = %v, want %v", got, want)
+			}
+			// the client is still usable after an early exit
+			if _, err := tsclient.Get(ctx, key); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+
+			err = tsclient.RangeFunc(ctx, key+":missing", TSMin(), TSMax(), func(DataPoint) error {
+				return nil
+			})
+			if !errors.Is(err, ErrKeyNotExist) {
+				t.Errorf("RangeFunc() error = %v, want %v", err, ErrKeyNotExist)
+			}
+		})
+	}
+}
+
 func TestCmdMRanger(t *testing.T) {
 	t.Run("range", func(t *testing.T) {
 		cmd := newCmdMRanger(nameMRange, secondMillennium, thirdMillennium, []Filter{FilterEqual("l", "v")})
@@ -369,6 +444,85 @@ func TestClient_MRange(t *testing.T) {
 	}
 }
 
+func TestClient_MRangeFunc(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip client test")
+	}
+	errStop := errors.New("stop")
+	for _, tt := range doerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := fmt.Sprintf("example:%s:", t.Name())
+			keys := []string{prefix + "a", prefix + "b", prefix + "c"}
+			filters := []Filter{FilterEqual("test", t.Name())}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			doer, err := tt.doer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doer.Close()
+			for _, key := range keys {
+				defer doer.Do(context.Background(), "DEL", key)
+			}
+
+			tsclient := NewClient(doer)
+			for i, key := range keys {
+				if err := tsclient.Create(ctx, key, CreateWithLabels(Labels{"test": t.Name()})); err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+				if _, err := tsclient.Add(ctx, NewSample(key, secondMillennium, float64(i))); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+			want, err := tsclient.MRevRange(ctx, TSMin(), TSMax(), filters, MRangerWithLabels())
+			if err != nil {
+				t.Fatalf("MRevRange() error = %v", err)
+			}
+			var got []TimeSeries
+			err = tsclient.MRevRangeFunc(ctx, TSMin(), TSMax(), filters, func(ts TimeSeries) error {
+				got = append(got, ts)
+				return nil
+			}, MRangerWithLabels())
+			if err != nil {
+				t.Errorf("MRevRangeFunc() error = %v", err)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].Key < got[j].Key })
+			sort.Slice(want, func(i, j int) bool { return want[i].Key < want[j].Key })
+			if len(want) != len(keys) || !reflect.DeepEqual(got, want) {
+				t.Errorf("MRevRangeFunc() got = %v, want %v", got, want)
+			}
+
+			calls := 0
+			err = tsclient.MRangeFunc(ctx, TSMin(), TSMax(), filters, func(TimeSeries) error {
+				calls++
+				return errStop
+			})
+			if err != errStop || calls != 1 {
+				t.Errorf("MRangeFunc() error = %v, calls = %d, want %v after 1 call", err, calls, errStop)
+			}
+
+			// the hooks need the whole reply, so the reply is not streamed
+			var e CmdEvent
+			hooked := NewClient(doer, ClientWithHooks(HookFuncs{After: func(_ context.Context, ev *CmdEvent) {
+				e = *ev
+			}}))
+			calls = 0
+			err = hooked.MRangeFunc(ctx, TSMin(), TSMax(), filters, func(TimeSeries) error {
+				calls++
+				return nil
+			})
+			if err != nil || calls != len(keys) {
+				t.Errorf("MRangeFunc() error = %v, calls = %d, want %d calls", err, calls, len(keys))
+			}
+			if e.Name != "TS.MRANGE" || e.ReplySize == 0 {
+				t.Errorf("AfterCmd() got = %+v", e)
+			}
+		})
+	}
+}
+
 func TestCmdGet(t *testing.T) {
 	cmd := newCmdGet("key:any")
 	if got, want := cmd.Name(), "TS.GET"; got != want {
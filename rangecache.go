@@ -0,0 +1,479 @@
+package redists
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RangeCacheStats are the statistics of a RangeCache.
+type RangeCacheStats struct {
+	// Hits is the number of queries served from the cache.
+	Hits int64
+	// Misses is the number of queries of settled windows which were sent to
+	// the server.
+	Misses int64
+	// Entries is the number of cached replies.
+	Entries int
+	// Size is the approximate size of the cached replies in bytes.
+	Size int
+	// Evictions is the number of replies removed to stay within the size
+	// limit.
+	Evictions int64
+	// Invalidations is the number of replies removed because a command
+	// changed their time-series.
+	Invalidations int64
+}
+
+// rangeCacheEntry is a cached reply.
+type rangeCacheEntry struct {
+	id       string
+	key      string
+	from, to int64
+	res      interface{}
+	size     int
+	expires  time.Time
+}
+
+// rangeCacheSeries is what RangeCache knows about a time-series.
+type rangeCacheSeries struct {
+	retention time.Duration
+	expires   time.Time
+}
+
+// RangeCache is a Doer which caches the replies of TS.RANGE and TS.REVRANGE
+// over windows which do not change anymore, e.g. the hourly aggregation of
+// yesterday which dashboards query again and again. The replies are keyed by
+// the exact command and arguments, and the least recently used ones are
+// evicted when the cache is full.
+//
+// A window is cached when it ends before now minus the settle time, it does
+// not use LATEST, and it starts after now minus the retention of the
+// time-series, so the server does not trim it while it is cached. The
+// retention and the compaction rules of a time-series are looked up with
+// TS.INFO.
+//
+// Writes sent through the RangeCache invalidate the replies they change:
+// TS.ADD, TS.MADD, TS.INCRBY and TS.DECRBY with a timestamp in a cached
+// window, TS.DEL, TS.ALTER and DEL. A change also invalidates the time-series
+// compacted from the changed one. Writes of other clients are not seen, so
+// the replies expire after a TTL, and Invalidate removes them explicitly.
+//
+// The replies are copied when they are stored and when they are served, so
+// callers may modify them.
+type RangeCache struct {
+	d       Doer
+	now     func() time.Time
+	settle  time.Duration
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	keys    map[string]map[*list.Element]bool
+	series  map[string]*rangeCacheSeries
+	// dests are the destinations of the compaction rules of a time-series.
+	dests map[string]map[string]bool
+	// gen changes with every invalidation, so replies which were read before
+	// it are not stored.
+	gen   uint64
+	stats RangeCacheStats
+}
+
+var _ PipelineDoer = (*RangeCache)(nil)
+
+type OptionRangeCache func(c *RangeCache)
+
+// NewRangeCache returns a RangeCache which sends commands to d. By default,
+// windows are cached one minute after they end for ten minutes, and the cache
+// holds 64 MiB of replies.
+func NewRangeCache(d Doer, options ...OptionRangeCache) *RangeCache {
+	c := &RangeCache{
+		d:       d,
+		now:     time.Now,
+		settle:  time.Minute,
+		ttl:     10 * time.Minute,
+		maxSize: 64 << 20,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+		keys:    map[string]map[*list.Element]bool{},
+		series:  map[string]*rangeCacheSeries{},
+		dests:   map[string]map[string]bool{},
+	}
+	for i := range options {
+		options[i](c)
+	}
+	return c
+}
+
+// RangeCacheWithSettleTime sets the time after which the end of a window is
+// not expected to get new samples.
+func RangeCacheWithSettleTime(d time.Duration) OptionRangeCache {
+	return func(c *RangeCache) {
+		c.settle = d
+	}
+}
+
+// RangeCacheWithTTL sets how long a reply is cached. Zero keeps the replies
+// until they are evicted or invalidated, and windows of time-series with a
+// retention are not cached then.
+func RangeCacheWithTTL(d time.Duration) OptionRangeCache {
+	return func(c *RangeCache) {
+		c.ttl = d
+	}
+}
+
+// RangeCacheWithMaxSize limits the approximate size of the cached replies in
+// bytes.
+func RangeCacheWithMaxSize(n int) OptionRangeCache {
+	return func(c *RangeCache) {
+		c.maxSize = n
+	}
+}
+
+// RangeCacheWithClock sets the clock which decides whether a window is
+// settled, and when replies expire.
+func RangeCacheWithClock(now func() time.Time) OptionRangeCache {
+	return func(c *RangeCache) {
+		c.now = now
+	}
+}
+
+func (c *RangeCache) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+	if name == string(nameRange) || name == string(nameRevRange) {
+		return c.doRange(ctx, cmd, args)
+	}
+	res, err := c.d.Do(ctx, cmd, args...)
+	c.invalidateCmd(name, args)
+	return res, err
+}
+
+// DoPipeline sends the commands without the cache, and invalidates the
+// replies which the writes of the pipeline change.
+func (c *RangeCache) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	res, err := doPipeline(ctx, c.d, cmds)
+	for _, cmd := range cmds {
+		c.invalidateCmd(strings.ToUpper(cmd.Name), cmd.Args)
+	}
+	return res, err
+}
+
+func (c *RangeCache) doRange(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	key, from, to, ok := rangeWindow(args)
+	now := c.now()
+	if !ok || to >= now.Add(-c.settle).UnixMilli() {
+		return c.d.Do(ctx, cmd, args...)
+	}
+	id := string(appendCommand(nil, strings.ToUpper(cmd), args))
+	c.mu.Lock()
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*rangeCacheEntry)
+		if c.ttl == 0 || now.Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return copyReply(e.res), nil
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	gen := c.gen
+	s := c.series[key]
+	if s != nil && c.ttl > 0 && !now.Before(s.expires) {
+		s = nil
+	}
+	c.mu.Unlock()
+	if s == nil {
+		var err error
+		if s, err = c.lookup(ctx, key, gen); err != nil {
+			// e.g. the key does not exist, which the query reports as well
+			return c.d.Do(ctx, cmd, args...)
+		}
+	}
+	cacheable := s.retention == 0 || (c.ttl > 0 && from > now.Add(c.ttl).Add(-s.retention).UnixMilli())
+	res, err := c.d.Do(ctx, cmd, args...)
+	if err != nil || !cacheable {
+		return res, err
+	}
+	c.store(&rangeCacheEntry{id: id, key: key, from: from, to: to, res: copyReply(res), size: len(id) + replySize(res), expires: now.Add(c.ttl)}, gen)
+	return res, nil
+}
+
+// lookup gets the retention and the compaction rules of a time-series with
+// TS.INFO.
+func (c *RangeCache) lookup(ctx context.Context, key string, gen uint64) (*rangeCacheSeries, error) {
+	res, err := c.d.Do(ctx, "TS.INFO", key)
+	if err != nil {
+		return nil, err
+	}
+	inf, err := parseInfo(res)
+	if err != nil {
+		return nil, err
+	}
+	s := &rangeCacheSeries{retention: inf.RetentionTime, expires: c.now().Add(c.ttl)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for dest := range inf.Rules {
+		c.addDest(key, dest)
+	}
+	if inf.SourceKey != "" {
+		c.addDest(inf.SourceKey, key)
+	}
+	if c.gen == gen {
+		c.series[key] = s
+	}
+	return s, nil
+}
+
+func (c *RangeCache) addDest(src, dest string) {
+	if c.dests[src] == nil {
+		c.dests[src] = map[string]bool{}
+	}
+	c.dests[src][dest] = true
+}
+
+// store caches e unless there was an invalidation since gen, and evicts the
+// least recently used replies when the cache is full.
+func (c *RangeCache) store(e *rangeCacheEntry, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen || e.size > c.maxSize {
+		return
+	}
+	if el, ok := c.entries[e.id]; ok {
+		c.remove(el)
+	}
+	el := c.lru.PushFront(e)
+	c.entries[e.id] = el
+	if c.keys[e.key] == nil {
+		c.keys[e.key] = map[*list.Element]bool{}
+	}
+	c.keys[e.key][el] = true
+	c.stats.Size += e.size
+	for c.stats.Size > c.maxSize {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *RangeCache) remove(el *list.Element) {
+	e := el.Value.(*rangeCacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, e.id)
+	delete(c.keys[e.key], el)
+	if len(c.keys[e.key]) == 0 {
+		delete(c.keys, e.key)
+	}
+	c.stats.Size -= e.size
+}
+
+// invalidateCmd invalidates the replies which a command changes.
+func (c *RangeCache) invalidateCmd(name string, args []interface{}) {
+	if readCmds[name] || len(args) == 0 {
+		return
+	}
+	key, _ := args[0].(string)
+	switch name {
+	case "TS.ADD":
+		if len(args) < 2 {
+			return
+		}
+		// "*" is the current time, which no cached window contains
+		if ts, ok := argInt64(args[1]); ok {
+			c.invalidate(key, ts, ts, false)
+		}
+	case "TS.MADD":
+		for i := 0; i+1 < len(args); i += 3 {
+			if ts, ok := argInt64(args[i+1]); ok {
+				key, _ := args[i].(string)
+				c.invalidate(key, ts, ts, false)
+			}
+		}
+	case "TS.INCRBY", "TS.DECRBY":
+		// without TIMESTAMP, the sample is added at the current time
+		for i := 2; i+1 < len(args); i++ {
+			if s, ok := args[i].(string); ok && strings.EqualFold(s, optionNameTimestamp) {
+				if ts, ok := argInt64(args[i+1]); ok {
+					c.invalidate(key, ts, ts, false)
+				}
+				break
+			}
+		}
+	case "TS.DEL":
+		from, to := int64(math.MinInt64), int64(math.MaxInt64)
+		if len(args) > 2 {
+			from, _ = rangeTimestamp(args[1])
+			to, _ = rangeTimestamp(args[2])
+		}
+		c.invalidate(key, from, to, false)
+	case "TS.ALTER":
+		c.invalidate(key, math.MinInt64, math.MaxInt64, true)
+	case "DEL":
+		for _, arg := range args {
+			key, _ := arg.(string)
+			c.invalidate(key, math.MinInt64, math.MaxInt64, true)
+		}
+	case "TS.CREATE", "TS.CREATERULE", "TS.DELETERULE":
+		// the retention or the compaction rules change
+		c.mu.Lock()
+		delete(c.series, key)
+		c.gen++
+		c.mu.Unlock()
+	case "FLUSHDB", "FLUSHALL":
+		c.Purge()
+	}
+}
+
+// invalidate removes the replies of key with a window overlapping from and
+// to, and every reply of the time-series compacted from key. When forget is
+// true, the information from TS.INFO is removed too.
+func (c *RangeCache) invalidate(key string, from, to int64, forget bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	seen := map[string]bool{}
+	var walk func(key string, from, to int64)
+	walk = func(key string, from, to int64) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		for el := range c.keys[key] {
+			e := el.Value.(*rangeCacheEntry)
+			if e.from <= to && from <= e.to {
+				c.remove(el)
+				c.stats.Invalidations++
+			}
+		}
+		if forget {
+			delete(c.series, key)
+		}
+		for dest := range c.dests[key] {
+			walk(dest, math.MinInt64, math.MaxInt64)
+		}
+	}
+	walk(key, from, to)
+	if forget {
+		delete(c.dests, key)
+	}
+}
+
+// Invalidate removes the replies of key and of the time-series compacted
+// from it, e.g. after another client changed it.
+func (c *RangeCache) Invalidate(key string) {
+	c.invalidate(key, math.MinInt64, math.MaxInt64, true)
+}
+
+// Purge removes every reply.
+func (c *RangeCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.lru.Init()
+	c.entries = map[string]*list.Element{}
+	c.keys = map[string]map[*list.Element]bool{}
+	c.series = map[string]*rangeCacheSeries{}
+	c.dests = map[string]map[string]bool{}
+	c.stats.Size = 0
+}
+
+// Stats returns the statistics of the cache.
+func (c *RangeCache) Stats() RangeCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.lru.Len()
+	return s
+}
+
+// rangeWindow returns the key and the window of the arguments of TS.RANGE
+// or TS.REVRANGE. It reports false when the window is open, or it uses
+// LATEST, which reads the open bucket of a compaction.
+func rangeWindow(args []interface{}) (string, int64, int64, bool) {
+	if len(args) < 3 {
+		return "", 0, 0, false
+	}
+	key, ok := args[0].(string)
+	if !ok {
+		return "", 0, 0, false
+	}
+	from, ok := rangeTimestamp(args[1])
+	if !ok {
+		return "", 0, 0, false
+	}
+	to, ok := argInt64(args[2])
+	if !ok {
+		return "", 0, 0, false
+	}
+	for _, arg := range args[3:] {
+		if s, ok := arg.(string); ok && strings.EqualFold(s, "LATEST") {
+			return "", 0, 0, false
+		}
+	}
+	return key, from, to, true
+}
+
+// rangeTimestamp returns a timestamp argument of a range, where "-" and "+"
+// are the smallest and the largest timestamps.
+func rangeTimestamp(arg interface{}) (int64, bool) {
+	switch arg {
+	case "-":
+		return math.MinInt64, true
+	case "+":
+		return math.MaxInt64, true
+	}
+	return argInt64(arg)
+}
+
+// argInt64 returns an integer argument, e.g. a timestamp.
+func argInt64(arg interface{}) (int64, bool) {
+	switch v := arg.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// copyReply returns a deep copy of the arrays, maps and byte slices of a
+// reply.
+func copyReply(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		return append([]byte(nil), v...)
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i := range v {
+			c[i] = copyReply(v[i])
+		}
+		return c
+	case map[interface{}]interface{}:
+		c := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			c[k] = copyReply(e)
+		}
+		return c
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = copyReply(e)
+		}
+		return c
+	}
+	return val
+}
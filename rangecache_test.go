@@ -0,0 +1,183 @@
+package redists
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRangeCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip client test")
+	}
+	for _, tt := range doerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := fmt.Sprintf("example:%s", t.Name())
+			destKey, trimKey := key+":hourly", key+":trim"
+
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			doer, err := tt.doer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doer.Close()
+			defer doer.Do(context.Background(), "DEL", key, destKey, trimKey)
+
+			now := thirdMillennium.Add(24 * time.Hour)
+			rc := NewRangeCache(doer, RangeCacheWithClock(func() time.Time { return now }))
+			tsclient := NewClient(rc)
+			for _, k := range []string{key, destKey} {
+				if err = tsclient.Create(ctx, k); err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+			}
+			if err = tsclient.Create(ctx, trimKey, CreateWithRetention(time.Hour)); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if err = tsclient.CreateRule(ctx, key, destKey, AggregationTypeSum, time.Hour); err != nil {
+				t.Fatalf("CreateRule() error = %v", err)
+			}
+			_, err = tsclient.MAdd(ctx, []Sample{
+				NewSample(key, secondMillennium, 1),
+				NewSample(key, thirdMillennium, 2.5),
+				NewSample(trimKey, thirdMillennium, 1),
+			})
+			if err != nil {
+				t.Fatalf("MAdd() error = %v", err)
+			}
+
+			wantRange := func(key string, from, to time.Time, want []DataPoint, options ...OptionRanger) {
+				t.Helper()
+				got, err := tsclient.Range(ctx, key, from, to, options...)
+				if err != nil {
+					t.Fatalf("Range() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Range() got = %v, want %v", got, want)
+				}
+			}
+			wantStats := func(hits, misses int64, entries int) {
+				t.Helper()
+				s := rc.Stats()
+				if s.Hits != hits || s.Misses != misses || s.Entries != entries {
+					t.Errorf("Stats() = %+v, want hits %v, misses %v, entries %v", s, hits, misses, entries)
+				}
+			}
+			from, to := secondMillennium, thirdMillennium.Add(time.Hour)
+			agg := RangerWithAggregation(AggregationTypeSum, 24*time.Hour)
+			wantRange(key, from, to, []DataPoint{{secondMillennium, 1}, {thirdMillennium, 2.5}})
+			wantRange(key, from, to, []DataPoint{{secondMillennium, 1}, {thirdMillennium, 2.5}})
+			wantRange(key, from, to, []DataPoint{{secondMillennium, 1}, {thirdMillennium, 2.5}}, agg)
+			wantStats(1, 2, 2)
+
+			// the window is not settled
+			wantRange(key, from, now, []DataPoint{{secondMillennium, 1}, {thirdMillennium, 2.5}})
+			// the server trims the window while it is cached
+			wantRange(trimKey, from, to, []DataPoint{{thirdMillennium, 1}})
+			wantStats(1, 3, 2)
+
+			// the first sample is compacted when the second closes its bucket
+			wantRange(destKey, from, to, []DataPoint{{secondMillennium, 1}})
+			wantStats(1, 4, 3)
+			// a sample after the window leaves it, but it closes a bucket of the compaction
+			if _, err = tsclient.Add(ctx, NewSample(key, thirdMillennium.Add(2*time.Hour), 4)); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			wantStats(1, 4, 2)
+			wantRange(destKey, from, to, []DataPoint{{secondMillennium, 1}, {thirdMillennium, 2.5}})
+			wantRange(key, from, to, []DataPoint{{secondMillennium, 1}, {thirdMillennium, 2.5}})
+			wantStats(2, 5, 3)
+
+			if _, err = tsclient.Del(ctx, key, secondMillennium, secondMillennium); err != nil {
+				t.Fatalf("Del() error = %v", err)
+			}
+			wantRange(key, from, to, []DataPoint{{thirdMillennium, 2.5}})
+			if err = tsclient.Alter(ctx, key, AlterWithLabels(Labels{"altered": "true"})); err != nil {
+				t.Fatalf("Alter() error = %v", err)
+			}
+			wantRange(key, from, to, []DataPoint{{thirdMillennium, 2.5}})
+			s := rc.Stats()
+			if s.Invalidations != 5 || s.Entries != 1 {
+				t.Errorf("Stats() = %+v, want 5 invalidations and 1 entry", s)
+			}
+
+			now = now.Add(10 * time.Minute)
+			wantRange(key, from, to, []DataPoint{{thirdMillennium, 2.5}})
+			wantStats(2, 8, 1)
+
+			rc.Invalidate(key)
+			wantStats(2, 8, 0)
+		})
+	}
+}
+
+func TestRangeCache_maxSize(t *testing.T) {
+	ctx := context.Background()
+	res := []interface{}{[]interface{}{int64(1000), "1"}}
+	info := []interface{}{"retentionTime", int64(0)}
+	// the retention of key:b is known when it is queried again
+	d := &replyDoer{replies: []interface{}{info, res, info, res, info, res, res}}
+	size := len(appendCommand(nil, "TS.RANGE", []interface{}{"key:a", 0, 1000})) + replySize(res)
+	rc := NewRangeCache(d, RangeCacheWithMaxSize(2*size))
+	for _, key := range []string{"key:a", "key:b", "key:a", "key:c", "key:a", "key:b"} {
+		if _, err := rc.Do(ctx, "TS.RANGE", key, 0, 1000); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+	s := rc.Stats()
+	want := RangeCacheStats{Hits: 2, Misses: 4, Entries: 2, Size: 2 * size, Evictions: 2}
+	if s != want {
+		t.Errorf("Stats() = %+v, want %+v", s, want)
+	}
+}
+
+func TestRangeCache_copy(t *testing.T) {
+	ctx := context.Background()
+	res := []interface{}{[]interface{}{int64(1000), "1"}}
+	d := &replyDoer{replies: []interface{}{[]interface{}{"retentionTime", int64(0)}, res}}
+	rc := NewRangeCache(d)
+	for i := 0; i < 3; i++ {
+		got, err := rc.Do(ctx, "TS.RANGE", "key:any", 0, 1000)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		want := []interface{}{[]interface{}{int64(1000), "1"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Do() got = %v, want %v", got, want)
+		}
+		// neither the caller nor the Doer may change the cached reply
+		got.([]interface{})[0].([]interface{})[1] = "2"
+	}
+	if s := rc.Stats(); s.Hits != 2 {
+		t.Errorf("Stats().Hits = %v, want 2", s.Hits)
+	}
+}
+
+// invalidatingDoer invalidates the cache while it reads a range.
+type invalidatingDoer struct {
+	replyDoer
+	rc *RangeCache
+}
+
+func (d *invalidatingDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "TS.RANGE" {
+		d.rc.Invalidate("key:any")
+	}
+	return d.replyDoer.Do(ctx, cmd, args...)
+}
+
+func TestRangeCache_inflight(t *testing.T) {
+	res := []interface{}{[]interface{}{int64(1000), "1"}}
+	d := &invalidatingDoer{replyDoer: replyDoer{replies: []interface{}{[]interface{}{"retentionTime", int64(0)}, res}}}
+	d.rc = NewRangeCache(d)
+	if _, err := d.rc.Do(context.Background(), "TS.RANGE", "key:any", 0, 1000); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if s := d.rc.Stats(); s.Entries != 0 {
+		t.Errorf("Stats() = %+v, want no entries", s)
+	}
+}
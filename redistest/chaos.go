@@ -0,0 +1,282 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coding-socks/redists"
+)
+
+// ErrTransport is returned by Chaos instead of the reply of a command when it
+// injects a transport error.
+var ErrTransport = errors.New("redistest: injected transport error")
+
+// Latency returns a random delay using r.
+type Latency func(r *rand.Rand) time.Duration
+
+// FixedLatency returns a Latency which always returns d.
+func FixedLatency(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// UniformLatency returns a Latency which is uniformly distributed between min
+// and max. It panics when max is less than min.
+func UniformLatency(min, max time.Duration) Latency {
+	if max < min {
+		panic("redistest: UniformLatency max is less than min")
+	}
+	return func(r *rand.Rand) time.Duration {
+		return min + time.Duration(r.Int63n(int64(max-min)+1))
+	}
+}
+
+// NormalLatency returns a Latency which is normally distributed. Negative
+// values are returned as zero.
+func NormalLatency(mean, stddev time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		d := time.Duration(r.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// ExponentialLatency returns a Latency which is exponentially distributed,
+// which produces a long tail of slow commands.
+func ExponentialLatency(mean time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
+
+// Fault configures the faults Chaos injects. The rates are the fractions of
+// commands failing in each way, and their sum must not be more than 1.
+type Fault struct {
+	// Latency delays commands before they are sent.
+	Latency Latency
+	// TransportErrorRate is the fraction of commands which fail with
+	// ErrTransport before they are sent.
+	TransportErrorRate float64
+	// LostReplyRate is the fraction of commands which are sent, but they
+	// fail with ErrTransport instead of returning the reply.
+	LostReplyRate float64
+	// ServerErrorRate is the fraction of commands which fail with
+	// ServerError before they are sent.
+	ServerErrorRate float64
+	// ServerError is the error of ServerErrorRate. The default is
+	// "ERR redistest: injected server error".
+	ServerError error
+	// TruncateRate is the fraction of commands with a truncated reply.
+	// Arrays lose elements and strings lose bytes.
+	TruncateRate float64
+	// CorruptRate is the fraction of commands where a value of the reply is
+	// replaced with a value of another type.
+	CorruptRate float64
+}
+
+// Chaos is a redists.Doer which injects faults into the commands of another
+// Doer. The faults are chosen by a seeded random number generator, so a
+// sequence of commands fails the same way every time. The order of
+// concurrent commands is not deterministic though.
+type Chaos struct {
+	d      redists.Doer
+	mu     sync.Mutex
+	r      *rand.Rand
+	faults map[string]Fault
+	def    Fault
+}
+
+var _ redists.Doer = (*Chaos)(nil)
+
+type OptionChaos func(c *Chaos)
+
+// NewChaos returns a Chaos which sends the commands to d. Without options it
+// does not inject faults.
+func NewChaos(d redists.Doer, seed int64, options ...OptionChaos) *Chaos {
+	c := &Chaos{d: d, r: rand.New(rand.NewSource(seed)), faults: map[string]Fault{}}
+	for i := range options {
+		options[i](c)
+	}
+	return c
+}
+
+// ChaosWithFault sets the fault of the given commands, e.g. "TS.MADD". Without
+// commands, it sets the fault of the commands which have no fault of their
+// own.
+func ChaosWithFault(f Fault, cmds ...string) OptionChaos {
+	return func(c *Chaos) {
+		if len(cmds) == 0 {
+			c.def = f
+			return
+		}
+		for _, cmd := range cmds {
+			c.faults[strings.ToUpper(cmd)] = f
+		}
+	}
+}
+
+type faultKind int
+
+const (
+	faultNone faultKind = iota
+	faultTransport
+	faultLostReply
+	faultServer
+	faultTruncate
+	faultCorrupt
+)
+
+// plan draws the latency and the kind of fault of a command.
+func (c *Chaos) plan(cmd string) (Fault, time.Duration, faultKind, *rand.Rand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.faults[strings.ToUpper(cmd)]
+	if !ok {
+		f = c.def
+	}
+	var latency time.Duration
+	if f.Latency != nil {
+		latency = f.Latency(c.r)
+	}
+	u := c.r.Float64()
+	// a separate generator keeps the sequence of the plans independent of
+	// the shape of the replies
+	r := rand.New(rand.NewSource(c.r.Int63()))
+	kind := faultNone
+	for _, k := range []struct {
+		rate float64
+		kind faultKind
+	}{
+		{f.TransportErrorRate, faultTransport},
+		{f.LostReplyRate, faultLostReply},
+		{f.ServerErrorRate, faultServer},
+		{f.TruncateRate, faultTruncate},
+		{f.CorruptRate, faultCorrupt},
+	} {
+		if u < k.rate {
+			kind = k.kind
+			break
+		}
+		u -= k.rate
+	}
+	return f, latency, kind, r
+}
+
+func (c *Chaos) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	f, latency, kind, r := c.plan(cmd)
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case faultTransport:
+		return nil, ErrTransport
+	case faultServer:
+		if f.ServerError != nil {
+			return nil, f.ServerError
+		}
+		return nil, Error("ERR redistest: injected server error")
+	}
+	res, err := c.d.Do(ctx, cmd, args...)
+	if err != nil {
+		return res, err
+	}
+	switch kind {
+	case faultLostReply:
+		return nil, ErrTransport
+	case faultTruncate:
+		return truncate(r, res), nil
+	case faultCorrupt:
+		return corrupt(r, res), nil
+	}
+	return res, nil
+}
+
+// truncate returns val with fewer elements or bytes.
+func truncate(r *rand.Rand, val interface{}) interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		return append([]interface{}(nil), v[:r.Intn(len(v))]...)
+	case string:
+		return v[:r.Intn(len(v)+1)/2]
+	case []byte:
+		return append([]byte(nil), v[:r.Intn(len(v)+1)/2]...)
+	default:
+		return nil
+	}
+}
+
+// corrupt returns a copy of val where a random value is replaced with a
+// value of another type.
+func corrupt(r *rand.Rand, val interface{}) interface{} {
+	n := countValues(val)
+	res, _ := replaceValue(val, r.Intn(n), r)
+	return res
+}
+
+// countValues returns the number of values in the reply tree, including the
+// arrays.
+func countValues(val interface{}) int {
+	n := 1
+	if vs, ok := val.([]interface{}); ok {
+		for _, v := range vs {
+			n += countValues(v)
+		}
+	}
+	return n
+}
+
+// replaceValue replaces the i-th value of the tree in depth-first order. It
+// returns the new tree and the number of values visited.
+func replaceValue(val interface{}, i int, r *rand.Rand) (interface{}, int) {
+	if i == 0 {
+		return corruptValue(val, r), 1
+	}
+	vs, ok := val.([]interface{})
+	if !ok {
+		return val, 1
+	}
+	res := make([]interface{}, len(vs))
+	seen := 1
+	for j := range vs {
+		var n int
+		res[j], n = replaceValue(vs[j], i-seen, r)
+		seen += n
+	}
+	return res, seen
+}
+
+func corruptValue(val interface{}, r *rand.Rand) interface{} {
+	switch val.(type) {
+	case int64:
+		return "\x00corrupt"
+	case string, []byte:
+		if r.Intn(2) == 0 {
+			return int64(r.Int31())
+		}
+		return math.NaN()
+	case nil:
+		return []interface{}{int64(0)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChaos(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("seed", func(t *testing.T) {
+		outcomes := func() []bool {
+			c := NewChaos(NewDoer(), 42, ChaosWithFault(Fault{TransportErrorRate: 0.3, ServerErrorRate: 0.2}))
+			var res []bool
+			for i := 0; i < 50; i++ {
+				_, err := c.Do(ctx, "PING")
+				res = append(res, err == nil)
+			}
+			return res
+		}
+		if got, want := outcomes(), outcomes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("outcomes = %v, want %v", got, want)
+		}
+	})
+	t.Run("rate", func(t *testing.T) {
+		c := NewChaos(NewDoer(), 1, ChaosWithFault(Fault{TransportErrorRate: 0.25}))
+		var failed int
+		for i := 0; i < 1000; i++ {
+			if _, err := c.Do(ctx, "PING"); errors.Is(err, ErrTransport) {
+				failed++
+			}
+		}
+		if failed < 200 || failed > 300 {
+			t.Errorf("failed = %v, want about 250", failed)
+		}
+	})
+	t.Run("per command", func(t *testing.T) {
+		d := NewDoer()
+		c := NewChaos(d, 1, ChaosWithFault(Fault{LostReplyRate: 1}, "TS.ADD"))
+		if _, err := c.Do(ctx, "TS.ADD", "key:any", 1000, 1); !errors.Is(err, ErrTransport) {
+			t.Errorf("Do() error = %v, want %v", err, ErrTransport)
+		}
+		// the reply is lost, but the command was executed
+		res, err := c.Do(ctx, "TS.GET", "key:any")
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if want := []interface{}{int64(1000), "1"}; !reflect.DeepEqual(res, want) {
+			t.Errorf("Do() got = %v, want %v", res, want)
+		}
+	})
+	t.Run("server error", func(t *testing.T) {
+		want := Error("LOADING Redis is loading the dataset in memory")
+		c := NewChaos(NewDoer(), 1, ChaosWithFault(Fault{ServerErrorRate: 1, ServerError: want}))
+		if _, err := c.Do(ctx, "PING"); err != want {
+			t.Errorf("Do() error = %v, want %v", err, want)
+		}
+	})
+	t.Run("deadline", func(t *testing.T) {
+		c := NewChaos(NewDoer(), 1, ChaosWithFault(Fault{Latency: FixedLatency(time.Hour)}))
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := c.Do(ctx, "PING"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Do() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+	t.Run("replies", func(t *testing.T) {
+		d := NewDoer()
+		for i := 0; i < 10; i++ {
+			if _, err := d.Do(ctx, "TS.ADD", "key:any", 1000+i, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := d.Do(ctx, "TS.RANGE", "key:any", "-", "+")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []Fault{{TruncateRate: 1}, {CorruptRate: 1}} {
+			c := NewChaos(d, 1, ChaosWithFault(f))
+			for i := 0; i < 20; i++ {
+				got, err := c.Do(ctx, "TS.RANGE", "key:any", "-", "+")
+				if err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+				if reflect.DeepEqual(got, want) {
+					t.Errorf("Do() got = %v, want a faulty reply", got)
+				}
+			}
+		}
+	})
+}
+
+func TestUniformLatency(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l := UniformLatency(time.Second, 2*time.Second)
+	for i := 0; i < 100; i++ {
+		if d := l(r); d < time.Second || d > 2*time.Second {
+			t.Fatalf("Latency() = %v, want between %v and %v", d, time.Second, 2*time.Second)
+		}
+	}
+	if d := UniformLatency(time.Second, time.Second)(r); d != time.Second {
+		t.Errorf("Latency() = %v, want %v", d, time.Second)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("UniformLatency() did not panic with max < min")
+		}
+	}()
+	UniformLatency(2*time.Second, time.Second)
+}
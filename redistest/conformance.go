@@ -0,0 +1,488 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+)
+
+// RunDoerConformance checks that redists.Client decodes the replies of a Doer
+// correctly. It runs every Client method and Pipeline, including edge cases
+// such as empty ranges, missing keys, failed samples of MAdd, Info fields
+// without a value and unicode labels, and it reports every mismatch.
+//
+// newDoer is called for each subtest. The returned Doer must be connected to
+// a server with RedisTimeSeries, e.g. a Server. Subtests use keys with the
+// "conformance:" prefix and delete them at the end, so a shared database can
+// be used as well.
+func RunDoerConformance(t *testing.T, newDoer func(t *testing.T) redists.Doer) {
+	for _, tt := range conformanceTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			d := newDoer(t)
+			c := &conformance{
+				t:      t,
+				ctx:    ctx,
+				client: redists.NewClient(d),
+				prefix: "conformance:" + tt.name + ":",
+				// the label selects the keys of the subtest in filters
+				label: redists.Labels{"conformance": tt.name},
+			}
+			defer func() {
+				for _, key := range c.keys {
+					d.Do(context.Background(), "DEL", key)
+				}
+			}()
+			tt.f(c)
+		})
+	}
+}
+
+type conformance struct {
+	t      *testing.T
+	ctx    context.Context
+	client *redists.Client
+	prefix string
+	label  redists.Labels
+	keys   []string
+}
+
+// key returns a key of the subtest, which is deleted at the end.
+func (c *conformance) key(name string) string {
+	key := c.prefix + name
+	c.keys = append(c.keys, key)
+	return key
+}
+
+// labels returns the label of the subtest merged with ls.
+func (c *conformance) labels(ls redists.Labels) redists.Labels {
+	res := redists.Labels{}
+	for k, v := range c.label {
+		res[k] = v
+	}
+	for k, v := range ls {
+		res[k] = v
+	}
+	return res
+}
+
+func (c *conformance) filter() redists.Filter {
+	for k, v := range c.label {
+		return redists.FilterEqual(k, v)
+	}
+	panic("unreachable")
+}
+
+// must stops the subtest when a step which sets up data fails.
+func (c *conformance) must(what string, err error) {
+	c.t.Helper()
+	if err != nil {
+		c.t.Fatalf("%s error = %v", what, err)
+	}
+}
+
+// equal reports a mismatch between the decoded value and the expected one.
+func (c *conformance) equal(what string, got, want interface{}) {
+	c.t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		c.t.Errorf("%s%s\n\tgot  = %s\n\twant = %s", what, mismatch(got, want), show(got), show(want))
+	}
+}
+
+// mismatch describes the first difference between two slices, or the values
+// themselves.
+func mismatch(got, want interface{}) string {
+	gv, wv := reflect.ValueOf(got), reflect.ValueOf(want)
+	if gv.Kind() != reflect.Slice || wv.Kind() != reflect.Slice {
+		return fmt.Sprintf(" got = %s, want %s", show(got), show(want))
+	}
+	for i := 0; i < gv.Len() && i < wv.Len(); i++ {
+		g, w := gv.Index(i).Interface(), wv.Index(i).Interface()
+		if !reflect.DeepEqual(g, w) {
+			return fmt.Sprintf("[%d] got = %s, want %s", i, show(g), show(w))
+		}
+	}
+	return fmt.Sprintf(" len = %d, want %d", gv.Len(), wv.Len())
+}
+
+// errorIs reports an error which does not match target.
+func (c *conformance) errorIs(what string, err, target error) {
+	c.t.Helper()
+	if !errors.Is(err, target) {
+		c.t.Errorf("%s error = %#v, want %v", what, err, target)
+	}
+}
+
+// show formats a value with the fields of structs, and with the value of
+// pointers instead of the address.
+func show(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return "&" + show(rv.Elem().Interface())
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+func ts(ms int64) time.Time {
+	return time.UnixMilli(ms)
+}
+
+func points(pairs ...float64) []redists.DataPoint {
+	ps := []redists.DataPoint{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		ps = append(ps, redists.DataPoint{Timestamp: ts(int64(pairs[i])), Value: pairs[i+1]})
+	}
+	return ps
+}
+
+// nonNil replaces a nil slice with an empty one, so the clients which decode
+// an empty array differently do not cause false mismatches.
+func nonNil(ps []redists.DataPoint) []redists.DataPoint {
+	if ps == nil {
+		return []redists.DataPoint{}
+	}
+	return ps
+}
+
+var conformanceTests = []struct {
+	name string
+	f    func(c *conformance)
+}{
+	{name: "create", f: testCreate},
+	{name: "alter", f: testAlter},
+	{name: "add", f: testAdd},
+	{name: "madd", f: testMAdd},
+	{name: "counter", f: testCounter},
+	{name: "range", f: testRange},
+	{name: "empty", f: testEmpty},
+	{name: "del", f: testDel},
+	{name: "rules", f: testRules},
+	{name: "multi", f: testMulti},
+	{name: "pipeline", f: testPipeline},
+}
+
+func testCreate(c *conformance) {
+	key := c.key("a")
+	labels := c.labels(redists.Labels{"név": "árvíztűrő tükörfúrógép", "emoji": "📈 ✓"})
+	c.must("Create()", c.client.Create(c.ctx, key,
+		redists.CreateWithRetention(time.Hour),
+		redists.CreateWithChunkSize(128),
+		redists.CreateWithLabels(labels),
+	))
+	c.errorIs("Create() existing key", c.client.Create(c.ctx, key), redists.ErrKeyExists)
+
+	inf, err := c.client.Info(c.ctx, key)
+	c.must("Info()", err)
+	c.equal("Info().TotalSamples", inf.TotalSamples, int64(0))
+	c.equal("Info().RetentionTime", inf.RetentionTime, time.Hour)
+	c.equal("Info().ChunkSize", inf.ChunkSize, int64(128))
+	c.equal("Info().Labels", inf.Labels, labels)
+	// the fields without a value are nil in the reply
+	c.equal("Info().SourceKey", inf.SourceKey, "")
+	c.equal("Info().DuplicatePolicy", inf.DuplicatePolicy, (*redists.DuplicatePolicy)(nil))
+	c.equal("len(Info().Rules)", len(inf.Rules), 0)
+
+	_, err = c.client.Info(c.ctx, c.key("missing"))
+	c.errorIs("Info() missing key", err, redists.ErrKeyNotExist)
+}
+
+func testAlter(c *conformance) {
+	key := c.key("a")
+	c.must("Create()", c.client.Create(c.ctx, key, redists.CreateWithLabels(c.labels(nil))))
+	labels := c.labels(redists.Labels{"ünnep": "🎉"})
+	c.must("Alter()", c.client.Alter(c.ctx, key,
+		redists.AlterWithRetention(time.Minute),
+		redists.AlterWithDuplicatePolicy(redists.DuplicatePolicyMax),
+		redists.AlterWithLabels(labels),
+	))
+	inf, err := c.client.Info(c.ctx, key)
+	c.must("Info()", err)
+	c.equal("Info().RetentionTime", inf.RetentionTime, time.Minute)
+	dp := redists.DuplicatePolicyMax
+	c.equal("Info().DuplicatePolicy", inf.DuplicatePolicy, &dp)
+	c.equal("Info().Labels", inf.Labels, labels)
+
+	c.errorIs("Alter() missing key", c.client.Alter(c.ctx, c.key("missing")), redists.ErrKeyNotExist)
+}
+
+func testAdd(c *conformance) {
+	key := c.key("a")
+	c.must("Create()", c.client.Create(c.ctx, key, redists.CreateWithDuplicatePolicy(redists.DuplicatePolicyBlock)))
+	p, err := c.client.Get(c.ctx, key)
+	c.must("Get() empty", err)
+	c.equal("Get() empty", p, (*redists.DataPoint)(nil))
+
+	got, err := c.client.Add(c.ctx, redists.NewSample(key, ts(1000), 1.5))
+	c.must("Add()", err)
+	c.equal("Add()", got, ts(1000))
+	_, err = c.client.Add(c.ctx, redists.NewSample(key, ts(2000), -0.25))
+	c.must("Add()", err)
+	p, err = c.client.Get(c.ctx, key)
+	c.must("Get()", err)
+	c.equal("Get()", p, &redists.DataPoint{Timestamp: ts(2000), Value: -0.25})
+
+	_, err = c.client.Add(c.ctx, redists.NewSample(key, ts(2000), 3))
+	c.errorIs("Add() duplicate", err, redists.ErrDuplicateBlocked)
+	got, err = c.client.Add(c.ctx, redists.NewSample(key, ts(2000), 3), redists.AddWithOnDuplicate(redists.DuplicatePolicyLast))
+	c.must("Add() ON_DUPLICATE LAST", err)
+	c.equal("Add() ON_DUPLICATE LAST", got, ts(2000))
+
+	// Add creates the time-series with the labels
+	created := c.key("created")
+	labels := c.labels(redists.Labels{"hely": "Kőszeg"})
+	_, err = c.client.Add(c.ctx, redists.NewSample(created, ts(1000), 1), redists.AddWithLabels(labels))
+	c.must("Add() new key", err)
+	inf, err := c.client.Info(c.ctx, created)
+	c.must("Info()", err)
+	c.equal("Info().Labels", inf.Labels, labels)
+	c.equal("Info().FirstTimestamp", inf.FirstTimestamp, ts(1000))
+	c.equal("Info().LastTimestamp", inf.LastTimestamp, ts(1000))
+
+	_, err = c.client.Get(c.ctx, c.key("missing"))
+	c.errorIs("Get() missing key", err, redists.ErrKeyNotExist)
+}
+
+func testMAdd(c *conformance) {
+	a, b := c.key("a"), c.key("b")
+	for _, key := range []string{a, b} {
+		c.must("Create()", c.client.Create(c.ctx, key, redists.CreateWithDuplicatePolicy(redists.DuplicatePolicyBlock)))
+	}
+	rs, err := c.client.MAdd(c.ctx, []redists.Sample{
+		redists.NewSample(a, ts(1000), 1),
+		redists.NewSample(c.key("missing"), ts(1000), 2),
+		redists.NewSample(b, ts(1000), 3),
+		redists.NewSample(a, ts(1000), 4),
+	})
+	c.must("MAdd()", err)
+	if len(rs) != 4 {
+		c.t.Fatalf("MAdd() got %d results, want 4", len(rs))
+	}
+	for _, i := range []int{0, 2} {
+		c.equal(fmt.Sprintf("MAdd()[%d].Err()", i), rs[i].Err(), nil)
+		c.equal(fmt.Sprintf("MAdd()[%d].Time()", i), rs[i].Time(), ts(1000))
+	}
+	c.errorIs("MAdd()[1].Err()", rs[1].Err(), redists.ErrKeyNotExist)
+	c.errorIs("MAdd()[3].Err()", rs[3].Err(), redists.ErrDuplicateBlocked)
+
+	rs, err = c.client.MAdd(c.ctx, nil)
+	if err == nil {
+		c.equal("len(MAdd() no samples)", len(rs), 0)
+	}
+}
+
+func testCounter(c *conformance) {
+	key := c.key("a")
+	got, err := c.client.IncrBy(c.ctx, key, 5, redists.CounterWithTimestamp(ts(1000)), redists.CounterWithLabels(c.labels(nil)))
+	c.must("IncrBy()", err)
+	c.equal("IncrBy()", got, ts(1000))
+	got, err = c.client.DecrBy(c.ctx, key, 1.5, redists.CounterWithTimestamp(ts(2000)))
+	c.must("DecrBy()", err)
+	c.equal("DecrBy()", got, ts(2000))
+	p, err := c.client.Get(c.ctx, key)
+	c.must("Get()", err)
+	c.equal("Get()", p, &redists.DataPoint{Timestamp: ts(2000), Value: 3.5})
+
+	_, err = c.client.IncrBy(c.ctx, key, 1, redists.CounterWithTimestamp(ts(1000)))
+	c.errorIs("IncrBy() older timestamp", err, redists.ErrTimestampNotLatest)
+}
+
+func testRange(c *conformance) {
+	key := c.key("a")
+	c.must("Create()", c.client.Create(c.ctx, key))
+	for i, v := range []float64{1, 2, 3, 4, 5} {
+		_, err := c.client.Add(c.ctx, redists.NewSample(key, ts(int64(i+1)*500), v))
+		c.must("Add()", err)
+	}
+	ps, err := c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax())
+	c.must("Range()", err)
+	c.equal("Range()", ps, points(500, 1, 1000, 2, 1500, 3, 2000, 4, 2500, 5))
+	ps, err = c.client.RevRange(c.ctx, key, ts(1000), ts(2000))
+	c.must("RevRange()", err)
+	c.equal("RevRange()", ps, points(2000, 4, 1500, 3, 1000, 2))
+	ps, err = c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax(), redists.RangerWithCount(2))
+	c.must("Range() COUNT", err)
+	c.equal("Range() COUNT", ps, points(500, 1, 1000, 2))
+	ps, err = c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax(), redists.RangerWithValueFilter(2, 3))
+	c.must("Range() FILTER_BY_VALUE", err)
+	c.equal("Range() FILTER_BY_VALUE", ps, points(1000, 2, 1500, 3))
+	ps, err = c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax(), redists.RangerWithTSFilter(ts(500), ts(2500)))
+	c.must("Range() FILTER_BY_TS", err)
+	c.equal("Range() FILTER_BY_TS", ps, points(500, 1, 2500, 5))
+	ps, err = c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax(), redists.RangerWithAggregation(redists.AggregationTypeSum, time.Second))
+	c.must("Range() AGGREGATION", err)
+	c.equal("Range() AGGREGATION", ps, points(0, 1, 1000, 5, 2000, 9))
+}
+
+func testEmpty(c *conformance) {
+	key := c.key("a")
+	c.must("Create()", c.client.Create(c.ctx, key, redists.CreateWithLabels(c.labels(nil))))
+	ps, err := c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax())
+	c.must("Range() empty series", err)
+	c.equal("Range() empty series", nonNil(ps), []redists.DataPoint{})
+	ps, err = c.client.RevRange(c.ctx, key, redists.TSMin(), redists.TSMax())
+	c.must("RevRange() empty series", err)
+	c.equal("RevRange() empty series", nonNil(ps), []redists.DataPoint{})
+
+	_, err = c.client.Add(c.ctx, redists.NewSample(key, ts(1000), 1))
+	c.must("Add()", err)
+	ps, err = c.client.Range(c.ctx, key, ts(2000), ts(3000))
+	c.must("Range() empty window", err)
+	c.equal("Range() empty window", nonNil(ps), []redists.DataPoint{})
+	ps, err = c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax(), redists.RangerWithAggregation(redists.AggregationTypeAvg, time.Second), redists.RangerWithValueFilter(5, 6))
+	c.must("Range() empty aggregation", err)
+	c.equal("Range() empty aggregation", nonNil(ps), []redists.DataPoint{})
+
+	_, err = c.client.Range(c.ctx, c.key("missing"), redists.TSMin(), redists.TSMax())
+	c.errorIs("Range() missing key", err, redists.ErrKeyNotExist)
+
+	none := []redists.Filter{c.filter(), redists.FilterEqual("conformance_none", "x")}
+	tss, err := c.client.MRange(c.ctx, redists.TSMin(), redists.TSMax(), none)
+	c.must("MRange() no match", err)
+	c.equal("len(MRange() no match)", len(tss), 0)
+	lds, err := c.client.MGet(c.ctx, none)
+	c.must("MGet() no match", err)
+	c.equal("len(MGet() no match)", len(lds), 0)
+	keys, err := c.client.QueryIndex(c.ctx, none)
+	c.must("QueryIndex() no match", err)
+	c.equal("len(QueryIndex() no match)", len(keys), 0)
+}
+
+func testDel(c *conformance) {
+	key := c.key("a")
+	c.must("Create()", c.client.Create(c.ctx, key))
+	for _, ms := range []int64{1000, 2000, 3000} {
+		_, err := c.client.Add(c.ctx, redists.NewSample(key, ts(ms), 1))
+		c.must("Add()", err)
+	}
+	n, err := c.client.Del(c.ctx, key, ts(1500), ts(3000))
+	c.must("Del()", err)
+	c.equal("Del()", n, int64(2))
+	ps, err := c.client.Range(c.ctx, key, redists.TSMin(), redists.TSMax())
+	c.must("Range()", err)
+	c.equal("Range()", ps, points(1000, 1))
+
+	_, err = c.client.Del(c.ctx, c.key("missing"), ts(0), ts(1000))
+	c.errorIs("Del() missing key", err, redists.ErrKeyNotExist)
+}
+
+func testRules(c *conformance) {
+	src, dest := c.key("src"), c.key("dest")
+	c.must("Create()", c.client.Create(c.ctx, src))
+	c.must("Create()", c.client.Create(c.ctx, dest))
+	c.must("CreateRule()", c.client.CreateRule(c.ctx, src, dest, redists.AggregationTypeAvg, time.Minute))
+	c.errorIs("CreateRule() existing rule", c.client.CreateRule(c.ctx, src, dest, redists.AggregationTypeAvg, time.Minute), redists.ErrRuleExists)
+
+	inf, err := c.client.Info(c.ctx, src)
+	c.must("Info()", err)
+	r, ok := inf.Rules[dest]
+	if !ok {
+		c.t.Errorf("Info().Rules got = %#v, want a rule for %s", inf.Rules, dest)
+	} else {
+		c.equal("Info().Rules.Type", r.Type, redists.AggregationTypeAvg)
+		c.equal("Info().Rules.Bucket", r.Bucket.Milliseconds(), time.Minute.Milliseconds())
+	}
+	inf, err = c.client.Info(c.ctx, dest)
+	c.must("Info()", err)
+	c.equal("Info().SourceKey", inf.SourceKey, src)
+
+	c.must("DeleteRule()", c.client.DeleteRule(c.ctx, src, dest))
+	c.errorIs("DeleteRule() missing rule", c.client.DeleteRule(c.ctx, src, dest), redists.ErrRuleNotExist)
+}
+
+func testMulti(c *conformance) {
+	a, b := c.key("a"), c.key("b")
+	la := c.labels(redists.Labels{"város": "Győr", "csoport": "ő"})
+	lb := c.labels(redists.Labels{"város": "Pécs", "csoport": "ő"})
+	c.must("Create()", c.client.Create(c.ctx, a, redists.CreateWithLabels(la)))
+	c.must("Create()", c.client.Create(c.ctx, b, redists.CreateWithLabels(lb)))
+	for _, s := range []redists.Sample{
+		redists.NewSample(a, ts(1000), 1),
+		redists.NewSample(a, ts(2000), 2),
+		redists.NewSample(b, ts(1000), 10),
+	} {
+		_, err := c.client.Add(c.ctx, s)
+		c.must("Add()", err)
+	}
+	filters := []redists.Filter{c.filter()}
+
+	keys, err := c.client.QueryIndex(c.ctx, filters)
+	c.must("QueryIndex()", err)
+	sort.Strings(keys)
+	c.equal("QueryIndex()", keys, []string{a, b})
+	keys, err = c.client.QueryIndex(c.ctx, []redists.Filter{c.filter(), redists.FilterEqual("város", "Győr")})
+	c.must("QueryIndex() unicode filter", err)
+	c.equal("QueryIndex() unicode filter", keys, []string{a})
+
+	tss, err := c.client.MRange(c.ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithLabels())
+	c.must("MRange()", err)
+	sortTimeSeries(tss)
+	c.equal("MRange()", tss, []redists.TimeSeries{
+		{Key: a, Labels: la, DataPoints: points(1000, 1, 2000, 2)},
+		{Key: b, Labels: lb, DataPoints: points(1000, 10)},
+	})
+	tss, err = c.client.MRevRange(c.ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithLabels("város"))
+	c.must("MRevRange() SELECTED_LABELS", err)
+	sortTimeSeries(tss)
+	c.equal("MRevRange() SELECTED_LABELS", tss, []redists.TimeSeries{
+		{Key: a, Labels: redists.Labels{"város": "Győr"}, DataPoints: points(2000, 2, 1000, 1)},
+		{Key: b, Labels: redists.Labels{"város": "Pécs"}, DataPoints: points(1000, 10)},
+	})
+	tss, err = c.client.MRange(c.ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithGroupBy("csoport", redists.ReducerSum))
+	c.must("MRange() GROUPBY", err)
+	if len(tss) != 1 {
+		c.t.Errorf("MRange() GROUPBY got = %#v, want 1 group", tss)
+	} else {
+		c.equal("MRange() GROUPBY DataPoints", tss[0].DataPoints, points(1000, 11, 2000, 2))
+	}
+
+	lds, err := c.client.MGet(c.ctx, filters, redists.MGetWithLabels())
+	c.must("MGet()", err)
+	sort.Slice(lds, func(i, j int) bool {
+		return lds[i].Key < lds[j].Key
+	})
+	c.equal("MGet()", lds, []redists.LastDatapoint{
+		{Key: a, Labels: la, DataPoint: &redists.DataPoint{Timestamp: ts(2000), Value: 2}},
+		{Key: b, Labels: lb, DataPoint: &redists.DataPoint{Timestamp: ts(1000), Value: 10}},
+	})
+}
+
+func sortTimeSeries(tss []redists.TimeSeries) {
+	sort.Slice(tss, func(i, j int) bool {
+		return tss[i].Key < tss[j].Key
+	})
+}
+
+func testPipeline(c *conformance) {
+	key := c.key("a")
+	p := c.client.Pipeline()
+	create := p.Create(key, redists.CreateWithLabels(c.labels(nil)))
+	add := p.Add(redists.NewSample(key, ts(1000), 1))
+	get := p.Get(key)
+	missing := p.Get(c.key("missing"))
+	madd := p.MAdd([]redists.Sample{
+		redists.NewSample(key, ts(2000), 2),
+		redists.NewSample(c.key("missing"), ts(2000), 2),
+	})
+	rng := p.Range(key, redists.TSMin(), redists.TSMax())
+	c.must("Pipeline.Exec()", p.Exec(c.ctx))
+
+	c.equal("Pipeline.Create().Err()", create.Err(), nil)
+	c.equal("Pipeline.Add().Val()", add.Val(), ts(1000))
+	c.equal("Pipeline.Get().Val()", get.Val(), &redists.DataPoint{Timestamp: ts(1000), Value: 1})
+	c.errorIs("Pipeline.Get() missing key", missing.Err(), redists.ErrKeyNotExist)
+	if rs := madd.Val(); len(rs) != 2 {
+		c.t.Errorf("Pipeline.MAdd().Val() got = %#v, want 2 results", rs)
+	} else {
+		c.equal("Pipeline.MAdd()[0].Err()", rs[0].Err(), nil)
+		c.errorIs("Pipeline.MAdd()[1].Err()", rs[1].Err(), redists.ErrKeyNotExist)
+	}
+	c.equal("Pipeline.Range().Val()", rng.Val(), points(1000, 1, 2000, 2))
+}
@@ -0,0 +1,52 @@
+package redistest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/coding-socks/redists"
+)
+
+func TestRunDoerConformance(t *testing.T) {
+	t.Run("doer", func(t *testing.T) {
+		RunDoerConformance(t, func(t *testing.T) redists.Doer {
+			return NewDoer()
+		})
+	})
+	for _, protocol := range []int{2, 3} {
+		t.Run(fmt.Sprintf("resp%d", protocol), func(t *testing.T) {
+			RunDoerConformance(t, func(t *testing.T) redists.Doer {
+				s := NewServer(t)
+				p, err := redists.Dial(context.Background(), "redis://"+s.Addr(), redists.DialWithProtocol(protocol))
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { p.Close() })
+				return p
+			})
+		})
+	}
+}
+
+// TestRunDoerConformance_redis runs the suite against the server at
+// REDISTS_ADDR, so the Server cannot drift from RedisTimeSeries unnoticed.
+func TestRunDoerConformance_redis(t *testing.T) {
+	addr := os.Getenv("REDISTS_ADDR")
+	if addr == "" {
+		t.Skip("REDISTS_ADDR is not set")
+	}
+	for _, protocol := range []int{2, 3} {
+		t.Run(fmt.Sprintf("resp%d", protocol), func(t *testing.T) {
+			RunDoerConformance(t, func(t *testing.T) redists.Doer {
+				p, err := redists.Dial(context.Background(), "redis://"+addr, redists.DialWithProtocol(protocol))
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { p.Close() })
+				return p
+			})
+		})
+	}
+}
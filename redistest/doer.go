@@ -0,0 +1,703 @@
+// Package redistest provides utilities for testing code which uses
+// RedisTimeSeries through redists, without running a Redis server.
+package redistest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coding-socks/redists"
+)
+
+// Error is an error reply. The messages are the same as the ones of
+// RedisTimeSeries, so errors.Is works with the sentinel errors of redists.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+const (
+	errSyntax           = Error("ERR syntax error")
+	errWrongArgs        = Error("ERR wrong number of arguments")
+	errKeyNotExist      = Error("ERR TSDB: the key does not exist")
+	errKeyExists        = Error("ERR TSDB: key already exists")
+	errInvalidTimestamp = Error("ERR TSDB: invalid timestamp")
+	errInvalidValue     = Error("ERR TSDB: invalid value")
+	errInvalidRetention = Error("ERR TSDB: Couldn't parse RETENTION")
+	errInvalidChunkSize = Error("ERR TSDB: Couldn't parse CHUNK_SIZE")
+	errInvalidCount     = Error("ERR TSDB: Couldn't parse COUNT")
+	errInvalidPolicy    = Error("ERR TSDB: Unknown DUPLICATE_POLICY")
+	errInvalidEncoding  = Error("ERR TSDB: unknown ENCODING parameter")
+	errInvalidAgg       = Error("ERR TSDB: Unknown aggregation type")
+	errInvalidBucket    = Error("ERR TSDB: bucketDuration must be greater than zero")
+	errInvalidReducer   = Error("ERR TSDB: invalid reducer")
+	errTooOld           = Error("ERR TSDB: Timestamp is older than retention")
+	errNotLatest        = Error("ERR TSDB: timestamp must be equal to or higher than the maximum existing timestamp")
+	errBlocked          = Error("ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	errFilterParse      = Error("ERR TSDB: failed parsing labels")
+	errNoMatcher        = Error("ERR TSDB: please provide at least one matcher")
+	errMissingFilter    = Error("ERR TSDB: missing FILTER argument")
+	errSameKey          = Error("ERR TSDB: the source key and destination key should be different")
+	errDestHasRule      = Error("ERR TSDB: the destination key already has a src rule")
+	errRuleNotExist     = Error("ERR TSDB: compaction rule does not exist")
+)
+
+// Doer is an in-memory RedisTimeSeries which implements redists.Doer. Replies
+// have the same shape as the RESP2 replies of a real server, with strings
+// decoded as string. It is safe for concurrent use.
+type Doer struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	series map[string]*series
+}
+
+var _ redists.Doer = (*Doer)(nil)
+
+type OptionDoer func(d *Doer)
+
+// NewDoer returns an empty Doer.
+func NewDoer(options ...OptionDoer) *Doer {
+	d := &Doer{now: time.Now, series: map[string]*series{}}
+	for i := range options {
+		options[i](d)
+	}
+	return d
+}
+
+// DoerWithClock sets the clock used for `*` timestamps and for IncrBy and
+// DecrBy without a timestamp.
+func DoerWithClock(now func() time.Time) OptionDoer {
+	return func(d *Doer) {
+		d.now = now
+	}
+}
+
+type handler func(d *Doer, args []string) interface{}
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"TS.CREATE":     (*Doer).create,
+		"TS.ALTER":      (*Doer).alter,
+		"TS.ADD":        (*Doer).add,
+		"TS.MADD":       (*Doer).mAdd,
+		"TS.INCRBY":     func(d *Doer, args []string) interface{} { return d.counter(args, 1) },
+		"TS.DECRBY":     func(d *Doer, args []string) interface{} { return d.counter(args, -1) },
+		"TS.DEL":        (*Doer).del,
+		"TS.CREATERULE": (*Doer).createRule,
+		"TS.DELETERULE": (*Doer).deleteRule,
+		"TS.RANGE":      func(d *Doer, args []string) interface{} { return d.ranger(args, false) },
+		"TS.REVRANGE":   func(d *Doer, args []string) interface{} { return d.ranger(args, true) },
+		"TS.MRANGE":     func(d *Doer, args []string) interface{} { return d.mRanger(args, false) },
+		"TS.MREVRANGE":  func(d *Doer, args []string) interface{} { return d.mRanger(args, true) },
+		"TS.GET":        (*Doer).get,
+		"TS.MGET":       (*Doer).mGet,
+		"TS.QUERYINDEX": (*Doer).queryIndex,
+		"TS.INFO":       (*Doer).info,
+		"PING":          (*Doer).ping,
+		"EXISTS":        (*Doer).exists,
+		"DEL":           (*Doer).delKeys,
+		"TYPE":          (*Doer).keyType,
+		"PTTL":          (*Doer).pTTL,
+	}
+}
+
+// Do executes cmd. An error reply is returned as error, while the errors of
+// TS.MADD samples are Error values in the returned array.
+func (d *Doer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sargs := make([]string, len(args))
+	for i := range args {
+		sargs[i] = argString(args[i])
+	}
+	res := d.exec(cmd, sargs)
+	if err, ok := res.(error); ok {
+		return nil, err
+	}
+	return resp2Value(res), nil
+}
+
+// exec executes cmd and returns the reply tree.
+func (d *Doer) exec(cmd string, args []string) interface{} {
+	h, ok := handlers[strings.ToUpper(cmd)]
+	if !ok {
+		return Error(fmt.Sprintf("ERR unknown command '%s'", cmd))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return h(d, args)
+}
+
+// writtenKeys returns the keys which are changed by the write command cmd,
+// including the destinations of their compaction rules. It returns nil for
+// the other commands.
+func (d *Doer) writtenKeys(cmd string, args []string) []string {
+	var keys []string
+	switch strings.ToUpper(cmd) {
+	case "TS.CREATE", "TS.ALTER", "TS.ADD", "TS.INCRBY", "TS.DECRBY", "TS.DEL":
+		keys = append(keys, args[:1]...)
+	case "TS.MADD":
+		for i := 0; i < len(args); i += 3 {
+			keys = append(keys, args[i])
+		}
+	case "TS.CREATERULE", "TS.DELETERULE":
+		keys = append(keys, args[:2]...)
+	case "DEL":
+		keys = append(keys, args...)
+	default:
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	seen := map[string]bool{}
+	var written []string
+	for len(keys) > 0 {
+		key := keys[0]
+		keys = keys[1:]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		written = append(written, key)
+		if s, ok := d.series[key]; ok {
+			for _, r := range s.rules {
+				keys = append(keys, r.dest)
+			}
+		}
+	}
+	return written
+}
+
+// argString converts an argument the same way Redis clients do.
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func parseValue(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errInvalidValue
+	}
+	return v, nil
+}
+
+func parseTimestamp(s string) (int64, error) {
+	switch s {
+	case "-":
+		return 0, nil
+	case "+":
+		return math.MaxInt64, nil
+	}
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errInvalidTimestamp
+	}
+	return ts, nil
+}
+
+func (d *Doer) nowMilli() int64 {
+	return d.now().UnixMilli()
+}
+
+func (d *Doer) lookup(key string) (*series, error) {
+	s, ok := d.series[key]
+	if !ok {
+		return nil, errKeyNotExist
+	}
+	return s, nil
+}
+
+// seriesOptions are the options shared by TS.CREATE, TS.ALTER, TS.ADD,
+// TS.INCRBY and TS.DECRBY.
+type seriesOptions struct {
+	retention       *int64
+	chunkSize       *int64
+	uncompressed    *bool
+	duplicatePolicy *string
+	onDuplicate     string
+	timestamp       *string
+	labels          map[string]string
+}
+
+// parseSeriesOptions parses args. The allowed map lists the accepted option
+// names.
+func parseSeriesOptions(args []string, allowed map[string]bool) (seriesOptions, error) {
+	var o seriesOptions
+	for i := 0; i < len(args); i++ {
+		name := strings.ToUpper(args[i])
+		if !allowed[name] {
+			return o, errSyntax
+		}
+		if name == "UNCOMPRESSED" {
+			v := true
+			o.uncompressed = &v
+			continue
+		}
+		if name == "LABELS" {
+			rest := args[i+1:]
+			if len(rest)%2 != 0 {
+				return o, errSyntax
+			}
+			o.labels = make(map[string]string, len(rest)/2)
+			for j := 0; j < len(rest); j += 2 {
+				o.labels[rest[j]] = rest[j+1]
+			}
+			break
+		}
+		if i+1 >= len(args) {
+			return o, errSyntax
+		}
+		i++
+		val := args[i]
+		switch name {
+		case "RETENTION":
+			v, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || v < 0 {
+				return o, errInvalidRetention
+			}
+			o.retention = &v
+		case "CHUNK_SIZE":
+			v, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || v <= 0 {
+				return o, errInvalidChunkSize
+			}
+			o.chunkSize = &v
+		case "ENCODING":
+			var v bool
+			switch strings.ToUpper(val) {
+			case "COMPRESSED":
+			case "UNCOMPRESSED":
+				v = true
+			default:
+				return o, errInvalidEncoding
+			}
+			o.uncompressed = &v
+		case "DUPLICATE_POLICY", "ON_DUPLICATE":
+			v := strings.ToUpper(val)
+			if !duplicatePolicies[v] {
+				return o, errInvalidPolicy
+			}
+			if name == "ON_DUPLICATE" {
+				o.onDuplicate = v
+			} else {
+				o.duplicatePolicy = &v
+			}
+		case "TIMESTAMP":
+			o.timestamp = &val
+		}
+	}
+	return o, nil
+}
+
+func (o seriesOptions) apply(s *series) {
+	if o.retention != nil {
+		s.retention = *o.retention
+	}
+	if o.chunkSize != nil {
+		s.chunkSize = *o.chunkSize
+	}
+	if o.uncompressed != nil {
+		s.uncompressed = *o.uncompressed
+	}
+	if o.duplicatePolicy != nil {
+		s.duplicatePolicy = *o.duplicatePolicy
+	}
+	if o.labels != nil {
+		s.labels = o.labels
+	}
+}
+
+var duplicatePolicies = map[string]bool{
+	string(redists.DuplicatePolicyBlock): true,
+	string(redists.DuplicatePolicyFirst): true,
+	string(redists.DuplicatePolicyLast):  true,
+	string(redists.DuplicatePolicyMin):   true,
+	string(redists.DuplicatePolicyMax):   true,
+	string(redists.DuplicatePolicySum):   true,
+}
+
+var (
+	createOptions  = map[string]bool{"RETENTION": true, "ENCODING": true, "UNCOMPRESSED": true, "CHUNK_SIZE": true, "DUPLICATE_POLICY": true, "LABELS": true}
+	alterOptions   = map[string]bool{"RETENTION": true, "CHUNK_SIZE": true, "DUPLICATE_POLICY": true, "LABELS": true}
+	addOptions     = map[string]bool{"RETENTION": true, "ENCODING": true, "UNCOMPRESSED": true, "CHUNK_SIZE": true, "ON_DUPLICATE": true, "LABELS": true}
+	counterOptions = map[string]bool{"TIMESTAMP": true, "RETENTION": true, "ENCODING": true, "UNCOMPRESSED": true, "CHUNK_SIZE": true, "LABELS": true}
+)
+
+func (d *Doer) create(args []string) interface{} {
+	if len(args) < 1 {
+		return errWrongArgs
+	}
+	if _, ok := d.series[args[0]]; ok {
+		return errKeyExists
+	}
+	o, err := parseSeriesOptions(args[1:], createOptions)
+	if err != nil {
+		return err
+	}
+	d.newSeries(args[0], o)
+	return status("OK")
+}
+
+func (d *Doer) newSeries(key string, o seriesOptions) *series {
+	s := newSeries(key)
+	o.apply(s)
+	d.series[key] = s
+	return s
+}
+
+func (d *Doer) alter(args []string) interface{} {
+	if len(args) < 1 {
+		return errWrongArgs
+	}
+	s, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	o, err := parseSeriesOptions(args[1:], alterOptions)
+	if err != nil {
+		return err
+	}
+	o.apply(s)
+	return status("OK")
+}
+
+func (d *Doer) add(args []string) interface{} {
+	if len(args) < 3 {
+		return errWrongArgs
+	}
+	ts, err := d.parseAddTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+	v, err := parseValue(args[2])
+	if err != nil {
+		return err
+	}
+	o, err := parseSeriesOptions(args[3:], addOptions)
+	if err != nil {
+		return err
+	}
+	s, ok := d.series[args[0]]
+	if !ok {
+		s = d.newSeries(args[0], o)
+	}
+	if err := d.upsert(s, ts, v, o.onDuplicate); err != nil {
+		return err
+	}
+	return ts
+}
+
+func (d *Doer) parseAddTimestamp(arg string) (int64, error) {
+	if arg == "*" {
+		return d.nowMilli(), nil
+	}
+	ts, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errInvalidTimestamp
+	}
+	return ts, nil
+}
+
+func (d *Doer) mAdd(args []string) interface{} {
+	if len(args) == 0 || len(args)%3 != 0 {
+		return errWrongArgs
+	}
+	res := make([]interface{}, 0, len(args)/3)
+	for i := 0; i < len(args); i += 3 {
+		res = append(res, d.mAddSample(args[i], args[i+1], args[i+2]))
+	}
+	return res
+}
+
+func (d *Doer) mAddSample(key, tsArg, valArg string) interface{} {
+	s, err := d.lookup(key)
+	if err != nil {
+		return err
+	}
+	ts, err := d.parseAddTimestamp(tsArg)
+	if err != nil {
+		return err
+	}
+	v, err := parseValue(valArg)
+	if err != nil {
+		return err
+	}
+	if err := d.upsert(s, ts, v, ""); err != nil {
+		return err
+	}
+	return ts
+}
+
+func (d *Doer) counter(args []string, sign float64) interface{} {
+	if len(args) < 2 {
+		return errWrongArgs
+	}
+	v, err := parseValue(args[1])
+	if err != nil {
+		return err
+	}
+	o, err := parseSeriesOptions(args[2:], counterOptions)
+	if err != nil {
+		return err
+	}
+	ts := d.nowMilli()
+	if o.timestamp != nil {
+		if ts, err = d.parseAddTimestamp(*o.timestamp); err != nil {
+			return err
+		}
+	}
+	s, ok := d.series[args[0]]
+	if !ok {
+		s = d.newSeries(args[0], o)
+	}
+	if last, ok := s.last(); ok {
+		if ts < last.ts {
+			return errNotLatest
+		}
+		v = last.v + sign*v
+	} else {
+		v = sign * v
+	}
+	if err := d.upsert(s, ts, v, string(redists.DuplicatePolicyLast)); err != nil {
+		return err
+	}
+	return ts
+}
+
+func (d *Doer) del(args []string) interface{} {
+	if len(args) != 3 {
+		return errWrongArgs
+	}
+	s, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	from, err := parseTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+	to, err := parseTimestamp(args[2])
+	if err != nil {
+		return err
+	}
+	return s.delete(from, to)
+}
+
+func (d *Doer) createRule(args []string) interface{} {
+	if len(args) != 5 && len(args) != 6 {
+		return errWrongArgs
+	}
+	if strings.ToUpper(args[2]) != "AGGREGATION" {
+		return errSyntax
+	}
+	if args[0] == args[1] {
+		return errSameKey
+	}
+	src, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	dest, err := d.lookup(args[1])
+	if err != nil {
+		return err
+	}
+	r := rule{dest: args[1], agg: strings.ToUpper(args[3])}
+	if _, ok := aggregators[r.agg]; !ok {
+		return errInvalidAgg
+	}
+	if r.bucket, err = strconv.ParseInt(args[4], 10, 64); err != nil || r.bucket <= 0 {
+		return errInvalidBucket
+	}
+	if len(args) == 6 {
+		if r.align, err = strconv.ParseInt(args[5], 10, 64); err != nil {
+			return errInvalidTimestamp
+		}
+	}
+	if dest.source != "" {
+		return errDestHasRule
+	}
+	src.rules = append(src.rules, r)
+	dest.source = src.key
+	return status("OK")
+}
+
+func (d *Doer) deleteRule(args []string) interface{} {
+	if len(args) != 2 {
+		return errWrongArgs
+	}
+	src, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	for i, r := range src.rules {
+		if r.dest != args[1] {
+			continue
+		}
+		src.rules = append(src.rules[:i], src.rules[i+1:]...)
+		if dest, ok := d.series[args[1]]; ok {
+			dest.source = ""
+		}
+		return status("OK")
+	}
+	return errRuleNotExist
+}
+
+func (d *Doer) get(args []string) interface{} {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+	s, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	last, ok := s.last()
+	if !ok {
+		return []interface{}{}
+	}
+	return last.reply()
+}
+
+func (d *Doer) info(args []string) interface{} {
+	if len(args) != 1 && len(args) != 2 {
+		return errWrongArgs
+	}
+	s, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	debug := len(args) == 2
+	if debug && strings.ToUpper(args[1]) != "DEBUG" {
+		return errSyntax
+	}
+	return s.info(debug)
+}
+
+func (d *Doer) queryIndex(args []string) interface{} {
+	m, err := parseMatchers(args)
+	if err != nil {
+		return err
+	}
+	keys := d.match(m)
+	res := make([]interface{}, len(keys))
+	for i := range keys {
+		res[i] = keys[i]
+	}
+	return res
+}
+
+// match returns the sorted keys of the time-series matching m.
+func (d *Doer) match(m matchers) []string {
+	var keys []string
+	for key, s := range d.series {
+		if m.match(s.labels) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *Doer) ping(args []string) interface{} {
+	switch len(args) {
+	case 0:
+		return status("PONG")
+	case 1:
+		return args[0]
+	default:
+		return errWrongArgs
+	}
+}
+
+func (d *Doer) exists(args []string) interface{} {
+	if len(args) == 0 {
+		return errWrongArgs
+	}
+	var n int64
+	for _, key := range args {
+		if _, ok := d.series[key]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// pTTL is the PTTL command. Time-series never expire.
+func (d *Doer) pTTL(args []string) interface{} {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+	if _, ok := d.series[args[0]]; !ok {
+		return int64(-2)
+	}
+	return int64(-1)
+}
+
+// delKeys is the DEL command. It removes the compaction rules of the
+// deleted time-series as well.
+func (d *Doer) delKeys(args []string) interface{} {
+	if len(args) == 0 {
+		return errWrongArgs
+	}
+	var n int64
+	for _, key := range args {
+		s, ok := d.series[key]
+		if !ok {
+			continue
+		}
+		delete(d.series, key)
+		n++
+		if src, ok := d.series[s.source]; ok {
+			for i, r := range src.rules {
+				if r.dest == key {
+					src.rules = append(src.rules[:i], src.rules[i+1:]...)
+					break
+				}
+			}
+		}
+		for _, r := range s.rules {
+			if dest, ok := d.series[r.dest]; ok {
+				dest.source = ""
+			}
+		}
+	}
+	return n
+}
+
+func (d *Doer) keyType(args []string) interface{} {
+	if len(args) != 1 {
+		return errWrongArgs
+	}
+	if _, ok := d.series[args[0]]; !ok {
+		return status("none")
+	}
+	return status("TSDB-TYPE")
+}
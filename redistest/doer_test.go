@@ -0,0 +1,303 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+)
+
+var secondMillennium = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).In(time.Local)
+
+func ms(n int64) time.Time {
+	return secondMillennium.Add(time.Duration(n) * time.Millisecond)
+}
+
+func dp(n int64, v float64) redists.DataPoint {
+	return redists.DataPoint{Timestamp: ms(n), Value: v}
+}
+
+func TestDoer_Add(t *testing.T) {
+	ctx := context.Background()
+	c := redists.NewClient(NewDoer(DoerWithClock(func() time.Time { return ms(5) })))
+
+	if err := c.Create(ctx, "key:any", redists.CreateWithDuplicatePolicy(redists.DuplicatePolicyBlock)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := c.Create(ctx, "key:any"); !errors.Is(err, redists.ErrKeyExists) {
+		t.Errorf("Create() error = %v, want %v", err, redists.ErrKeyExists)
+	}
+	got, err := c.Add(ctx, redists.NewSample("key:any", redists.TSAuto(), 1))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if want := ms(5); !got.Equal(want) {
+		t.Errorf("Add() got = %v, want %v", got, want)
+	}
+	if _, err := c.Add(ctx, redists.NewSample("key:any", ms(5), 2)); !errors.Is(err, redists.ErrDuplicateBlocked) {
+		t.Errorf("Add() error = %v, want %v", err, redists.ErrDuplicateBlocked)
+	}
+	if _, err := c.Add(ctx, redists.NewSample("key:any", ms(5), 2), redists.AddWithOnDuplicate(redists.DuplicatePolicySum)); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+	rs, err := c.MAdd(ctx, []redists.Sample{
+		redists.NewSample("key:any", ms(6), 1),
+		redists.NewSample("key:missing", ms(6), 1),
+	})
+	if err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	if err := rs[1].Err(); !errors.Is(err, redists.ErrKeyNotExist) {
+		t.Errorf("MAdd() error = %v, want %v", err, redists.ErrKeyNotExist)
+	}
+	if got, want := rs[0].Time(), ms(6); !got.Equal(want) {
+		t.Errorf("MAdd() got = %v, want %v", got, want)
+	}
+	dps, err := c.Range(ctx, "key:any", redists.TSMin(), redists.TSMax())
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	if want := []redists.DataPoint{dp(5, 3), dp(6, 1)}; !reflect.DeepEqual(dps, want) {
+		t.Errorf("Range() got = %v, want %v", dps, want)
+	}
+}
+
+func TestDoer_retention(t *testing.T) {
+	ctx := context.Background()
+	c := redists.NewClient(NewDoer())
+
+	for _, n := range []int64{0, 1000, 2000} {
+		if _, err := c.Add(ctx, redists.NewSample("key:any", ms(n), 1), redists.AddWithRetention(time.Second)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if _, err := c.Add(ctx, redists.NewSample("key:any", ms(500), 1)); !errors.Is(err, redists.ErrTimestampTooOld) {
+		t.Errorf("Add() error = %v, want %v", err, redists.ErrTimestampTooOld)
+	}
+	inf, err := c.Info(ctx, "key:any")
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	if got, want := inf.TotalSamples, int64(2); got != want {
+		t.Errorf("Info() TotalSamples = %v, want %v", got, want)
+	}
+	if got, want := inf.FirstTimestamp, ms(1000); !got.Equal(want) {
+		t.Errorf("Info() FirstTimestamp = %v, want %v", got, want)
+	}
+}
+
+func TestDoer_IncrBy(t *testing.T) {
+	ctx := context.Background()
+	now := ms(0)
+	c := redists.NewClient(NewDoer(DoerWithClock(func() time.Time { return now })))
+
+	if _, err := c.IncrBy(ctx, "key:any", 2); err != nil {
+		t.Fatalf("IncrBy() error = %v", err)
+	}
+	now = ms(1000)
+	if _, err := c.DecrBy(ctx, "key:any", 0.5); err != nil {
+		t.Fatalf("DecrBy() error = %v", err)
+	}
+	if _, err := c.IncrBy(ctx, "key:any", 1, redists.CounterWithTimestamp(ms(0))); !errors.Is(err, redists.ErrTimestampNotLatest) {
+		t.Errorf("IncrBy() error = %v, want %v", err, redists.ErrTimestampNotLatest)
+	}
+	dps, err := c.Range(ctx, "key:any", redists.TSMin(), redists.TSMax())
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	if want := []redists.DataPoint{dp(0, 2), dp(1000, 1.5)}; !reflect.DeepEqual(dps, want) {
+		t.Errorf("Range() got = %v, want %v", dps, want)
+	}
+}
+
+func TestDoer_Range(t *testing.T) {
+	ctx := context.Background()
+	c := redists.NewClient(NewDoer())
+
+	var samples []redists.Sample
+	for i := int64(0); i < 6; i++ {
+		samples = append(samples, redists.NewSample("key:any", ms(i*500), float64(i)))
+	}
+	if err := c.Create(ctx, "key:any"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := c.MAdd(ctx, samples); err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		rev     bool
+		options []redists.OptionRanger
+		want    []redists.DataPoint
+	}{
+		{
+			name:    "aggregation",
+			options: []redists.OptionRanger{redists.RangerWithAggregation(redists.AggregationTypeSum, time.Second)},
+			want:    []redists.DataPoint{dp(0, 1), dp(1000, 5), dp(2000, 9)},
+		},
+		{
+			name: "align",
+			options: []redists.OptionRanger{
+				redists.RangerWithAlign(ms(500)),
+				redists.RangerWithAggregation(redists.AggregationTypeMax, time.Second),
+			},
+			want: []redists.DataPoint{dp(-500, 0), dp(500, 2), dp(1500, 4), dp(2500, 5)},
+		},
+		{
+			name: "filters",
+			options: []redists.OptionRanger{
+				redists.RangerWithTSFilter(ms(0), ms(500), ms(1000), ms(1500)),
+				redists.RangerWithValueFilter(1, 5),
+			},
+			want: []redists.DataPoint{dp(500, 1), dp(1000, 2), dp(1500, 3)},
+		},
+		{
+			name:    "reverse count",
+			rev:     true,
+			options: []redists.OptionRanger{redists.RangerWithCount(2)},
+			want:    []redists.DataPoint{dp(2500, 5), dp(2000, 4)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := c.Range
+			if tt.rev {
+				f = c.RevRange
+			}
+			got, err := f(ctx, "key:any", redists.TSMin(), redists.TSMax(), tt.options...)
+			if err != nil {
+				t.Fatalf("Range() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Range() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoer_CreateRule(t *testing.T) {
+	ctx := context.Background()
+	c := redists.NewClient(NewDoer())
+
+	for _, key := range []string{"key:src", "key:dest"} {
+		if err := c.Create(ctx, key); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if err := c.CreateRule(ctx, "key:src", "key:dest", redists.AggregationTypeAvg, time.Second); err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+	if err := c.CreateRule(ctx, "key:src", "key:dest", redists.AggregationTypeAvg, time.Second); !errors.Is(err, redists.ErrRuleExists) {
+		t.Errorf("CreateRule() error = %v, want %v", err, redists.ErrRuleExists)
+	}
+	for _, n := range []int64{0, 500, 1000, 1500, 2000} {
+		if _, err := c.Add(ctx, redists.NewSample("key:src", ms(n), float64(n))); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	dps, err := c.Range(ctx, "key:dest", redists.TSMin(), redists.TSMax())
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	if want := []redists.DataPoint{dp(0, 250), dp(1000, 1250)}; !reflect.DeepEqual(dps, want) {
+		t.Errorf("Range() got = %v, want %v", dps, want)
+	}
+	inf, err := c.Info(ctx, "key:src")
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	want := redists.Rules{"key:dest": {Type: redists.AggregationTypeAvg, Bucket: time.Second}}
+	if !reflect.DeepEqual(inf.Rules, want) {
+		t.Errorf("Info() Rules = %v, want %v", inf.Rules, want)
+	}
+	if err := c.DeleteRule(ctx, "key:src", "key:dest"); err != nil {
+		t.Fatalf("DeleteRule() error = %v", err)
+	}
+	if err := c.DeleteRule(ctx, "key:src", "key:dest"); !errors.Is(err, redists.ErrRuleNotExist) {
+		t.Errorf("DeleteRule() error = %v, want %v", err, redists.ErrRuleNotExist)
+	}
+}
+
+func TestDoer_MRange(t *testing.T) {
+	ctx := context.Background()
+	c := redists.NewClient(NewDoer())
+
+	series := map[string]redists.Labels{
+		"key:a": {"type": "cpu", "host": "a"},
+		"key:b": {"type": "cpu", "host": "b"},
+		"key:c": {"type": "mem", "host": "a"},
+	}
+	for key, labels := range series {
+		if err := c.Create(ctx, key, redists.CreateWithLabels(labels)); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err := c.Add(ctx, redists.NewSample(key, ms(0), 1)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	keys, err := c.QueryIndex(ctx, []redists.Filter{redists.FilterEqual("host", "a")})
+	if err != nil {
+		t.Fatalf("QueryIndex() error = %v", err)
+	}
+	if want := []string{"key:a", "key:c"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("QueryIndex() got = %v, want %v", keys, want)
+	}
+	if _, err := c.QueryIndex(ctx, []redists.Filter{redists.FilterNotEqual("host", "a")}); !errors.Is(err, redists.ErrInvalidFilter) {
+		t.Errorf("QueryIndex() error = %v, want %v", err, redists.ErrInvalidFilter)
+	}
+
+	tss, err := c.MRange(ctx, redists.TSMin(), redists.TSMax(), []redists.Filter{redists.FilterEqual("type", "cpu", "mem")}, redists.MRangerWithGroupBy("host", redists.ReducerSum))
+	if err != nil {
+		t.Fatalf("MRange() error = %v", err)
+	}
+	want := []redists.TimeSeries{
+		{
+			Key:        "host=a",
+			Labels:     redists.Labels{"host": "a", "__reducer__": "sum", "__source__": "key:a,key:c"},
+			DataPoints: []redists.DataPoint{dp(0, 2)},
+		},
+		{
+			Key:        "host=b",
+			Labels:     redists.Labels{"host": "b", "__reducer__": "sum", "__source__": "key:b"},
+			DataPoints: []redists.DataPoint{dp(0, 1)},
+		},
+	}
+	if !reflect.DeepEqual(tss, want) {
+		t.Errorf("MRange() got = %v, want %v", tss, want)
+	}
+
+	lds, err := c.MGet(ctx, []redists.Filter{redists.FilterEqual("type", "cpu"), redists.FilterNotEqual("host", "a")}, redists.MGetWithLabels("host", "missing"))
+	if err != nil {
+		t.Fatalf("MGet() error = %v", err)
+	}
+	wantLds := []redists.LastDatapoint{
+		{Key: "key:b", Labels: redists.Labels{"host": "b", "missing": ""}, DataPoint: &redists.DataPoint{Timestamp: ms(0), Value: 1}},
+	}
+	if !reflect.DeepEqual(lds, wantLds) {
+		t.Errorf("MGet() got = %v, want %v", lds, wantLds)
+	}
+}
+
+func TestDoer_Del(t *testing.T) {
+	ctx := context.Background()
+	c := redists.NewClient(NewDoer())
+
+	for _, n := range []int64{0, 1000, 2000} {
+		if _, err := c.Add(ctx, redists.NewSample("key:any", ms(n), 1)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	got, err := c.Del(ctx, "key:any", ms(500), ms(2000))
+	if err != nil {
+		t.Fatalf("Del() error = %v", err)
+	}
+	if want := int64(2); got != want {
+		t.Errorf("Del() got = %v, want %v", got, want)
+	}
+	if _, err := c.Del(ctx, "key:missing", ms(0), ms(0)); !errors.Is(err, redists.ErrKeyNotExist) {
+		t.Errorf("Del() error = %v, want %v", err, redists.ErrKeyNotExist)
+	}
+}
@@ -0,0 +1,258 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coding-socks/redists"
+)
+
+// Matcher matches a command argument.
+type Matcher interface {
+	Match(arg interface{}) bool
+	String() string
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(interface{}) bool { return true }
+func (anyArg) String() string         { return "<any>" }
+
+// AnyArg returns a Matcher which matches any argument.
+func AnyArg() Matcher {
+	return anyArg{}
+}
+
+type equalArg struct {
+	v interface{}
+}
+
+func (m equalArg) Match(arg interface{}) bool { return argString(arg) == argString(m.v) }
+func (m equalArg) String() string             { return fmt.Sprintf("%q", argString(m.v)) }
+
+// ArgEqual returns a Matcher which matches arguments equal to v. Arguments
+// are compared in the form they are sent to Redis, so 1000, int64(1000) and
+// "1000" are equal. Plain values passed to Expectation.WithArgs are matched
+// by ArgEqual.
+func ArgEqual(v interface{}) Matcher {
+	return equalArg{v: v}
+}
+
+type funcArg struct {
+	desc string
+	f    func(arg interface{}) bool
+}
+
+func (m funcArg) Match(arg interface{}) bool { return m.f(arg) }
+func (m funcArg) String() string             { return "<" + m.desc + ">" }
+
+// ArgFunc returns a Matcher which matches arguments for which f returns
+// true. The description is used in error messages.
+func ArgFunc(desc string, f func(arg interface{}) bool) Matcher {
+	return funcArg{desc: desc, f: f}
+}
+
+// Expectation is an expected command of Mock.
+type Expectation struct {
+	cmd       string
+	args      []Matcher
+	reply     interface{}
+	err       error
+	fulfilled bool
+}
+
+// WithArgs sets the expected arguments. Values which are not a Matcher are
+// matched by ArgEqual. Without WithArgs, any arguments are accepted.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = make([]Matcher, len(args))
+	for i := range args {
+		m, ok := args[i].(Matcher)
+		if !ok {
+			m = ArgEqual(args[i])
+		}
+		e.args[i] = m
+	}
+	return e
+}
+
+// WillReturn sets the reply of the command. The reply should have the shape
+// a Redis client returns, e.g. []interface{}{int64(1000), "0.5"}.
+func (e *Expectation) WillReturn(reply interface{}) *Expectation {
+	e.reply = reply
+	return e
+}
+
+// WillReturnError sets the error of the command.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.args == nil {
+		return e.cmd + " <any args>"
+	}
+	ss := make([]string, len(e.args)+1)
+	ss[0] = e.cmd
+	for i, m := range e.args {
+		ss[i+1] = m.String()
+	}
+	return strings.Join(ss, " ")
+}
+
+func (e *Expectation) match(cmd string, args []interface{}) bool {
+	if !strings.EqualFold(e.cmd, cmd) {
+		return false
+	}
+	if e.args == nil {
+		return true
+	}
+	if len(e.args) != len(args) {
+		return false
+	}
+	for i, m := range e.args {
+		if !m.Match(args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// diff describes why cmd and args do not match e.
+func (e *Expectation) diff(cmd string, args []interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\texpected: %s", e)
+	if !strings.EqualFold(e.cmd, cmd) {
+		fmt.Fprintf(&b, "\n\tcommand: got %s, want %s", cmd, e.cmd)
+		return b.String()
+	}
+	for i := 0; i < len(e.args) || i < len(args); i++ {
+		switch {
+		case i >= len(args):
+			fmt.Fprintf(&b, "\n\targs[%d]: missing, want %s", i, e.args[i])
+		case i >= len(e.args):
+			fmt.Fprintf(&b, "\n\targs[%d]: got %q, want nothing", i, argString(args[i]))
+		case !e.args[i].Match(args[i]):
+			fmt.Fprintf(&b, "\n\targs[%d]: got %q, want %s", i, argString(args[i]), e.args[i])
+		}
+	}
+	return b.String()
+}
+
+// Mock is a redists.Doer which replies according to expectations. By default
+// the commands must arrive in the order of the expectations, and every
+// expectation is fulfilled by a single command.
+//
+//	mock := redistest.NewMock()
+//	mock.ExpectCmd(redists.NewCmdMGet([]redists.Filter{redists.FilterEqual("env", "prod")})).
+//		WillReturn([]interface{}{})
+//	// ... code under test
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+type Mock struct {
+	mu         sync.Mutex
+	es         []*Expectation
+	unordered  bool
+	unexpected []error
+}
+
+var _ redists.Doer = (*Mock)(nil)
+
+// NewMock returns a Mock without expectations.
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// Expect adds an expectation of cmd.
+func (m *Mock) Expect(cmd string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{cmd: cmd}
+	m.es = append(m.es, e)
+	return e
+}
+
+// ExpectCmd adds an expectation of cmd with the arguments it produces.
+func (m *Mock) ExpectCmd(cmd redists.Cmd) *Expectation {
+	return m.Expect(cmd.Name()).WithArgs(cmd.Args()...)
+}
+
+// MatchExpectationsInOrder sets whether the commands must arrive in the
+// order of the expectations. It is true by default.
+func (m *Mock) MatchExpectationsInOrder(ordered bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unordered = !ordered
+}
+
+func (m *Mock) Do(_ context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var next *Expectation
+	for _, e := range m.es {
+		if e.fulfilled {
+			continue
+		}
+		if next == nil {
+			next = e
+		}
+		if e.match(cmd, args) {
+			e.fulfilled = true
+			return e.reply, e.err
+		}
+		if !m.unordered {
+			break
+		}
+	}
+	err := m.unexpectedError(cmd, args, next)
+	m.unexpected = append(m.unexpected, err)
+	return nil, err
+}
+
+func (m *Mock) unexpectedError(cmd string, args []interface{}, next *Expectation) error {
+	call := cmd
+	if len(args) > 0 {
+		ss := make([]string, len(args))
+		for i := range args {
+			ss[i] = fmt.Sprintf("%q", argString(args[i]))
+		}
+		call += " " + strings.Join(ss, " ")
+	}
+	if next == nil {
+		return fmt.Errorf("redistest: unexpected command %s: all expectations were already fulfilled", call)
+	}
+	if m.unordered {
+		// compare with the first remaining expectation of the same command
+		for _, e := range m.es {
+			if !e.fulfilled && strings.EqualFold(e.cmd, cmd) {
+				next = e
+				break
+			}
+		}
+	}
+	return fmt.Errorf("redistest: unexpected command %s%s", call, next.diff(cmd, args))
+}
+
+// ExpectationsWereMet returns an error when an expectation was not
+// fulfilled or a command was unexpected.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var msgs []string
+	for _, err := range m.unexpected {
+		msgs = append(msgs, err.Error())
+	}
+	for _, e := range m.es {
+		if !e.fulfilled {
+			msgs = append(msgs, "redistest: expectation was not fulfilled: "+e.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
@@ -0,0 +1,81 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+)
+
+func TestMock(t *testing.T) {
+	ctx := context.Background()
+	filters := []redists.Filter{redists.FilterEqual("env", "prod")}
+
+	t.Run("ordered", func(t *testing.T) {
+		m := NewMock()
+		m.ExpectCmd(redists.NewCmdMRange(redists.TSMin(), redists.TSMax(), filters)).
+			WillReturn([]interface{}{
+				[]interface{}{"key:any", []interface{}{}, []interface{}{[]interface{}{int64(1000), "0.5"}}},
+			})
+		m.Expect("TS.ADD").
+			WithArgs("key:any", AnyArg(), ArgFunc("positive", func(arg interface{}) bool {
+				v, ok := arg.(float64)
+				return ok && v > 0
+			})).
+			WillReturn(int64(2000))
+		m.Expect("TS.GET").WillReturnError(Error("ERR TSDB: the key does not exist"))
+
+		c := redists.NewClient(m)
+		tss, err := c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters)
+		if err != nil {
+			t.Fatalf("MRange() error = %v", err)
+		}
+		want := []redists.TimeSeries{{Key: "key:any", Labels: redists.Labels{}, DataPoints: []redists.DataPoint{{Timestamp: time.UnixMilli(1000), Value: 0.5}}}}
+		if !reflect.DeepEqual(tss, want) {
+			t.Errorf("MRange() got = %v, want %v", tss, want)
+		}
+		if _, err := c.Add(ctx, redists.NewSample("key:any", redists.TSAuto(), 1)); err != nil {
+			t.Errorf("Add() error = %v", err)
+		}
+		if _, err := c.Get(ctx, "key:other"); !errors.Is(err, redists.ErrKeyNotExist) {
+			t.Errorf("Get() error = %v, want %v", err, redists.ErrKeyNotExist)
+		}
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("ExpectationsWereMet() error = %v", err)
+		}
+	})
+	t.Run("unordered", func(t *testing.T) {
+		m := NewMock()
+		m.MatchExpectationsInOrder(false)
+		m.Expect("TS.DEL").WithArgs("key:any", "-", "+").WillReturn(int64(1))
+		m.Expect("TS.CREATE").WithArgs("key:any").WillReturn("OK")
+
+		c := redists.NewClient(m)
+		if err := c.Create(ctx, "key:any"); err != nil {
+			t.Errorf("Create() error = %v", err)
+		}
+		if err := m.ExpectationsWereMet(); err == nil || !strings.Contains(err.Error(), "not fulfilled: TS.DEL") {
+			t.Errorf("ExpectationsWereMet() error = %v, want TS.DEL not fulfilled", err)
+		}
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		m := NewMock()
+		m.ExpectCmd(redists.NewCmdMGet(filters))
+
+		c := redists.NewClient(m)
+		_, err := c.MGet(ctx, []redists.Filter{redists.FilterEqual("env", "dev")})
+		want := `redistest: unexpected command TS.MGET "FILTER" "env=dev"
+	expected: TS.MGET "FILTER" "env=prod"
+	args[1]: got "env=dev", want "env=prod"`
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("MGet() error = %v, want %v", err, want)
+		}
+		if err := m.ExpectationsWereMet(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ExpectationsWereMet() error = %v, want %v", err, want)
+		}
+	})
+}
@@ -0,0 +1,525 @@
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coding-socks/redists"
+)
+
+type aggregator func(ss []sample) float64
+
+var aggregators = map[string]aggregator{
+	string(redists.AggregationTypeAvg): func(ss []sample) float64 {
+		return sum(ss) / float64(len(ss))
+	},
+	string(redists.AggregationTypeSum): sum,
+	string(redists.AggregationTypeMin): func(ss []sample) float64 {
+		v := ss[0].v
+		for _, s := range ss[1:] {
+			v = math.Min(v, s.v)
+		}
+		return v
+	},
+	string(redists.AggregationTypeMax): func(ss []sample) float64 {
+		v := ss[0].v
+		for _, s := range ss[1:] {
+			v = math.Max(v, s.v)
+		}
+		return v
+	},
+	string(redists.AggregationTypeRange): func(ss []sample) float64 {
+		lo, hi := ss[0].v, ss[0].v
+		for _, s := range ss[1:] {
+			lo, hi = math.Min(lo, s.v), math.Max(hi, s.v)
+		}
+		return hi - lo
+	},
+	string(redists.AggregationTypeCount): func(ss []sample) float64 {
+		return float64(len(ss))
+	},
+	string(redists.AggregationTypeFirst): func(ss []sample) float64 {
+		return ss[0].v
+	},
+	string(redists.AggregationTypeLast): func(ss []sample) float64 {
+		return ss[len(ss)-1].v
+	},
+	string(redists.AggregationTypeStdP): func(ss []sample) float64 {
+		return math.Sqrt(variance(ss, 0))
+	},
+	string(redists.AggregationTypeStdS): func(ss []sample) float64 {
+		return math.Sqrt(variance(ss, 1))
+	},
+	string(redists.AggregationTypeVarP): func(ss []sample) float64 {
+		return variance(ss, 0)
+	},
+	string(redists.AggregationTypeVarS): func(ss []sample) float64 {
+		return variance(ss, 1)
+	},
+	string(redists.AggregationTypeTWA): twa,
+}
+
+func sum(ss []sample) float64 {
+	var v float64
+	for _, s := range ss {
+		v += s.v
+	}
+	return v
+}
+
+// variance returns the variance of the values. ddof is 0 for the population
+// and 1 for the sample variance.
+func variance(ss []sample, ddof int) float64 {
+	if len(ss) <= ddof {
+		return 0
+	}
+	mean := sum(ss) / float64(len(ss))
+	var v float64
+	for _, s := range ss {
+		v += (s.v - mean) * (s.v - mean)
+	}
+	return v / float64(len(ss)-ddof)
+}
+
+// twa returns the time-weighted average of the samples using linear
+// interpolation between them.
+func twa(ss []sample) float64 {
+	if len(ss) == 1 || ss[len(ss)-1].ts == ss[0].ts {
+		return ss[0].v
+	}
+	var area float64
+	for i := 1; i < len(ss); i++ {
+		area += (ss[i-1].v + ss[i].v) / 2 * float64(ss[i].ts-ss[i-1].ts)
+	}
+	return area / float64(ss[len(ss)-1].ts-ss[0].ts)
+}
+
+// aggregate returns one sample per non-empty bucket. The timestamp of the
+// sample is the start of the bucket.
+func aggregate(ss []sample, agg string, bucket, align int64) []sample {
+	f := aggregators[agg]
+	var res []sample
+	for i := 0; i < len(ss); {
+		start := bucketStart(ss[i].ts, bucket, align)
+		j := i + 1
+		for j < len(ss) && ss[j].ts-start < bucket {
+			j++
+		}
+		res = append(res, sample{ts: start, v: f(ss[i:j])})
+		i = j
+	}
+	return res
+}
+
+// rangeOptions are the options shared by TS.RANGE and TS.MRANGE.
+type rangeOptions struct {
+	from, to   int64
+	tsFilter   map[int64]bool
+	valueMin   *float64
+	valueMax   float64
+	count      int64
+	align      int64
+	agg        string
+	bucket     int64
+	withLabels bool
+	selected   []string
+	filters    matchers
+	groupBy    string
+	reducer    string
+}
+
+// parseRangeOptions parses args after the from and to timestamps. multi
+// allows the options of TS.MRANGE.
+func parseRangeOptions(from, to string, args []string, multi bool) (rangeOptions, error) {
+	var o rangeOptions
+	var err error
+	if o.from, err = parseTimestamp(from); err != nil {
+		return o, err
+	}
+	if o.to, err = parseTimestamp(to); err != nil {
+		return o, err
+	}
+	var align string
+	for i := 0; i < len(args); i++ {
+		name := strings.ToUpper(args[i])
+		rest := args[i+1:]
+		switch name {
+		case "FILTER_BY_TS":
+			o.tsFilter = map[int64]bool{}
+			for len(rest) > 0 {
+				ts, err := strconv.ParseInt(rest[0], 10, 64)
+				if err != nil {
+					break
+				}
+				o.tsFilter[ts] = true
+				rest = rest[1:]
+				i++
+			}
+			if len(o.tsFilter) == 0 {
+				return o, errInvalidTimestamp
+			}
+		case "FILTER_BY_VALUE":
+			if len(rest) < 2 {
+				return o, errSyntax
+			}
+			min, err := parseValue(rest[0])
+			if err != nil {
+				return o, err
+			}
+			if o.valueMax, err = parseValue(rest[1]); err != nil {
+				return o, err
+			}
+			o.valueMin = &min
+			i += 2
+		case "COUNT":
+			if len(rest) < 1 {
+				return o, errSyntax
+			}
+			if o.count, err = strconv.ParseInt(rest[0], 10, 64); err != nil || o.count <= 0 {
+				return o, errInvalidCount
+			}
+			i++
+		case "ALIGN":
+			if len(rest) < 1 {
+				return o, errSyntax
+			}
+			align = rest[0]
+			i++
+		case "AGGREGATION":
+			if len(rest) < 2 {
+				return o, errSyntax
+			}
+			o.agg = strings.ToUpper(rest[0])
+			if _, ok := aggregators[o.agg]; !ok {
+				return o, errInvalidAgg
+			}
+			if o.bucket, err = strconv.ParseInt(rest[1], 10, 64); err != nil || o.bucket <= 0 {
+				return o, errInvalidBucket
+			}
+			i += 2
+		case "WITHLABELS":
+			if !multi {
+				return o, errSyntax
+			}
+			o.withLabels = true
+		case "SELECTED_LABELS":
+			if !multi {
+				return o, errSyntax
+			}
+			for len(rest) > 0 && !isRangeKeyword(rest[0]) {
+				o.selected = append(o.selected, rest[0])
+				rest = rest[1:]
+				i++
+			}
+			if len(o.selected) == 0 {
+				return o, errSyntax
+			}
+		case "FILTER":
+			if !multi {
+				return o, errSyntax
+			}
+			n := 0
+			for n < len(rest) && strings.ToUpper(rest[n]) != "GROUPBY" {
+				n++
+			}
+			if o.filters, err = parseMatchers(rest[:n]); err != nil {
+				return o, err
+			}
+			i += n
+		case "GROUPBY":
+			if !multi || len(rest) < 3 || strings.ToUpper(rest[1]) != "REDUCE" {
+				return o, errSyntax
+			}
+			o.groupBy, o.reducer = rest[0], strings.ToUpper(rest[2])
+			if _, ok := reducers[o.reducer]; !ok {
+				return o, errInvalidReducer
+			}
+			i += 3
+		default:
+			return o, errSyntax
+		}
+	}
+	if multi && o.filters == nil {
+		return o, errMissingFilter
+	}
+	switch strings.ToLower(align) {
+	case "":
+	case "-", "start":
+		o.align = o.from
+	case "+", "end":
+		o.align = o.to
+	default:
+		if o.align, err = strconv.ParseInt(align, 10, 64); err != nil {
+			return o, errInvalidTimestamp
+		}
+	}
+	return o, nil
+}
+
+func isRangeKeyword(arg string) bool {
+	switch strings.ToUpper(arg) {
+	case "FILTER_BY_TS", "FILTER_BY_VALUE", "COUNT", "ALIGN", "AGGREGATION", "WITHLABELS", "SELECTED_LABELS", "FILTER", "GROUPBY":
+		return true
+	}
+	return false
+}
+
+// query returns the samples of s selected by o in forward or reverse order.
+func (o rangeOptions) query(s *series, rev bool) []sample {
+	var ss []sample
+	for _, p := range s.window(o.from, o.to) {
+		if o.tsFilter != nil && !o.tsFilter[p.ts] {
+			continue
+		}
+		if o.valueMin != nil && (p.v < *o.valueMin || p.v > o.valueMax) {
+			continue
+		}
+		ss = append(ss, p)
+	}
+	if o.agg != "" {
+		ss = aggregate(ss, o.agg, o.bucket, o.align)
+	}
+	if rev {
+		for i, j := 0, len(ss)-1; i < j; i, j = i+1, j-1 {
+			ss[i], ss[j] = ss[j], ss[i]
+		}
+	}
+	if o.count > 0 && int64(len(ss)) > o.count {
+		ss = ss[:o.count]
+	}
+	return ss
+}
+
+func samplesReply(ss []sample) []interface{} {
+	res := make([]interface{}, len(ss))
+	for i := range ss {
+		res[i] = ss[i].reply()
+	}
+	return res
+}
+
+func (d *Doer) ranger(args []string, rev bool) interface{} {
+	if len(args) < 3 {
+		return errWrongArgs
+	}
+	s, err := d.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	o, err := parseRangeOptions(args[1], args[2], args[3:], false)
+	if err != nil {
+		return err
+	}
+	return samplesReply(o.query(s, rev))
+}
+
+func (d *Doer) mRanger(args []string, rev bool) interface{} {
+	if len(args) < 2 {
+		return errWrongArgs
+	}
+	o, err := parseRangeOptions(args[0], args[1], args[2:], true)
+	if err != nil {
+		return err
+	}
+	keys := d.match(o.filters)
+	if o.groupBy != "" {
+		return d.groupBy(keys, o, rev)
+	}
+	res := mapReply{kind: mapPrefixed}
+	for _, key := range keys {
+		s := d.series[key]
+		res.kv = append(res.kv, key, []interface{}{o.labels(s), samplesReply(o.query(s, rev))})
+	}
+	return res
+}
+
+func (o rangeOptions) labels(s *series) mapReply {
+	switch {
+	case o.selected != nil:
+		return labelsReply(s.labels, o.selected)
+	case o.withLabels:
+		return labelsReply(s.labels, nil)
+	default:
+		return mapReply{kind: mapPairs}
+	}
+}
+
+var reducers = map[string]aggregator{
+	string(redists.ReducerSum): sum,
+	string(redists.ReducerMin): aggregators[string(redists.AggregationTypeMin)],
+	string(redists.ReducerMax): aggregators[string(redists.AggregationTypeMax)],
+	"AVG":                      aggregators[string(redists.AggregationTypeAvg)],
+	"RANGE":                    aggregators[string(redists.AggregationTypeRange)],
+	"COUNT":                    aggregators[string(redists.AggregationTypeCount)],
+	"STD.P":                    aggregators[string(redists.AggregationTypeStdP)],
+	"STD.S":                    aggregators[string(redists.AggregationTypeStdS)],
+	"VAR.P":                    aggregators[string(redists.AggregationTypeVarP)],
+	"VAR.S":                    aggregators[string(redists.AggregationTypeVarS)],
+}
+
+// groupBy groups the time-series by the value of the GROUPBY label and
+// reduces the samples with the same timestamp in each group.
+func (d *Doer) groupBy(keys []string, o rangeOptions, rev bool) mapReply {
+	groups := map[string][]string{}
+	var values []string
+	for _, key := range keys {
+		v, ok := d.series[key].labels[o.groupBy]
+		if !ok {
+			continue
+		}
+		if _, ok := groups[v]; !ok {
+			values = append(values, v)
+		}
+		groups[v] = append(groups[v], key)
+	}
+	sort.Strings(values)
+	reduce := reducers[o.reducer]
+	res := mapReply{kind: mapPrefixed}
+	for _, v := range values {
+		byTS := map[int64][]sample{}
+		var tss []int64
+		for _, key := range groups[v] {
+			for _, p := range o.query(d.series[key], false) {
+				if _, ok := byTS[p.ts]; !ok {
+					tss = append(tss, p.ts)
+				}
+				byTS[p.ts] = append(byTS[p.ts], p)
+			}
+		}
+		sort.Slice(tss, func(i, j int) bool {
+			if rev {
+				return tss[i] > tss[j]
+			}
+			return tss[i] < tss[j]
+		})
+		ss := make([]sample, len(tss))
+		for j, ts := range tss {
+			ss[j] = sample{ts: ts, v: reduce(byTS[ts])}
+		}
+		labels := mapReply{kind: mapPairs, kv: []interface{}{
+			o.groupBy, v,
+			"__reducer__", strings.ToLower(o.reducer),
+			"__source__", strings.Join(groups[v], ","),
+		}}
+		res.kv = append(res.kv, o.groupBy+"="+v, []interface{}{labels, samplesReply(ss)})
+	}
+	return res
+}
+
+func (d *Doer) mGet(args []string) interface{} {
+	var withLabels bool
+	var selected []string
+	for len(args) > 0 && strings.ToUpper(args[0]) != "FILTER" {
+		switch strings.ToUpper(args[0]) {
+		case "WITHLABELS":
+			withLabels = true
+			args = args[1:]
+		case "SELECTED_LABELS":
+			args = args[1:]
+			for len(args) > 0 && strings.ToUpper(args[0]) != "FILTER" {
+				selected = append(selected, args[0])
+				args = args[1:]
+			}
+		default:
+			return errSyntax
+		}
+	}
+	if len(args) == 0 {
+		return errMissingFilter
+	}
+	m, err := parseMatchers(args[1:])
+	if err != nil {
+		return err
+	}
+	o := rangeOptions{withLabels: withLabels, selected: selected}
+	res := mapReply{kind: mapPrefixed}
+	for _, key := range d.match(m) {
+		s := d.series[key]
+		last := []interface{}{}
+		if p, ok := s.last(); ok {
+			last = p.reply()
+		}
+		res.kv = append(res.kv, key, []interface{}{o.labels(s), last})
+	}
+	return res
+}
+
+// matcher is a parsed filter expression of TS.MRANGE, TS.MGET and
+// TS.QUERYINDEX.
+type matcher struct {
+	label  string
+	equal  bool
+	values []string
+}
+
+func (m matcher) match(labels map[string]string) bool {
+	v, ok := labels[m.label]
+	if len(m.values) == 0 {
+		// label= matches when the label is missing, label!= when it exists
+		return ok != m.equal
+	}
+	for _, want := range m.values {
+		if ok && v == want {
+			return m.equal
+		}
+	}
+	return !m.equal
+}
+
+type matchers []matcher
+
+func (ms matchers) match(labels map[string]string) bool {
+	for _, m := range ms {
+		if !m.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseMatchers(args []string) (matchers, error) {
+	if len(args) == 0 {
+		return nil, errWrongArgs
+	}
+	ms := make(matchers, len(args))
+	var positive bool
+	for i, arg := range args {
+		m, err := parseMatcher(arg)
+		if err != nil {
+			return nil, err
+		}
+		if m.equal && len(m.values) > 0 {
+			positive = true
+		}
+		ms[i] = m
+	}
+	if !positive {
+		return nil, errNoMatcher
+	}
+	return ms, nil
+}
+
+func parseMatcher(arg string) (matcher, error) {
+	i := strings.Index(arg, "=")
+	if i <= 0 {
+		return matcher{}, errFilterParse
+	}
+	m := matcher{label: arg[:i], equal: true}
+	if strings.HasSuffix(m.label, "!") {
+		m.label, m.equal = m.label[:len(m.label)-1], false
+	}
+	if m.label == "" {
+		return matcher{}, errFilterParse
+	}
+	v := arg[i+1:]
+	switch {
+	case v == "":
+	case strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")"):
+		m.values = strings.Split(v[1:len(v)-1], ",")
+	default:
+		m.values = []string{v}
+	}
+	return m, nil
+}
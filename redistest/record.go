@@ -0,0 +1,468 @@
+package redistest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/coding-socks/redists"
+)
+
+// Interaction is a command sent to a Doer and the reply it returned.
+type Interaction struct {
+	Cmd   string
+	Args  []interface{}
+	Reply interface{}
+	Err   error
+}
+
+// Recorder is a redists.Doer which passes commands to another Doer and
+// records the interactions, so they can be saved as a golden file and
+// replayed by Replayer.
+type Recorder struct {
+	d  redists.Doer
+	mu sync.Mutex
+	is []Interaction
+}
+
+var _ redists.Doer = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder which sends the commands to d.
+func NewRecorder(d redists.Doer) *Recorder {
+	return &Recorder{d: d}
+}
+
+func (r *Recorder) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	res, err := r.d.Do(ctx, cmd, args...)
+	r.mu.Lock()
+	r.is = append(r.is, Interaction{Cmd: cmd, Args: args, Reply: res, Err: err})
+	r.mu.Unlock()
+	return res, err
+}
+
+// Interactions returns the recorded interactions.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.is...)
+}
+
+// Save writes the recorded interactions to filename.
+func (r *Recorder) Save(filename string) error {
+	var buf bytes.Buffer
+	if err := WriteInteractions(&buf, r.Interactions()); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// Replayer is a redists.Doer which replies with recorded interactions. The
+// commands must be sent in the recorded order with the same arguments. On
+// mismatch, Do returns an error and the test fails. The test fails as well
+// when some interactions are not replayed by the end of the test.
+type Replayer struct {
+	t    testing.TB
+	mu   sync.Mutex
+	is   []Interaction
+	next int
+}
+
+var _ redists.Doer = (*Replayer)(nil)
+
+// NewReplayer returns a Replayer which replays is.
+func NewReplayer(t testing.TB, is []Interaction) *Replayer {
+	r := &Replayer{t: t, is: is}
+	t.Cleanup(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if n := len(r.is) - r.next; n > 0 {
+			t.Errorf("redistest: %d recorded commands were not replayed, next: %s", n, formatCmd(r.is[r.next].Cmd, r.is[r.next].Args))
+		}
+	})
+	return r
+}
+
+// LoadReplayer returns a Replayer which replays the interactions saved to
+// filename by Recorder.Save.
+func LoadReplayer(t testing.TB, filename string) *Replayer {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("redistest: %v", err)
+	}
+	defer f.Close()
+	is, err := ReadInteractions(f)
+	if err != nil {
+		t.Fatalf("redistest: %s: %v", filename, err)
+	}
+	return NewReplayer(t, is)
+}
+
+func (r *Replayer) Do(_ context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	got := formatCmd(cmd, args)
+	if r.next >= len(r.is) {
+		err := fmt.Errorf("redistest: unexpected command %d: %s", r.next+1, got)
+		r.t.Error(err)
+		return nil, err
+	}
+	in := r.is[r.next]
+	if want := formatCmd(in.Cmd, in.Args); got != want {
+		err := fmt.Errorf("redistest: command %d mismatch:\n\tgot:  %s\n\twant: %s", r.next+1, got, want)
+		r.t.Error(err)
+		return nil, err
+	}
+	r.next++
+	return in.Reply, in.Err
+}
+
+// formatCmd formats a command with the argument types, so int(1) and
+// int64(1) are different.
+func formatCmd(cmd string, args []interface{}) string {
+	v, err := encodeArgs(args)
+	if err != nil {
+		return fmt.Sprintf("%s %v", cmd, args)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%s %v", cmd, args)
+	}
+	return cmd + " " + string(b)
+}
+
+type interactionJSON struct {
+	Cmd   string        `json:"cmd"`
+	Args  []interface{} `json:"args"`
+	Reply interface{}   `json:"reply"`
+	Err   *string       `json:"err,omitempty"`
+}
+
+// encodeArgs encodes args as a JSON array. The arguments of a variadic call
+// are nil or empty depending on the caller, so they are not kept apart.
+func encodeArgs(args []interface{}) ([]interface{}, error) {
+	vs := make([]interface{}, len(args))
+	for i := range args {
+		var err error
+		if vs[i], err = encodeValue(args[i]); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+// WriteInteractions writes is to w as JSON lines. Every value is tagged with
+// its Go type, so nil, []uint8(nil) and empty values are kept apart.
+func WriteInteractions(w io.Writer, is []Interaction) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for i, in := range is {
+		args, err := encodeArgs(in.Args)
+		if err != nil {
+			return fmt.Errorf("redistest: interaction %d: args: %w", i+1, err)
+		}
+		res, err := encodeValue(in.Reply)
+		if err != nil {
+			return fmt.Errorf("redistest: interaction %d: reply: %w", i+1, err)
+		}
+		ij := interactionJSON{Cmd: in.Cmd, Args: args, Reply: res}
+		if in.Err != nil {
+			msg := in.Err.Error()
+			ij.Err = &msg
+		}
+		if err := enc.Encode(ij); err != nil {
+			return fmt.Errorf("redistest: interaction %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// ReadInteractions reads interactions written by WriteInteractions. Errors
+// are read as Error, except context.Canceled and context.DeadlineExceeded.
+func ReadInteractions(r io.Reader) ([]Interaction, error) {
+	var is []Interaction
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 64<<20)
+	for n := 1; s.Scan(); n++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(s.Bytes()))
+		dec.UseNumber()
+		var ij interactionJSON
+		if err := dec.Decode(&ij); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		in := Interaction{Cmd: ij.Cmd, Args: make([]interface{}, len(ij.Args))}
+		var err error
+		for i := range ij.Args {
+			if in.Args[i], err = decodeValue(ij.Args[i]); err != nil {
+				return nil, fmt.Errorf("line %d: args: %w", n, err)
+			}
+		}
+		if in.Reply, err = decodeValue(ij.Reply); err != nil {
+			return nil, fmt.Errorf("line %d: reply: %w", n, err)
+		}
+		if ij.Err != nil {
+			in.Err = decodeError(*ij.Err)
+		}
+		is = append(is, in)
+	}
+	return is, s.Err()
+}
+
+func decodeError(msg string) error {
+	switch msg {
+	case context.Canceled.Error():
+		return context.Canceled
+	case context.DeadlineExceeded.Error():
+		return context.DeadlineExceeded
+	default:
+		return Error(msg)
+	}
+}
+
+// encodeValue returns a JSON value which keeps the Go type of val. Strings
+// are encoded as JSON strings, every other type as an object with a single
+// key naming the type.
+func encodeValue(val interface{}) (interface{}, error) {
+	tagged := func(tag string, v interface{}) (interface{}, error) {
+		return map[string]interface{}{tag: v}, nil
+	}
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if !utf8.ValidString(v) {
+			return tagged("string64", base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+		return v, nil
+	case []byte:
+		switch {
+		case v == nil:
+			return tagged("bytes", nil)
+		case !utf8.Valid(v):
+			return tagged("bytes64", base64.StdEncoding.EncodeToString(v))
+		default:
+			return tagged("bytes", string(v))
+		}
+	case int64:
+		return tagged("int64", v)
+	case int:
+		return tagged("int", v)
+	case float64:
+		return tagged("float64", strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		return tagged("bool", v)
+	case error:
+		return tagged("error", v.Error())
+	case []interface{}:
+		if v == nil {
+			return tagged("array", nil)
+		}
+		vs := make([]interface{}, len(v))
+		for i := range v {
+			var err error
+			if vs[i], err = encodeValue(v[i]); err != nil {
+				return nil, err
+			}
+		}
+		return tagged("array", vs)
+	case map[string]interface{}:
+		kvs := make([]interface{}, 0, len(v))
+		for k, e := range v {
+			ek, _ := encodeValue(k)
+			ev, err := encodeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			kvs = append(kvs, []interface{}{ek, ev})
+		}
+		return tagged("map", sortEntries(kvs))
+	case map[interface{}]interface{}:
+		kvs := make([]interface{}, 0, len(v))
+		for k, e := range v {
+			ek, err := encodeValue(k)
+			if err != nil {
+				return nil, err
+			}
+			ev, err := encodeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			kvs = append(kvs, []interface{}{ek, ev})
+		}
+		return tagged("imap", sortEntries(kvs))
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// sortEntries sorts map entries by their encoded key, so the output is
+// deterministic.
+func sortEntries(kvs []interface{}) []interface{} {
+	keys := make([]string, len(kvs))
+	for i := range kvs {
+		b, _ := json.Marshal(kvs[i].([]interface{})[0])
+		keys[i] = string(b)
+	}
+	sort.Sort(entrySorter{keys: keys, kvs: kvs})
+	return kvs
+}
+
+type entrySorter struct {
+	keys []string
+	kvs  []interface{}
+}
+
+func (s entrySorter) Len() int           { return len(s.keys) }
+func (s entrySorter) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s entrySorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.kvs[i], s.kvs[j] = s.kvs[j], s.kvs[i]
+}
+
+var errUnexpectedJSON = errors.New("unexpected JSON value")
+
+// decodeValue reverses encodeValue. The numbers must be decoded as
+// json.Number.
+func decodeValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return nil, errUnexpectedJSON
+		}
+		for tag, e := range v {
+			return decodeTagged(tag, e)
+		}
+	}
+	return nil, errUnexpectedJSON
+}
+
+func decodeTagged(tag string, val interface{}) (interface{}, error) {
+	switch tag {
+	case "string64", "bytes64":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if tag == "string64" {
+			return string(b), nil
+		}
+		return b, nil
+	case "bytes":
+		if val == nil {
+			return []byte(nil), nil
+		}
+		s, ok := val.(string)
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		return []byte(s), nil
+	case "int64", "int":
+		n, ok := val.(json.Number)
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if tag == "int" {
+			return int(i), nil
+		}
+		return i, nil
+	case "float64":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		b, ok := val.(bool)
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		return b, nil
+	case "error":
+		s, ok := val.(string)
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		return decodeError(s), nil
+	case "array":
+		if val == nil {
+			return []interface{}(nil), nil
+		}
+		vs, ok := val.([]interface{})
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		res := make([]interface{}, len(vs))
+		for i := range vs {
+			var err error
+			if res[i], err = decodeValue(vs[i]); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case "map", "imap":
+		kvs, ok := val.([]interface{})
+		if !ok {
+			return nil, errUnexpectedJSON
+		}
+		sm := make(map[string]interface{}, len(kvs))
+		im := make(map[interface{}]interface{}, len(kvs))
+		for _, kv := range kvs {
+			pair, ok := kv.([]interface{})
+			if !ok || len(pair) != 2 {
+				return nil, errUnexpectedJSON
+			}
+			k, err := decodeValue(pair[0])
+			if err != nil {
+				return nil, err
+			}
+			e, err := decodeValue(pair[1])
+			if err != nil {
+				return nil, err
+			}
+			if tag == "imap" {
+				if k != nil && !reflect.TypeOf(k).Comparable() {
+					return nil, errUnexpectedJSON
+				}
+				im[k] = e
+				continue
+			}
+			s, ok := k.(string)
+			if !ok {
+				return nil, errUnexpectedJSON
+			}
+			sm[s] = e
+		}
+		if tag == "imap" {
+			return im, nil
+		}
+		return sm, nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", tag)
+}
@@ -0,0 +1,124 @@
+package redistest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coding-socks/redists"
+)
+
+func TestWriteInteractions(t *testing.T) {
+	want := []Interaction{
+		{Cmd: "TS.ADD", Args: []interface{}{"key:any", int64(1000), 0.5, "CHUNK_SIZE", 8}, Reply: int64(1000)},
+		{Cmd: "TS.GET", Args: []interface{}{"key:any"}, Reply: []interface{}{}},
+		{Cmd: "TS.INFO", Args: []interface{}{"key:any"}, Reply: []interface{}{
+			"sourceKey", []uint8(nil),
+			"duplicatePolicy", nil,
+			"labels", []interface{}(nil),
+			"chunkType", []byte("compressed"),
+			"empty", []byte{},
+		}},
+		{Cmd: "TS.MGET", Args: []interface{}{"FILTER", "l=v"}, Reply: map[interface{}]interface{}{
+			"key:any": []interface{}{map[string]interface{}{"l": "v", "\xff": nil}, []interface{}{int64(1000), math.Inf(-1)}},
+			int64(1):  true,
+		}},
+		{Cmd: "TS.MADD", Args: []interface{}{[]byte{0xff}, "*", 1.5}, Reply: []interface{}{Error("ERR TSDB: the key does not exist")}},
+		{Cmd: "TS.DEL", Args: []interface{}{"key:any", "-", "+"}, Err: Error("ERR TSDB: the key does not exist")},
+		{Cmd: "PING", Args: []interface{}{}, Err: context.DeadlineExceeded},
+	}
+	var buf bytes.Buffer
+	if err := WriteInteractions(&buf, want); err != nil {
+		t.Fatalf("WriteInteractions() error = %v", err)
+	}
+	got, err := ReadInteractions(&buf)
+	if err != nil {
+		t.Fatalf("ReadInteractions() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadInteractions() got = %#v, want %#v", got, want)
+	}
+	if err := WriteInteractions(&buf, []Interaction{{Cmd: "PING", Reply: struct{}{}}}); err == nil {
+		t.Errorf("WriteInteractions() error = %v, wantErr %v", err, true)
+	}
+}
+
+// cleanupTB records the errors and the cleanup functions of a test.
+type cleanupTB struct {
+	testing.TB
+	errs     []string
+	cleanups []func()
+}
+
+func (t *cleanupTB) Error(args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprint(args...))
+}
+
+func (t *cleanupTB) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func (t *cleanupTB) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *cleanupTB) cleanup() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	ctx := context.Background()
+	session := func(d redists.Doer) ([]redists.DataPoint, error) {
+		c := redists.NewClient(d)
+		if _, err := c.Add(ctx, redists.NewSample("key:any", ms(0), 0.5)); err != nil {
+			return nil, err
+		}
+		if _, err := c.Get(ctx, "key:missing"); !errors.Is(err, redists.ErrKeyNotExist) {
+			return nil, fmt.Errorf("Get() error = %v, want %v", err, redists.ErrKeyNotExist)
+		}
+		return c.Range(ctx, "key:any", redists.TSMin(), redists.TSMax())
+	}
+
+	r := NewRecorder(NewDoer())
+	want, err := session(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := r.Save(filename); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	t.Run("match", func(t *testing.T) {
+		got, err := session(LoadReplayer(t, filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Range() got = %v, want %v", got, want)
+		}
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		tb := &cleanupTB{TB: t}
+		d := NewReplayer(tb, r.Interactions())
+		_, err := d.Do(ctx, "TS.ADD", "key:any", ms(0).UnixMilli(), 1.5)
+		if err == nil || !strings.Contains(err.Error(), "command 1 mismatch") {
+			t.Errorf("Do() error = %v, want command 1 mismatch", err)
+		}
+		tb.cleanup()
+		if got, want := len(tb.errs), 2; got != want {
+			t.Fatalf("errors = %v, want %v errors", tb.errs, want)
+		}
+		if !strings.Contains(tb.errs[1], "3 recorded commands were not replayed") {
+			t.Errorf("errors[1] = %v, want not replayed", tb.errs[1])
+		}
+	})
+}
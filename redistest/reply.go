@@ -0,0 +1,76 @@
+package redistest
+
+// Handlers return a reply tree which contains the following types:
+//
+//   - nil
+//   - int64
+//   - float64, a double
+//   - string, a bulk string
+//   - status, a simple string
+//   - Error
+//   - []interface{}
+//   - mapReply
+//
+// The tree keeps the information the RESP3 encoder needs, and it is converted
+// to the RESP2 shape by Doer.
+
+// status is a simple string reply, e.g. "OK".
+type status string
+
+type mapKind int
+
+const (
+	// mapFlat maps are [k1, v1, k2, v2] in RESP2.
+	mapFlat mapKind = iota
+	// mapPairs maps are [[k1, v1], [k2, v2]] in RESP2.
+	mapPairs
+	// mapPrefixed maps are [[k1, v1...], [k2, v2...]] in RESP2, where the
+	// values are arrays.
+	mapPrefixed
+)
+
+// pushReply is an out of band push in RESP3, e.g. an invalidation message.
+type pushReply []interface{}
+
+// mapReply is a map in RESP3. Its RESP2 shape depends on kind.
+type mapReply struct {
+	kind mapKind
+	kv   []interface{}
+}
+
+// resp2 returns the values of m in RESP2 shape without converting them.
+func (m mapReply) resp2() []interface{} {
+	if m.kind == mapFlat {
+		return m.kv
+	}
+	res := make([]interface{}, 0, len(m.kv)/2)
+	for i := 0; i < len(m.kv); i += 2 {
+		if m.kind == mapPairs {
+			res = append(res, []interface{}{m.kv[i], m.kv[i+1]})
+			continue
+		}
+		vs := m.kv[i+1].([]interface{})
+		res = append(res, append([]interface{}{m.kv[i]}, vs...))
+	}
+	return res
+}
+
+// resp2Value converts a reply tree to the values a RESP2 client returns.
+func resp2Value(val interface{}) interface{} {
+	switch v := val.(type) {
+	case status:
+		return string(v)
+	case float64:
+		return formatValue(v)
+	case mapReply:
+		return resp2Value(v.resp2())
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i := range v {
+			res[i] = resp2Value(v[i])
+		}
+		return res
+	default:
+		return v
+	}
+}
@@ -0,0 +1,138 @@
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// errProtocol is returned by readCommand when the request is not valid RESP.
+var errProtocol = errors.New("redistest: protocol error")
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readCommand reads a command sent as an array of bulk strings or as an
+// inline command. It returns an empty slice for empty inline commands.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return nil, errProtocol
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errProtocol
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, errProtocol
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// respWriter encodes reply trees in RESP2 or RESP3.
+type respWriter struct {
+	w     *bufio.Writer
+	proto int
+}
+
+func (w *respWriter) header(prefix byte, n int) {
+	w.w.WriteByte(prefix)
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
+
+func (w *respWriter) line(prefix byte, s string) {
+	w.w.WriteByte(prefix)
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+func (w *respWriter) write(val interface{}) {
+	switch v := val.(type) {
+	case nil:
+		if w.proto == 3 {
+			w.w.WriteString("_\r\n")
+			return
+		}
+		w.w.WriteString("$-1\r\n")
+	case int64:
+		w.line(':', strconv.FormatInt(v, 10))
+	case float64:
+		if w.proto == 3 {
+			w.line(',', formatDouble(v))
+			return
+		}
+		w.write(formatValue(v))
+	case string:
+		w.header('$', len(v))
+		w.w.WriteString(v)
+		w.w.WriteString("\r\n")
+	case status:
+		w.line('+', string(v))
+	case Error:
+		w.line('-', string(v))
+	case []interface{}:
+		w.header('*', len(v))
+		for i := range v {
+			w.write(v[i])
+		}
+	case pushReply:
+		w.header('>', len(v))
+		for i := range v {
+			w.write(v[i])
+		}
+	case mapReply:
+		if w.proto != 3 {
+			w.write(v.resp2())
+			return
+		}
+		w.header('%', len(v.kv)/2)
+		for i := range v.kv {
+			w.write(v.kv[i])
+		}
+	default:
+		panic("redistest: unexpected reply type")
+	}
+}
+
+// formatDouble formats v as a RESP3 double.
+func formatDouble(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	default:
+		return formatValue(v)
+	}
+}
@@ -0,0 +1,273 @@
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/coding-socks/redists"
+)
+
+const (
+	defaultChunkSize = 4096
+	// bytesPerSample is used to split samples into chunks.
+	bytesPerSample = 16
+)
+
+type sample struct {
+	ts int64
+	v  float64
+}
+
+func (s sample) reply() []interface{} {
+	return []interface{}{s.ts, s.v}
+}
+
+type rule struct {
+	dest   string
+	agg    string
+	bucket int64
+	align  int64
+}
+
+// bucketStart returns the start of the bucket ts belongs to.
+func bucketStart(ts, bucket, align int64) int64 {
+	mod := (ts - align) % bucket
+	if mod < 0 {
+		mod += bucket
+	}
+	return ts - mod
+}
+
+type series struct {
+	key             string
+	retention       int64
+	chunkSize       int64
+	uncompressed    bool
+	duplicatePolicy string
+	labels          map[string]string
+	samples         []sample
+	rules           []rule
+	source          string
+}
+
+func newSeries(key string) *series {
+	return &series{key: key, chunkSize: defaultChunkSize, labels: map[string]string{}}
+}
+
+func (s *series) last() (sample, bool) {
+	if len(s.samples) == 0 {
+		return sample{}, false
+	}
+	return s.samples[len(s.samples)-1], true
+}
+
+// search returns the index of the first sample at or after ts.
+func (s *series) search(ts int64) int {
+	return sort.Search(len(s.samples), func(i int) bool {
+		return s.samples[i].ts >= ts
+	})
+}
+
+// window returns the samples between from and to inclusive.
+func (s *series) window(from, to int64) []sample {
+	if from > to {
+		return nil
+	}
+	i := s.search(from)
+	j := i + sort.Search(len(s.samples)-i, func(k int) bool {
+		return s.samples[i+k].ts > to
+	})
+	return s.samples[i:j]
+}
+
+// upsert adds a sample to s. The policy overrides the duplicate policy of s
+// when it is not empty.
+func (s *series) upsert(ts int64, v float64, policy string) error {
+	if last, ok := s.last(); ok && s.retention > 0 && ts < last.ts-s.retention {
+		return errTooOld
+	}
+	i := s.search(ts)
+	if i < len(s.samples) && s.samples[i].ts == ts {
+		if policy == "" {
+			policy = s.duplicatePolicy
+		}
+		old := &s.samples[i]
+		switch redists.DuplicatePolicy(policy) {
+		case redists.DuplicatePolicyFirst:
+		case redists.DuplicatePolicyLast:
+			old.v = v
+		case redists.DuplicatePolicyMin:
+			old.v = math.Min(old.v, v)
+		case redists.DuplicatePolicyMax:
+			old.v = math.Max(old.v, v)
+		case redists.DuplicatePolicySum:
+			old.v += v
+		default:
+			return errBlocked
+		}
+		return nil
+	}
+	s.samples = append(s.samples, sample{})
+	copy(s.samples[i+1:], s.samples[i:])
+	s.samples[i] = sample{ts: ts, v: v}
+	s.trim()
+	return nil
+}
+
+// trim removes the samples which are older than the retention.
+func (s *series) trim() {
+	last, ok := s.last()
+	if !ok || s.retention == 0 {
+		return
+	}
+	if i := s.search(last.ts - s.retention); i > 0 {
+		s.samples = append(s.samples[:0], s.samples[i:]...)
+	}
+}
+
+func (s *series) delete(from, to int64) int64 {
+	i := s.search(from)
+	n := len(s.window(from, to))
+	s.samples = append(s.samples[:i], s.samples[i+n:]...)
+	return int64(n)
+}
+
+// upsert adds a sample to s and updates the destinations of its compaction
+// rules.
+func (d *Doer) upsert(s *series, ts int64, v float64, policy string) error {
+	prev, hasPrev := s.last()
+	if err := s.upsert(ts, v, policy); err != nil {
+		return err
+	}
+	from := ts
+	if hasPrev && prev.ts < from {
+		from = prev.ts
+	}
+	d.compact(s, from)
+	return nil
+}
+
+// compact writes the aggregates of the closed buckets of s starting at the
+// bucket of from to the destinations of its rules. The bucket of the latest
+// sample is still open, so it is written once a later sample arrives.
+func (d *Doer) compact(s *series, from int64) {
+	last, ok := s.last()
+	if !ok {
+		return
+	}
+	for _, r := range s.rules {
+		dest, ok := d.series[r.dest]
+		if !ok {
+			continue
+		}
+		open := bucketStart(last.ts, r.bucket, r.align)
+		start := bucketStart(from, r.bucket, r.align)
+		for _, b := range aggregate(s.window(start, open-1), r.agg, r.bucket, r.align) {
+			_ = d.upsert(dest, b.ts, b.v, string(redists.DuplicatePolicyLast))
+		}
+	}
+}
+
+func (s *series) info(debug bool) mapReply {
+	var first, last int64
+	if len(s.samples) > 0 {
+		first, last = s.samples[0].ts, s.samples[len(s.samples)-1].ts
+	}
+	chunks := s.chunks()
+	chunkType := "compressed"
+	if s.uncompressed {
+		chunkType = "uncompressed"
+	}
+	var policy, source interface{}
+	if s.duplicatePolicy != "" {
+		policy = strings.ToLower(s.duplicatePolicy)
+	}
+	if s.source != "" {
+		source = s.source
+	}
+	rules := mapReply{kind: mapPrefixed}
+	for _, r := range s.rules {
+		rules.kv = append(rules.kv, r.dest, []interface{}{r.bucket, r.agg})
+	}
+	res := []interface{}{
+		"totalSamples", int64(len(s.samples)),
+		"memoryUsage", int64(len(chunks))*s.chunkSize + 128,
+		"firstTimestamp", first,
+		"lastTimestamp", last,
+		"retentionTime", s.retention,
+		"chunkCount", int64(len(chunks)),
+		"chunkSize", s.chunkSize,
+		"chunkType", chunkType,
+		"duplicatePolicy", policy,
+		"labels", labelsReply(s.labels, nil),
+		"sourceKey", source,
+		"rules", rules,
+	}
+	if !debug {
+		return mapReply{kv: res}
+	}
+	cs := make([]interface{}, len(chunks))
+	for i, c := range chunks {
+		var start, end int64
+		var bps float64
+		if len(c) > 0 {
+			start, end = c[0].ts, c[len(c)-1].ts
+			bps = float64(s.chunkSize) / float64(len(c))
+		}
+		cs[i] = mapReply{kv: []interface{}{
+			"startTimestamp", start,
+			"endTimestamp", end,
+			"samples", int64(len(c)),
+			"size", s.chunkSize,
+			"bytesPerSample", bps,
+		}}
+	}
+	return mapReply{kv: append(res, "keySelfName", s.key, "Chunks", cs)}
+}
+
+// chunks splits the samples of s the way the server would store them. There
+// is always at least one chunk.
+func (s *series) chunks() [][]sample {
+	n := int(s.chunkSize / bytesPerSample)
+	if n < 1 {
+		n = 1
+	}
+	var cs [][]sample
+	for i := 0; i < len(s.samples); i += n {
+		j := i + n
+		if j > len(s.samples) {
+			j = len(s.samples)
+		}
+		cs = append(cs, s.samples[i:j])
+	}
+	if len(cs) == 0 {
+		cs = append(cs, nil)
+	}
+	return cs
+}
+
+// labelsReply returns the labels sorted by name. When selected is not nil,
+// only the selected labels are returned, with nil for the missing ones.
+func labelsReply(labels map[string]string, selected []string) mapReply {
+	res := mapReply{kind: mapPairs}
+	if selected != nil {
+		for _, name := range selected {
+			var v interface{}
+			if lv, ok := labels[name]; ok {
+				v = lv
+			}
+			res.kv = append(res.kv, name, v)
+		}
+		return res
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		res.kv = append(res.kv, name, labels[name])
+	}
+	return res
+}
@@ -0,0 +1,275 @@
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server is a Redis server stand-in which serves a Doer over RESP. It speaks
+// RESP2 and switches to RESP3 after HELLO 3, so it can be used with real
+// Redis clients. Besides the TS.* commands it supports PING, EXISTS, DEL,
+// TYPE, PTTL, MULTI, EXEC, DISCARD, HELLO, SELECT, AUTH, CLIENT and QUIT.
+//
+// CLIENT TRACKING is supported in RESP3 without keeping track of the read
+// keys: every write command sent over a connection, e.g. TS.ADD, invalidates
+// its keys and the destination keys of their compaction rules on every
+// connection with tracking on. Writes through Server.Doer bypass tracking.
+type Server struct {
+	d *Doer
+	l net.Listener
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	conns  map[net.Conn]*serverConn
+	nextID int64
+	closed bool
+}
+
+// NewServer starts a Server on a loopback port. The Server is closed when
+// the test and all its subtests complete.
+func NewServer(t testing.TB, options ...OptionDoer) *Server {
+	t.Helper()
+	s, err := StartServer("127.0.0.1:0", options...)
+	if err != nil {
+		t.Fatalf("redistest: cannot start server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// StartServer starts a Server listening on the TCP address addr. It is
+// useful in TestMain where there is no testing.TB.
+func StartServer(addr string, options ...OptionDoer) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{d: NewDoer(options...), l: l, conns: map[net.Conn]*serverConn{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the Server listens on, e.g. "127.0.0.1:49152".
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Doer returns the Doer which holds the data of the Server.
+func (s *Server) Doer() *Doer {
+	return s.d
+}
+
+// Close closes the listener and all connections, and it waits until the
+// connections are served.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.l.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		c := &serverConn{w: &respWriter{w: bufio.NewWriter(conn), proto: 2}}
+		s.conns[conn] = c
+		s.nextID++
+		id := s.nextID
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn, c, id)
+	}
+}
+
+// serverConn is a connection of a Server. Replies and invalidation pushes
+// are written under mu.
+type serverConn struct {
+	mu       sync.Mutex
+	w        *respWriter
+	tracking bool
+}
+
+func (s *Server) serveConn(conn net.Conn, c *serverConn, id int64) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	var tx transaction
+	for {
+		args, err := readCommand(r)
+		if errors.Is(err, errProtocol) {
+			c.mu.Lock()
+			c.w.write(Error("ERR Protocol error"))
+			c.w.w.Flush()
+			c.mu.Unlock()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := strings.ToUpper(args[0]) == "QUIT"
+		res := tx.exec(s, c, id, args)
+		c.mu.Lock()
+		c.w.write(res)
+		// flush once the pipelined commands are answered
+		if r.Buffered() == 0 || quit {
+			err = c.w.w.Flush()
+		}
+		c.mu.Unlock()
+		if err != nil || quit {
+			return
+		}
+	}
+}
+
+// transaction queues the commands between MULTI and EXEC.
+type transaction struct {
+	multi  bool
+	queued [][]string
+}
+
+// exec executes MULTI, EXEC and DISCARD, and it queues the other commands in
+// a transaction or executes them with Server.exec.
+func (tx *transaction) exec(s *Server, c *serverConn, id int64, args []string) interface{} {
+	switch strings.ToUpper(args[0]) {
+	case "MULTI":
+		if tx.multi {
+			return Error("ERR MULTI calls can not be nested")
+		}
+		tx.multi = true
+		return status("OK")
+	case "EXEC":
+		if !tx.multi {
+			return Error("ERR EXEC without MULTI")
+		}
+		res := make([]interface{}, len(tx.queued))
+		for i, args := range tx.queued {
+			res[i] = s.exec(c, id, args)
+		}
+		*tx = transaction{}
+		return res
+	case "DISCARD":
+		if !tx.multi {
+			return Error("ERR DISCARD without MULTI")
+		}
+		*tx = transaction{}
+		return status("OK")
+	}
+	if tx.multi {
+		tx.queued = append(tx.queued, args)
+		return status("QUEUED")
+	}
+	return s.exec(c, id, args)
+}
+
+// exec executes the connection commands, and it passes the other commands
+// to the Doer.
+func (s *Server) exec(c *serverConn, id int64, args []string) interface{} {
+	switch strings.ToUpper(args[0]) {
+	case "HELLO":
+		return hello(c, id, args[1:])
+	case "SELECT":
+		if len(args) != 2 {
+			return errWrongArgs
+		}
+		if _, err := strconv.Atoi(args[1]); err != nil {
+			return Error("ERR value is not an integer or out of range")
+		}
+		return status("OK")
+	case "CLIENT":
+		if len(args) > 2 && strings.EqualFold(args[1], "TRACKING") {
+			c.mu.Lock()
+			c.tracking = strings.EqualFold(args[2], "ON")
+			c.mu.Unlock()
+		}
+		return status("OK")
+	case "AUTH", "QUIT":
+		return status("OK")
+	}
+	res := s.d.exec(args[0], args[1:])
+	if _, failed := res.(Error); !failed {
+		if keys := s.d.writtenKeys(args[0], args[1:]); len(keys) > 0 {
+			s.invalidate(keys)
+		}
+	}
+	return res
+}
+
+// invalidate sends an invalidation push of keys to the RESP3 connections
+// with tracking on.
+func (s *Server) invalidate(keys []string) {
+	ks := make([]interface{}, len(keys))
+	for i := range keys {
+		ks[i] = keys[i]
+	}
+	push := pushReply{"invalidate", ks}
+	s.mu.Lock()
+	cs := make([]*serverConn, 0, len(s.conns))
+	for _, c := range s.conns {
+		cs = append(cs, c)
+	}
+	s.mu.Unlock()
+	for _, c := range cs {
+		c.mu.Lock()
+		if c.tracking && c.w.proto == 3 {
+			c.w.write(push)
+			c.w.w.Flush()
+		}
+		c.mu.Unlock()
+	}
+}
+
+func hello(c *serverConn, id int64, args []string) interface{} {
+	w := c.w
+	if len(args) > 0 {
+		proto, err := strconv.Atoi(args[0])
+		if err != nil || proto < 2 || proto > 3 {
+			return Error("NOPROTO unsupported protocol version")
+		}
+		c.mu.Lock()
+		w.proto = proto
+		c.mu.Unlock()
+	}
+	return mapReply{kv: []interface{}{
+		"server", "redis",
+		"version", "7.0.0",
+		"proto", int64(w.proto),
+		"id", id,
+		"mode", "standalone",
+		"role", "master",
+		"modules", []interface{}{
+			mapReply{kv: []interface{}{"name", "timeseries", "ver", int64(10800)}},
+		},
+	}}
+}
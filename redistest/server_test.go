@@ -0,0 +1,171 @@
+package redistest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestServer_resp2(t *testing.T) {
+	s := NewServer(t)
+	conn, err := redigo.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if got, err := redigo.String(conn.Do("PING")); err != nil || got != "PONG" {
+		t.Errorf("PING got = %v, %v, want PONG", got, err)
+	}
+	if _, err := conn.Do("TS.ADD", "key:any", 1000, 0.5, "LABELS", "l", "v"); err != nil {
+		t.Fatalf("TS.ADD error = %v", err)
+	}
+	if got, err := redigo.String(conn.Do("TYPE", "key:any")); err != nil || got != "TSDB-TYPE" {
+		t.Errorf("TYPE got = %v, %v, want TSDB-TYPE", got, err)
+	}
+	res, err := conn.Do("TS.MGET", "WITHLABELS", "FILTER", "l=v")
+	if err != nil {
+		t.Fatalf("TS.MGET error = %v", err)
+	}
+	want := []interface{}{
+		[]interface{}{
+			[]byte("key:any"),
+			[]interface{}{[]interface{}{[]byte("l"), []byte("v")}},
+			[]interface{}{int64(1000), []byte("0.5")},
+		},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("TS.MGET got = %v, want %v", res, want)
+	}
+	if _, err := conn.Do("TS.GET", "key:missing"); err == nil || !strings.Contains(err.Error(), "the key does not exist") {
+		t.Errorf("TS.GET error = %v, want key does not exist", err)
+	}
+	if got, err := redigo.Int(conn.Do("DEL", "key:any", "key:missing")); err != nil || got != 1 {
+		t.Errorf("DEL got = %v, %v, want 1", got, err)
+	}
+	if got, err := redigo.Int(conn.Do("EXISTS", "key:any")); err != nil || got != 0 {
+		t.Errorf("EXISTS got = %v, %v, want 0", got, err)
+	}
+}
+
+func TestServer_resp3(t *testing.T) {
+	s := NewServer(t)
+	if _, err := s.Doer().Do(context.Background(), "TS.ADD", "key:any", 1000, 0.5, "LABELS", "l", "v"); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// inline commands are pipelined, so the replies arrive together
+	if _, err := io.WriteString(conn, "HELLO 3\r\nTS.MGET WITHLABELS FILTER l=v\r\nQUIT\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "%1\r\n$7\r\nkey:any"
+	got := string(b)
+	if i := strings.Index(got, prefix); i >= 0 {
+		got = got[i+len(prefix):]
+	}
+	want := "\r\n*2\r\n%1\r\n$1\r\nl\r\n$1\r\nv\r\n*2\r\n:1000\r\n,0.5\r\n+OK\r\n"
+	if got != want {
+		t.Errorf("TS.MGET got = %q, want %q", got, want)
+	}
+}
+
+func TestServer_tracking(t *testing.T) {
+	s := NewServer(t)
+	tracking, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracking.Close()
+	tracking.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(tracking)
+	if _, err := io.WriteString(tracking, "HELLO 3\r\nCLIENT TRACKING ON\r\nPING\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	// skip the replies until PONG
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "+PONG\r\n" {
+			break
+		}
+	}
+
+	conn, err := redigo.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, args := range [][]interface{}{
+		{"TS.CREATE", "key:src"},
+		{"TS.CREATE", "key:dest"},
+		{"TS.CREATERULE", "key:src", "key:dest", "AGGREGATION", "avg", 1000},
+		{"TS.ADD", "key:src", 1000, 0.5},
+	} {
+		if _, err := conn.Do(args[0].(string), args[1:]...); err != nil {
+			t.Fatalf("%s error = %v", args[0], err)
+		}
+	}
+	want := ">2\r\n$10\r\ninvalidate\r\n*1\r\n$7\r\nkey:src\r\n" +
+		">2\r\n$10\r\ninvalidate\r\n*1\r\n$8\r\nkey:dest\r\n" +
+		">2\r\n$10\r\ninvalidate\r\n*2\r\n$7\r\nkey:src\r\n$8\r\nkey:dest\r\n" +
+		">2\r\n$10\r\ninvalidate\r\n*2\r\n$7\r\nkey:src\r\n$8\r\nkey:dest\r\n"
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(r, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("pushes got = %q, want %q", got, want)
+	}
+}
+
+func TestServer_transaction(t *testing.T) {
+	s := NewServer(t)
+	conn, err := redigo.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Do("EXEC"); err == nil {
+		t.Errorf("EXEC error = %v, want EXEC without MULTI", err)
+	}
+	conn.Send("MULTI")
+	conn.Send("TS.ADD", "key:any", 1000, 0.5)
+	conn.Send("PTTL", "key:any")
+	conn.Send("PTTL", "key:missing")
+	res, err := conn.Do("EXEC")
+	if err != nil {
+		t.Fatalf("EXEC error = %v", err)
+	}
+	want := []interface{}{int64(1000), int64(-1), int64(-2)}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("EXEC got = %v, want %v", res, want)
+	}
+	conn.Send("MULTI")
+	conn.Send("DEL", "key:any")
+	if _, err := conn.Do("DISCARD"); err != nil {
+		t.Fatalf("DISCARD error = %v", err)
+	}
+	if got, err := redigo.Int(conn.Do("EXISTS", "key:any")); err != nil || got != 1 {
+		t.Errorf("EXISTS got = %v, %v, want 1", got, err)
+	}
+}
@@ -0,0 +1,215 @@
+package redists
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplicaSelection is the way ReplicaRouter chooses a replica.
+type ReplicaSelection int
+
+const (
+	// ReplicaRoundRobin uses the replicas in turn.
+	ReplicaRoundRobin ReplicaSelection = iota
+	// ReplicaLeastLatency uses the replica with the lowest moving average of
+	// the latency, weighted by the number of commands in flight.
+	ReplicaLeastLatency
+)
+
+type replica struct {
+	d        Doer
+	inflight int
+	samples  int
+	avg      float64
+}
+
+// ReplicaRouter is a Doer which sends read commands to replicas and every
+// other command to the primary. The read commands are the ones in
+// CmdClassRead, see ClassifyCmd.
+type ReplicaRouter struct {
+	primary   Doer
+	replicas  []*replica
+	selection ReplicaSelection
+	window    time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	next      int
+	writes    map[string]time.Time
+	lastSweep time.Time
+}
+
+var _ PipelineDoer = (*ReplicaRouter)(nil)
+
+type OptionReplicaRouter func(r *ReplicaRouter)
+
+// NewReplicaRouter returns a ReplicaRouter which sends the commands to the
+// primary or to one of the replicas. Without replicas, every command is sent
+// to the primary.
+func NewReplicaRouter(primary Doer, replicas []Doer, options ...OptionReplicaRouter) *ReplicaRouter {
+	r := &ReplicaRouter{primary: primary, now: time.Now, writes: map[string]time.Time{}}
+	for _, d := range replicas {
+		r.replicas = append(r.replicas, &replica{d: d})
+	}
+	for i := range options {
+		options[i](r)
+	}
+	return r
+}
+
+// ReplicaRouterWithSelection sets the way a replica is chosen. The default is
+// ReplicaRoundRobin.
+func ReplicaRouterWithSelection(s ReplicaSelection) OptionReplicaRouter {
+	return func(r *ReplicaRouter) {
+		r.selection = s
+	}
+}
+
+// ReplicaRouterWithReadYourWrites sends the reads of a key to the primary for
+// the duration of window after the key was written, so readers see their own
+// writes despite the replication lag. Reads which select time-series with
+// filters are not pinned, because their keys are unknown.
+func ReplicaRouterWithReadYourWrites(window time.Duration) OptionReplicaRouter {
+	return func(r *ReplicaRouter) {
+		r.window = window
+	}
+}
+
+// toPrimary reports whether a command is sent to the primary. It records
+// the keys of writes.
+func (r *ReplicaRouter) toPrimary(cmd string, args []interface{}) bool {
+	read := ClassifyCmd(cmd, args, "") == CmdClassRead
+	if len(r.replicas) == 0 {
+		return true
+	}
+	if r.window <= 0 {
+		return !read
+	}
+	keys := cmdKeys(strings.ToUpper(cmd), args)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if !read {
+		for _, key := range keys {
+			r.writes[key] = now
+		}
+		r.sweep(now)
+		return true
+	}
+	for _, key := range keys {
+		if t, ok := r.writes[key]; ok && now.Sub(t) < r.window {
+			return true
+		}
+	}
+	return false
+}
+
+// pick chooses a replica.
+func (r *ReplicaRouter) pick() *replica {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rep *replica
+	switch r.selection {
+	case ReplicaLeastLatency:
+		var best float64
+		for _, c := range r.replicas {
+			// replicas without samples are tried first
+			score := c.avg * float64(c.inflight+1)
+			if c.samples == 0 {
+				score = 0
+			}
+			if rep == nil || score < best {
+				rep, best = c, score
+			}
+		}
+	default:
+		rep = r.replicas[r.next%len(r.replicas)]
+		r.next++
+	}
+	rep.inflight++
+	return rep
+}
+
+// sweep forgets the writes older than the window.
+func (r *ReplicaRouter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.window {
+		return
+	}
+	r.lastSweep = now
+	for key, t := range r.writes {
+		if now.Sub(t) >= r.window {
+			delete(r.writes, key)
+		}
+	}
+}
+
+// done records the latency of a command sent to rep.
+func (r *ReplicaRouter) done(rep *replica, start time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := float64(r.now().Sub(start))
+	rep.inflight--
+	if rep.samples == 0 {
+		rep.avg = d
+	} else {
+		rep.avg += latencyAlpha * (d - rep.avg)
+	}
+	rep.samples++
+}
+
+func (r *ReplicaRouter) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if r.toPrimary(cmd, args) {
+		return r.primary.Do(ctx, cmd, args...)
+	}
+	rep := r.pick()
+	start := r.now()
+	res, err := rep.d.Do(ctx, cmd, args...)
+	r.done(rep, start)
+	return res, err
+}
+
+// DoPipeline sends the pipeline to a replica when every command is a read
+// which is not pinned to the primary, otherwise it sends the pipeline to the
+// primary. Doers which are not PipelineDoers get the commands one by one.
+func (r *ReplicaRouter) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	primary := len(cmds) == 0
+	for _, cmd := range cmds {
+		// every command is checked, so the keys of all writes are recorded
+		if r.toPrimary(cmd.Name, cmd.Args) {
+			primary = true
+		}
+	}
+	if primary {
+		return doPipeline(ctx, r.primary, cmds)
+	}
+	rep := r.pick()
+	start := r.now()
+	res, err := doPipeline(ctx, rep.d, cmds)
+	r.done(rep, start)
+	return res, err
+}
+
+// doPipeline sends the commands with d.DoPipeline when d is a PipelineDoer,
+// otherwise it sends them with doEach.
+func doPipeline(ctx context.Context, d Doer, cmds []PipelineCmd) ([]interface{}, error) {
+	if pd, ok := d.(PipelineDoer); ok {
+		return pd.DoPipeline(ctx, cmds)
+	}
+	return doEach(ctx, d, cmds)
+}
+
+// doEach sends the commands one by one with d.Do, and it puts the errors in
+// the replies like DoPipeline.
+func doEach(ctx context.Context, d Doer, cmds []PipelineCmd) ([]interface{}, error) {
+	res := make([]interface{}, len(cmds))
+	for i, cmd := range cmds {
+		val, err := d.Do(ctx, cmd.Name, cmd.Args...)
+		if err != nil {
+			val = err
+		}
+		res[i] = val
+	}
+	return res, nil
+}
@@ -0,0 +1,99 @@
+package redists
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// nameDoer replies with its name and advances a fake clock by its latency.
+type nameDoer struct {
+	name    string
+	now     *time.Time
+	latency time.Duration
+}
+
+func (d *nameDoer) Do(_ context.Context, _ string, _ ...interface{}) (interface{}, error) {
+	*d.now = d.now.Add(d.latency)
+	return d.name, nil
+}
+
+func TestReplicaRouter(t *testing.T) {
+	ctx := context.Background()
+	now := time.UnixMilli(0)
+	newRouter := func(latencies []time.Duration, options ...OptionReplicaRouter) *ReplicaRouter {
+		var replicas []Doer
+		for i, l := range latencies {
+			replicas = append(replicas, &nameDoer{name: fmt.Sprint("replica", i), now: &now, latency: l})
+		}
+		r := NewReplicaRouter(&nameDoer{name: "primary", now: &now}, replicas, options...)
+		r.now = func() time.Time { return now }
+		return r
+	}
+	do := func(t *testing.T, r *ReplicaRouter, cmd Cmd) interface{} {
+		t.Helper()
+		res, err := r.Do(ctx, cmd.Name(), cmd.Args()...)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		return res
+	}
+	rangeCmd := NewCmdRange("key:any", TSMin(), TSMax())
+	addCmd := NewCmdAdd(NewSample("key:any", TSAuto(), 1))
+
+	t.Run("round robin", func(t *testing.T) {
+		r := newRouter([]time.Duration{0, 0})
+		var got []interface{}
+		for _, cmd := range []Cmd{rangeCmd, addCmd, NewCmdMGet([]Filter{FilterEqual("env", "prod")}), NewCmdInfo("key:any"), NewCmdIncrBy("key:any", 1)} {
+			got = append(got, do(t, r, cmd))
+		}
+		want := []interface{}{"replica0", "primary", "replica1", "replica0", "primary"}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Do() got = %v, want %v", got, want)
+				break
+			}
+		}
+	})
+	t.Run("least latency", func(t *testing.T) {
+		r := newRouter([]time.Duration{10 * time.Millisecond, time.Millisecond}, ReplicaRouterWithSelection(ReplicaLeastLatency))
+		counts := map[interface{}]int{}
+		for i := 0; i < 20; i++ {
+			counts[do(t, r, rangeCmd)]++
+		}
+		if counts["replica1"] != 19 {
+			t.Errorf("Do() counts = %v, want replica1 after the first sample of each replica", counts)
+		}
+	})
+	t.Run("read your writes", func(t *testing.T) {
+		r := newRouter([]time.Duration{0}, ReplicaRouterWithReadYourWrites(time.Second))
+		do(t, r, addCmd)
+		if got := do(t, r, rangeCmd); got != "primary" {
+			t.Errorf("Do() got = %v, want primary", got)
+		}
+		if got := do(t, r, NewCmdRange("key:other", TSMin(), TSMax())); got != "replica0" {
+			t.Errorf("Do() got = %v, want replica0", got)
+		}
+		now = now.Add(time.Second)
+		if got := do(t, r, rangeCmd); got != "replica0" {
+			t.Errorf("Do() got = %v, want replica0", got)
+		}
+	})
+	t.Run("pipeline", func(t *testing.T) {
+		r := newRouter([]time.Duration{0})
+		res, err := r.DoPipeline(ctx, []PipelineCmd{{Name: rangeCmd.Name(), Args: rangeCmd.Args()}, {Name: "TS.GET", Args: []interface{}{"key:any"}}})
+		if err != nil || res[0] != "replica0" {
+			t.Errorf("DoPipeline() = %v, %v, want replica0", res, err)
+		}
+		res, err = r.DoPipeline(ctx, []PipelineCmd{{Name: rangeCmd.Name(), Args: rangeCmd.Args()}, {Name: addCmd.Name(), Args: addCmd.Args()}})
+		if err != nil || res[0] != "primary" {
+			t.Errorf("DoPipeline() = %v, %v, want primary", res, err)
+		}
+	})
+	t.Run("no replicas", func(t *testing.T) {
+		if got := do(t, newRouter(nil), rangeCmd); got != "primary" {
+			t.Errorf("Do() got = %v, want primary", got)
+		}
+	})
+}
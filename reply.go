@@ -0,0 +1,149 @@
+package redists
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// DecodeError is returned when a reply has a shape the decoder does not expect.
+type DecodeError struct {
+	// Field is the part of the reply which could not be decoded, e.g. "Info.totalSamples".
+	Field string
+	// Value is the value which could not be decoded.
+	Value interface{}
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("redists: cannot decode %s: unexpected %T", e.Field, e.Value)
+}
+
+func newDecodeError(field string, val interface{}) error {
+	return &DecodeError{Field: field, Value: val}
+}
+
+func parseString(field string, val interface{}) (string, error) {
+	switch v := val.(type) {
+	case []byte:
+		return string(v), nil
+	case string: // some clients decodes values as string
+		return v, nil
+	default:
+		return "", newDecodeError(field, val)
+	}
+}
+
+func parseInt64(field string, val interface{}) (int64, error) {
+	v, ok := val.(int64)
+	if !ok {
+		return 0, newDecodeError(field, val)
+	}
+	return v, nil
+}
+
+func parseFloat64(field string, val interface{}) (float64, error) {
+	// RESP3 replies contain doubles instead of strings
+	if v, ok := val.(float64); ok {
+		return v, nil
+	}
+	s, err := parseString(field, val)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, newDecodeError(field, val)
+	}
+	return v, nil
+}
+
+// parseArray returns val as an array. A nil reply is an empty array.
+func parseArray(field string, val interface{}) ([]interface{}, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	default:
+		return nil, newDecodeError(field, val)
+	}
+}
+
+// parseTuple returns val as an array with at least n elements.
+func parseTuple(field string, val interface{}, n int) ([]interface{}, error) {
+	is, ok := val.([]interface{})
+	if !ok || len(is) < n {
+		return nil, newDecodeError(field, val)
+	}
+	return is, nil
+}
+
+// isNil reports whether val is a nil reply. Some clients (e.g. radix) decode
+// nil as []uint8(nil) instead of nil(nil).
+func isNil(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	return v.Kind() == reflect.Slice && v.IsNil()
+}
+
+type mapEntry struct {
+	key string
+	val interface{}
+}
+
+// parseMap returns the entries of a RESP3 map sorted by key. The second
+// return value is false when val is not a map. Clients decode RESP3 maps
+// either as map[interface{}]interface{} (e.g. go-redis) or as
+// map[string]interface{}.
+func parseMap(field string, val interface{}) ([]mapEntry, bool, error) {
+	var es []mapEntry
+	switch m := val.(type) {
+	case map[string]interface{}:
+		es = make([]mapEntry, 0, len(m))
+		for k, v := range m {
+			es = append(es, mapEntry{key: k, val: v})
+		}
+	case map[interface{}]interface{}:
+		es = make([]mapEntry, 0, len(m))
+		for k, v := range m {
+			key, err := parseString(field+".key", k)
+			if err != nil {
+				return nil, true, err
+			}
+			es = append(es, mapEntry{key: key, val: v})
+		}
+	default:
+		return nil, false, nil
+	}
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].key < es[j].key
+	})
+	return es, true, nil
+}
+
+// parsePairs returns the key-value pairs of a RESP2 flat array or a RESP3
+// map as a flat array.
+func parsePairs(field string, val interface{}) ([]interface{}, error) {
+	es, ok, err := parseMap(field, val)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		is := make([]interface{}, 0, 2*len(es))
+		for _, e := range es {
+			is = append(is, e.key, e.val)
+		}
+		return is, nil
+	}
+	is, err := parseArray(field, val)
+	if err != nil {
+		return nil, err
+	}
+	if len(is)%2 != 0 {
+		return nil, newDecodeError(field, val)
+	}
+	return is, nil
+}
@@ -0,0 +1,90 @@
+//go:build go1.18
+
+package redists
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// replyTree builds a reply from data. It is used to generate random replies
+// of every shape the supported clients can return.
+func replyTree(data []byte, depth int) (interface{}, []byte) {
+	if len(data) == 0 {
+		return nil, data
+	}
+	kind, data := data[0], data[1:]
+	switch kind % 10 {
+	case 0:
+		return nil, data
+	case 1:
+		if len(data) < 8 {
+			return int64(len(data)), data
+		}
+		return int64(binary.BigEndian.Uint64(data)), data[8:]
+	case 2, 3:
+		n := 0
+		if len(data) > 0 {
+			n, data = int(data[0]%16), data[1:]
+		}
+		if n > len(data) {
+			n = len(data)
+		}
+		if kind%10 == 2 {
+			return string(data[:n]), data[n:]
+		}
+		return data[:n], data[n:]
+	case 4:
+		return errors.New("ERR any"), data
+	case 5:
+		return []uint8(nil), data
+	case 6:
+		return 0.5, data
+	case 7:
+		if depth > 4 || len(data) == 0 {
+			return map[interface{}]interface{}{}, data
+		}
+		n := int(data[0] % 8)
+		data = data[1:]
+		m := make(map[interface{}]interface{}, n)
+		for i := 0; i < n; i++ {
+			var k, v interface{}
+			k, data = replyTree(data, depth+1)
+			v, data = replyTree(data, depth+1)
+			if _, ok := k.([]byte); ok {
+				k = string(k.([]byte))
+			}
+			if k == nil || reflect.TypeOf(k).Comparable() {
+				m[k] = v
+			}
+		}
+		return m, data
+	default:
+		if depth > 4 || len(data) == 0 {
+			return []interface{}{}, data
+		}
+		n := int(data[0] % 8)
+		data = data[1:]
+		is := make([]interface{}, n)
+		for i := range is {
+			is[i], data = replyTree(data, depth+1)
+		}
+		return is, data
+	}
+}
+
+func FuzzParseReply(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{7, 2, 7, 2, 1, 0, 0, 0, 0, 0, 0, 3, 232, 2, 3, '0', '.', '5'})
+	f.Add([]byte{7, 4, 2, 12, 't', 'o', 't', 'a', 'l', 'S', 'a', 'm', 'p', 'l', 'e', 's', 1, 5})
+	f.Add([]byte{7, 1, 7, 3, 2, 1, 'k', 7, 1, 7, 2, 3, 1, 'l', 3, 1, 'v', 7, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		res, _ := replyTree(data, 0)
+		for name := range replyParsers {
+			// the parsers must return an error instead of panicking
+			_, _ = parseReply(rawCmd{name: name}, res)
+		}
+	})
+}
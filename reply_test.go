@@ -0,0 +1,204 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(res interface{}) (interface{}, error)
+		res   interface{}
+		field string
+	}{
+		{
+			name:  "data point value",
+			parse: replyParsers["TS.RANGE"],
+			res:   []interface{}{[]interface{}{int64(1000), int64(1)}},
+			field: "DataPoint.Value",
+		},
+		{
+			name:  "data point timestamp",
+			parse: replyParsers["TS.RANGE"],
+			res:   []interface{}{[]interface{}{"1000", "0.5"}},
+			field: "DataPoint.Timestamp",
+		},
+		{
+			name:  "short data point",
+			parse: replyParsers["TS.GET"],
+			res:   []interface{}{int64(1000)},
+			field: "DataPoint",
+		},
+		{
+			name:  "info field",
+			parse: replyParsers["TS.INFO"],
+			res:   []interface{}{"totalSamples", "1"},
+			field: "Info.totalSamples",
+		},
+		{
+			name:  "odd info",
+			parse: replyParsers["TS.INFO"],
+			res:   []interface{}{"totalSamples"},
+			field: "Info",
+		},
+		{
+			name:  "time series key",
+			parse: replyParsers["TS.MRANGE"],
+			res:   []interface{}{[]interface{}{int64(1), []interface{}{}, []interface{}{}}},
+			field: "TimeSeries.Key",
+		},
+		{
+			name:  "multi result",
+			parse: replyParsers["TS.MADD"],
+			res:   []interface{}{"OK"},
+			field: "MultiResult",
+		},
+		{
+			name:  "timestamp",
+			parse: replyParsers["TS.ADD"],
+			res:   "OK",
+			field: "Timestamp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse(tt.res)
+			var derr *DecodeError
+			if !errors.As(err, &derr) {
+				t.Fatalf("parse() error = %v, want %T", err, derr)
+			}
+			if got, want := derr.Field, tt.field; got != want {
+				t.Errorf("DecodeError.Field = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_Get_decodeError(t *testing.T) {
+	d := &replyDoer{replies: []interface{}{"OK"}}
+	_, err := NewClient(d).Get(context.Background(), "key:any")
+	var cerr *CmdError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Get() error = %v, want %T", err, cerr)
+	}
+	if got, want := cerr.Key, "key:any"; got != want {
+		t.Errorf("CmdError.Key = %v, want %v", got, want)
+	}
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatalf("Get() error = %v, want %T", err, derr)
+	}
+}
+
+func TestParseInfo_nil(t *testing.T) {
+	inf, err := parseInfo([]interface{}{
+		"totalSamples", int64(1),
+		"sourceKey", []uint8(nil),
+		"duplicatePolicy", nil,
+		"firstTimestamp", int64(1000),
+	})
+	if err != nil {
+		t.Fatalf("parseInfo() error = %v", err)
+	}
+	want := Info{TotalSamples: 1, FirstTimestamp: time.UnixMilli(1000)}
+	if !reflect.DeepEqual(inf, want) {
+		t.Errorf("parseInfo() got = %v, want %v", inf, want)
+	}
+}
+
+func TestParseReply_resp3(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		res  interface{}
+		want interface{}
+	}{
+		{
+			name: "range",
+			cmd:  "TS.RANGE",
+			res:  []interface{}{[]interface{}{int64(1000), 0.5}, []interface{}{int64(2000), 1.5}},
+			want: []DataPoint{{time.UnixMilli(1000), 0.5}, {time.UnixMilli(2000), 1.5}},
+		},
+		{
+			name: "get",
+			cmd:  "TS.GET",
+			res:  []interface{}{int64(1000), 0.5},
+			want: &DataPoint{time.UnixMilli(1000), 0.5},
+		},
+		{
+			name: "mrange",
+			cmd:  "TS.MRANGE",
+			res: map[interface{}]interface{}{
+				"key:other": []interface{}{
+					map[interface{}]interface{}{"l": "v"},
+					map[interface{}]interface{}{"aggregators": []interface{}{"avg"}},
+					[]interface{}{[]interface{}{int64(1000), 1.5}},
+				},
+				"key:any": []interface{}{
+					map[interface{}]interface{}{},
+					[]interface{}{[]interface{}{int64(1000), 0.5}},
+				},
+			},
+			want: []TimeSeries{
+				{Key: "key:any", Labels: Labels{}, DataPoints: []DataPoint{{time.UnixMilli(1000), 0.5}}},
+				{Key: "key:other", Labels: Labels{"l": "v"}, DataPoints: []DataPoint{{time.UnixMilli(1000), 1.5}}},
+			},
+		},
+		{
+			name: "mget",
+			cmd:  "TS.MGET",
+			res: map[string]interface{}{
+				"key:any": []interface{}{
+					map[string]interface{}{"l": "v"},
+					[]interface{}{int64(1000), 0.5},
+				},
+				"key:other": []interface{}{
+					map[string]interface{}{"l": nil},
+					[]interface{}{},
+				},
+			},
+			want: []LastDatapoint{
+				{Key: "key:any", Labels: Labels{"l": "v"}, DataPoint: &DataPoint{time.UnixMilli(1000), 0.5}},
+				{Key: "key:other", Labels: Labels{"l": ""}},
+			},
+		},
+		{
+			name: "info",
+			cmd:  "TS.INFO",
+			res: map[interface{}]interface{}{
+				"totalSamples":    int64(1),
+				"retentionTime":   int64(1000),
+				"duplicatePolicy": nil,
+				"labels":          map[interface{}]interface{}{"l": "v"},
+				"rules": map[interface{}]interface{}{
+					"key:dest": []interface{}{int64(1000), "avg", int64(0)},
+				},
+				"Chunks": []interface{}{
+					map[interface{}]interface{}{"startTimestamp": int64(1000), "bytesPerSample": 0.5},
+				},
+			},
+			want: Info{
+				TotalSamples:  1,
+				RetentionTime: time.Second,
+				Labels:        Labels{"l": "v"},
+				Rules:         Rules{"key:dest": {Type: AggregationTypeAvg, Bucket: time.Second}},
+				Chunks:        []ChunkInfo{{StartTimestamp: time.UnixMilli(1000), BytesPerSample: 0.5}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReply(rawCmd{name: tt.cmd}, tt.res)
+			if err != nil {
+				t.Fatalf("parseReply() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseReply() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package redists
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// ServerError is an error reply of the server, e.g. "ERR unknown command".
+type ServerError string
+
+func (e ServerError) Error() string {
+	return string(e)
+}
+
+var errProtocol = errors.New("redists: protocol error")
+
+// maxLen limits the length of bulk strings and aggregates, like the
+// proto-max-bulk-len default of Redis, so a corrupt length cannot exhaust the
+// memory.
+const maxLen = 512 << 20
+
+// appendCommand appends a command as a RESP array of bulk strings.
+func appendCommand(b []byte, cmd string, args []interface{}) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)+1), 10)
+	b = append(b, '\r', '\n')
+	b = appendBulkString(b, cmd)
+	for _, arg := range args {
+		b = appendArg(b, arg)
+	}
+	return b
+}
+
+func appendBulkString(b []byte, s string) []byte {
+	b = append(b, '$')
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, '\r', '\n')
+	b = append(b, s...)
+	return append(b, '\r', '\n')
+}
+
+func appendBulkBytes(b []byte, s []byte) []byte {
+	b = append(b, '$')
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, '\r', '\n')
+	b = append(b, s...)
+	return append(b, '\r', '\n')
+}
+
+// appendArg appends an argument as a bulk string. Numbers are formatted like
+// the other clients do.
+func appendArg(b []byte, arg interface{}) []byte {
+	var buf [32]byte
+	switch v := arg.(type) {
+	case string:
+		return appendBulkString(b, v)
+	case []byte:
+		return appendBulkBytes(b, v)
+	case int:
+		return appendBulkBytes(b, strconv.AppendInt(buf[:0], int64(v), 10))
+	case int64:
+		return appendBulkBytes(b, strconv.AppendInt(buf[:0], v, 10))
+	case int32:
+		return appendBulkBytes(b, strconv.AppendInt(buf[:0], int64(v), 10))
+	case uint:
+		return appendBulkBytes(b, strconv.AppendUint(buf[:0], uint64(v), 10))
+	case uint64:
+		return appendBulkBytes(b, strconv.AppendUint(buf[:0], v, 10))
+	case float64:
+		return appendBulkBytes(b, appendFloat(buf[:0], v, 64))
+	case float32:
+		return appendBulkBytes(b, appendFloat(buf[:0], float64(v), 32))
+	case bool:
+		if v {
+			return appendBulkString(b, "1")
+		}
+		return appendBulkString(b, "0")
+	case nil:
+		return appendBulkString(b, "")
+	default:
+		return appendBulkString(b, fmt.Sprint(v))
+	}
+}
+
+func appendFloat(b []byte, f float64, bitSize int) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(b, "+inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "-inf"...)
+	}
+	return strconv.AppendFloat(b, f, 'f', -1, bitSize)
+}
+
+// readLine returns a line without the trailing CRLF. The returned slice is
+// only valid until the next read.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		buf := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			line, err = r.ReadSlice('\n')
+			buf = append(buf, line...)
+		}
+		line = buf
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+func parseInt(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, errProtocol
+	}
+	neg := b[0] == '-'
+	if neg || b[0] == '+' {
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, errProtocol
+		}
+	}
+	// longer numbers may overflow int64
+	if len(b) > 18 {
+		return 0, errProtocol
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, errProtocol
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// readReply reads a RESP2 or RESP3 reply. Error replies are returned as
+// ServerError values, while the returned error means that the connection
+// cannot be used anymore. Bulk strings are decoded as string, maps as
+// map[interface{}]interface{}, and nulls as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return ServerError(line[1:]), nil
+	case ':':
+		return parseInt(line[1:])
+	case '_':
+		return nil, nil
+	case ',':
+		v, err := strconv.ParseFloat(string(line[1:]), 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+		return v, nil
+	case '#':
+		if len(line) != 2 || (line[1] != 't' && line[1] != 'f') {
+			return nil, errProtocol
+		}
+		return line[1] == 't', nil
+	case '(':
+		return string(line[1:]), nil
+	case '$', '=', '!':
+		n, err := readLen(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, errProtocol
+		}
+		s := string(buf[:n])
+		switch line[0] {
+		case '=':
+			// verbatim strings start with the format, e.g. "txt:"
+			if len(s) < 4 {
+				return nil, errProtocol
+			}
+			return s[4:], nil
+		case '!':
+			return ServerError(s), nil
+		}
+		return s, nil
+	case '*', '~', '>':
+		n, err := readLen(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// the length is not trusted until the elements arrive
+		vs := make([]interface{}, 0, capHint(n))
+		for i := int64(0); i < n; i++ {
+			v, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+		return vs, nil
+	case '%', '|':
+		n, err := readLen(line)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errProtocol
+		}
+		m := make(map[interface{}]interface{}, capHint(n))
+		for i := int64(0); i < n; i++ {
+			k, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			v, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, errProtocol
+			}
+			m[k] = v
+		}
+		if line[0] == '|' {
+			// attributes describe the next reply, which is the actual one
+			return readReply(r)
+		}
+		return m, nil
+	}
+	return nil, errProtocol
+}
+
+// readLen parses the length of a bulk string or an aggregate. The length -1
+// means null in RESP2.
+func readLen(line []byte) (int64, error) {
+	n, err := parseInt(line[1:])
+	if err != nil {
+		return 0, err
+	}
+	if n < -1 || n > maxLen {
+		return 0, errProtocol
+	}
+	return n, nil
+}
+
+// capHint returns the capacity to allocate for an aggregate of length n.
+func capHint(n int64) int {
+	if n > 1024 {
+		return 1024
+	}
+	return int(n)
+}
@@ -0,0 +1,200 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// transientPrefixes are the prefixes of server errors which are expected to
+// go away on their own.
+var transientPrefixes = []string{"LOADING ", "TRYAGAIN ", "CLUSTERDOWN ", "MASTERDOWN ", "BUSY "}
+
+// IsTransient reports whether err is expected to go away when the command is
+// sent again, e.g. a closed connection or a server which is loading its
+// dataset. Context errors are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	for _, target := range []error{io.EOF, io.ErrUnexpectedEOF, net.ErrClosed, syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.EPIPE} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	msg := err.Error()
+	for _, prefix := range transientPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retrier is a Doer which sends commands again when they fail with a
+// transient error. Only commands in CmdClassRead and CmdClassWrite are sent
+// again, see ClassifyCmd. The delay between the attempts grows exponentially
+// with full jitter.
+type Retrier struct {
+	d           Doer
+	attempts    int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	timeouts    map[CmdClass]time.Duration
+	policy      DuplicatePolicy
+	isRetryable func(err error) bool
+}
+
+var _ PipelineDoer = (*Retrier)(nil)
+
+type OptionRetrier func(r *Retrier)
+
+// NewRetrier returns a Retrier which sends commands to d. By default it makes
+// 3 attempts with a backoff between 10ms and 1s, and it retries the errors
+// matched by IsTransient.
+func NewRetrier(d Doer, options ...OptionRetrier) *Retrier {
+	r := &Retrier{
+		d:           d,
+		attempts:    3,
+		minBackoff:  10 * time.Millisecond,
+		maxBackoff:  time.Second,
+		timeouts:    map[CmdClass]time.Duration{},
+		isRetryable: IsTransient,
+	}
+	for i := range options {
+		options[i](r)
+	}
+	return r
+}
+
+// RetrierWithMaxAttempts sets the number of attempts, including the first one.
+func RetrierWithMaxAttempts(n int) OptionRetrier {
+	return func(r *Retrier) {
+		r.attempts = n
+	}
+}
+
+// RetrierWithBackoff sets the delay before the first retry and the maximum
+// delay between attempts. The delay doubles after every attempt, and the
+// actual delay is a random duration up to it.
+func RetrierWithBackoff(min, max time.Duration) OptionRetrier {
+	return func(r *Retrier) {
+		r.minBackoff = min
+		r.maxBackoff = max
+	}
+}
+
+// RetrierWithTimeout sets the deadline of the commands of a class, including
+// the retries. A pipeline gets the longest deadline of its commands.
+func RetrierWithTimeout(class CmdClass, d time.Duration) OptionRetrier {
+	return func(r *Retrier) {
+		r.timeouts[class] = d
+	}
+}
+
+// RetrierWithDuplicatePolicy sets the duplicate policy of the time-series,
+// which ClassifyCmd uses for TS.ADD and TS.MADD. The default is
+// DuplicatePolicyBlock, so samples are not added again.
+func RetrierWithDuplicatePolicy(dp DuplicatePolicy) OptionRetrier {
+	return func(r *Retrier) {
+		r.policy = dp
+	}
+}
+
+// RetrierWithRetryable sets the function which reports whether an error is
+// retried.
+func RetrierWithRetryable(f func(err error) bool) OptionRetrier {
+	return func(r *Retrier) {
+		r.isRetryable = f
+	}
+}
+
+func (r *Retrier) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	class := ClassifyCmd(cmd, args, r.policy)
+	if d, ok := r.timeouts[class]; ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	var res interface{}
+	err := r.retry(ctx, class != CmdClassNonIdempotent, func() (err error) {
+		res, err = r.d.Do(ctx, cmd, args...)
+		return err
+	})
+	return res, err
+}
+
+// DoPipeline sends the commands with a single DoPipeline of the underlying
+// Doer when it is a PipelineDoer, and it retries the whole pipeline when every
+// command is safe to retry. Otherwise, it sends the commands with Do.
+func (r *Retrier) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	pd, ok := r.d.(PipelineDoer)
+	if !ok {
+		return doEach(ctx, r, cmds)
+	}
+	safe := true
+	var timeout time.Duration
+	hasTimeout := true
+	for _, cmd := range cmds {
+		class := ClassifyCmd(cmd.Name, cmd.Args, r.policy)
+		safe = safe && class != CmdClassNonIdempotent
+		d, ok := r.timeouts[class]
+		hasTimeout = hasTimeout && ok
+		if d > timeout {
+			timeout = d
+		}
+	}
+	if hasTimeout && len(cmds) > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var res []interface{}
+	err := r.retry(ctx, safe, func() (err error) {
+		res, err = pd.DoPipeline(ctx, cmds)
+		return err
+	})
+	return res, err
+}
+
+// retry calls f until it succeeds, it fails with an error which is not
+// retryable, or it runs out of attempts.
+func (r *Retrier) retry(ctx context.Context, safe bool, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || !safe || attempt+1 >= r.attempts || !r.isRetryable(err) {
+			return err
+		}
+		t := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns a random delay before the retry after attempt.
+func (r *Retrier) backoff(attempt int) time.Duration {
+	d := r.minBackoff
+	for i := 0; i < attempt && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
@@ -0,0 +1,157 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// flakyDoer fails the first n commands with err.
+type flakyDoer struct {
+	n     int
+	err   error
+	calls int
+}
+
+func (d *flakyDoer) Do(ctx context.Context, _ string, _ ...interface{}) (interface{}, error) {
+	d.calls++
+	if d.calls <= d.n {
+		return nil, d.err
+	}
+	return "OK", ctx.Err()
+}
+
+func (d *flakyDoer) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	if _, err := d.Do(ctx, ""); err != nil {
+		return nil, err
+	}
+	return make([]interface{}, len(cmds)), nil
+}
+
+func TestRetrier(t *testing.T) {
+	ctx := context.Background()
+	noBackoff := RetrierWithBackoff(0, 0)
+	tests := []struct {
+		name      string
+		d         *flakyDoer
+		cmd       Cmd
+		options   []OptionRetrier
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "read",
+			d:         &flakyDoer{n: 2, err: io.EOF},
+			cmd:       NewCmdRange("key:any", TSMin(), TSMax()),
+			wantCalls: 3,
+		},
+		{
+			name:      "attempts",
+			d:         &flakyDoer{n: 5, err: io.EOF},
+			cmd:       NewCmdMGet([]Filter{FilterEqual("env", "prod")}),
+			options:   []OptionRetrier{RetrierWithMaxAttempts(2)},
+			wantCalls: 2,
+			wantErr:   io.EOF,
+		},
+		{
+			name:      "server error",
+			d:         &flakyDoer{n: 1, err: errors.New("ERR TSDB: the key does not exist")},
+			cmd:       NewCmdInfo("key:any"),
+			wantCalls: 1,
+			wantErr:   errors.New(""),
+		},
+		{
+			name:      "loading",
+			d:         &flakyDoer{n: 1, err: errors.New("LOADING Redis is loading the dataset in memory")},
+			cmd:       NewCmdQueryIndex([]Filter{FilterEqual("env", "prod")}),
+			wantCalls: 2,
+		},
+		{
+			name:      "add block",
+			d:         &flakyDoer{n: 1, err: io.EOF},
+			cmd:       NewCmdAdd(NewSample("key:any", time.UnixMilli(1000), 1)),
+			wantCalls: 1,
+			wantErr:   io.EOF,
+		},
+		{
+			name:      "add last",
+			d:         &flakyDoer{n: 1, err: io.EOF},
+			cmd:       NewCmdAdd(NewSample("key:any", time.UnixMilli(1000), 1)),
+			options:   []OptionRetrier{RetrierWithDuplicatePolicy(DuplicatePolicyLast)},
+			wantCalls: 2,
+		},
+		{
+			name:      "incrby",
+			d:         &flakyDoer{n: 1, err: io.EOF},
+			cmd:       NewCmdIncrBy("key:any", 1),
+			options:   []OptionRetrier{RetrierWithDuplicatePolicy(DuplicatePolicyLast)},
+			wantCalls: 1,
+			wantErr:   io.EOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRetrier(tt.d, append([]OptionRetrier{noBackoff}, tt.options...)...)
+			_, err := r.Do(ctx, tt.cmd.Name(), tt.cmd.Args()...)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == io.EOF && err != io.EOF {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := tt.d.calls; got != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+	t.Run("pipeline", func(t *testing.T) {
+		d := &flakyDoer{n: 1, err: io.EOF}
+		p := NewClient(NewRetrier(d, noBackoff)).Pipeline()
+		p.Get("key:any")
+		p.Info("key:any")
+		if err := p.Exec(ctx); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		if got, want := d.calls, 2; got != want {
+			t.Errorf("calls = %v, want %v", got, want)
+		}
+
+		d = &flakyDoer{n: 1, err: io.EOF}
+		p = NewClient(NewRetrier(d, noBackoff)).Pipeline()
+		p.Get("key:any")
+		p.IncrBy("key:any", 1)
+		if err := p.Exec(ctx); err == nil {
+			t.Errorf("Exec() error = %v, want %v", err, io.EOF)
+		}
+		if got, want := d.calls, 1; got != want {
+			t.Errorf("calls = %v, want %v", got, want)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		d := &flakyDoer{n: 100, err: io.EOF}
+		r := NewRetrier(d, RetrierWithMaxAttempts(100), RetrierWithBackoff(time.Millisecond, time.Millisecond), RetrierWithTimeout(CmdClassRead, 20*time.Millisecond))
+		start := time.Now()
+		if _, err := r.Do(ctx, "TS.GET", "key:any"); err != io.EOF {
+			t.Errorf("Do() error = %v, want %v", err, io.EOF)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Do() took %v, want about 20ms", elapsed)
+		}
+		if d.calls >= 100 {
+			t.Errorf("calls = %v, want fewer than 100", d.calls)
+		}
+	})
+}
+
+func TestRetrier_backoff(t *testing.T) {
+	r := NewRetrier(nil, RetrierWithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	for attempt, max := range []time.Duration{10, 20, 40, 50, 50} {
+		for i := 0; i < 100; i++ {
+			if d := r.backoff(attempt); d < 0 || d > max*time.Millisecond {
+				t.Fatalf("backoff(%v) = %v, want at most %v", attempt, d, max*time.Millisecond)
+			}
+		}
+	}
+}
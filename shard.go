@@ -0,0 +1,168 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrCrossShard is returned by ShardedClient when the keys of a command are
+// on different shards.
+var ErrCrossShard = errors.New("redists: keys are on different shards")
+
+// shardVirtualNodes is the number of points of each shard on the hash ring.
+const shardVirtualNodes = 160
+
+// shardRing is a consistent hash ring.
+type shardRing struct {
+	points []uint32
+	shards []int
+}
+
+func newShardRing(n int) *shardRing {
+	r := &shardRing{}
+	type point struct {
+		hash  uint32
+		shard int
+	}
+	ps := make([]point, 0, n*shardVirtualNodes)
+	for i := 0; i < n; i++ {
+		for j := 0; j < shardVirtualNodes; j++ {
+			ps = append(ps, point{crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "-" + strconv.Itoa(j))), i})
+		}
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].hash < ps[j].hash
+	})
+	for _, p := range ps {
+		r.points = append(r.points, p.hash)
+		r.shards = append(r.shards, p.shard)
+	}
+	return r
+}
+
+// shard returns the shard of a key.
+func (r *shardRing) shard(key string) int {
+	h := crc32.ChecksumIEEE([]byte(hashTag(key)))
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.shards[i]
+}
+
+// shardedDoer is a ClusterDoer which routes commands to the shard of their
+// key.
+type shardedDoer struct {
+	ds   []Doer
+	ring *shardRing
+}
+
+var _ mAddDoer = (*shardedDoer)(nil)
+
+func (d *shardedDoer) Primaries(context.Context) ([]Doer, error) {
+	return d.ds, nil
+}
+
+func (d *shardedDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+	if filterCmds[name] {
+		return nil, fmt.Errorf("redists: %s must be sent to every shard", name)
+	}
+	keys := cmdKeys(name, args)
+	if len(keys) == 0 {
+		return d.ds[0].Do(ctx, cmd, args...)
+	}
+	if name == "TS.MADD" {
+		return d.mAdd(ctx, cmd, args)
+	}
+	shard := d.ring.shard(keys[0])
+	for _, key := range keys[1:] {
+		if s := d.ring.shard(key); s != shard {
+			return nil, fmt.Errorf("%w: %s is on shard %d, %s is on shard %d", ErrCrossShard, keys[0], shard, key, s)
+		}
+	}
+	return d.ds[shard].Do(ctx, cmd, args...)
+}
+
+// mAdd sends a TS.MADD to each shard of the samples, and it merges the
+// replies in the order of the samples. When the TS.MADD of a shard fails, the
+// reply contains the error for its samples.
+func (d *shardedDoer) mAdd(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	byShard := map[int][]int{}
+	var shards []int
+	for i := 0; i+2 < len(args); i += 3 {
+		key, _ := args[i].(string)
+		s := d.ring.shard(key)
+		if _, ok := byShard[s]; !ok {
+			shards = append(shards, s)
+		}
+		byShard[s] = append(byShard[s], i/3)
+	}
+	if len(shards) == 1 {
+		return d.ds[shards[0]].Do(ctx, cmd, args...)
+	}
+	res := make([]interface{}, len(args)/3)
+	errs := make([]error, len(shards))
+	parallel(len(shards), func(i int) {
+		idx := byShard[shards[i]]
+		sargs := make([]interface{}, 0, 3*len(idx))
+		for _, j := range idx {
+			sargs = append(sargs, args[3*j:3*j+3]...)
+		}
+		val, err := d.ds[shards[i]].Do(ctx, cmd, sargs...)
+		var is []interface{}
+		if err == nil {
+			if is, err = parseArray("MultiResult", val); err == nil && len(is) != len(idx) {
+				err = fmt.Errorf("redists: got %d results for %d samples", len(is), len(idx))
+			}
+		}
+		for k, j := range idx {
+			if err != nil {
+				res[j] = err
+			} else {
+				res[j] = is[k]
+			}
+		}
+		errs[i] = err
+	})
+	for _, err := range errs {
+		if err == nil {
+			return res, nil
+		}
+	}
+	return nil, errs[0]
+}
+
+// ShardedClient is a Client which distributes the time-series across
+// independent Redis servers. Commands with a key are sent to the shard of
+// the key, which is chosen by consistent hashing. When the key contains a
+// hash tag (e.g. "{sensor:1}:temp"), only the tag is hashed, so time-series
+// with the same tag are on the same shard.
+//
+// MRange, MRevRange, MGet and QueryIndex are sent to every shard and the
+// replies are merged like in cluster mode, see ClusterDoer. MAdd sends a
+// single TS.MADD to each shard of the samples. Compaction rules between
+// time-series on different shards fail with ErrCrossShard.
+type ShardedClient struct {
+	*Client
+	sd *shardedDoer
+}
+
+// NewShardedClient returns a ShardedClient which uses a Doer for each shard.
+// The order of the Doers must not change, because it identifies the shards.
+func NewShardedClient(ds []Doer, options ...OptionClient) *ShardedClient {
+	sd := &shardedDoer{ds: ds, ring: newShardRing(len(ds))}
+	return &ShardedClient{Client: NewClient(sd, options...), sd: sd}
+}
+
+// Shard returns the index of the shard of a key.
+func (c *ShardedClient) Shard(key string) int {
+	return c.sd.ring.shard(key)
+}
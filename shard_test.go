@@ -0,0 +1,165 @@
+package redists_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coding-socks/redists"
+	"github.com/coding-socks/redists/redistest"
+)
+
+// countingDoer counts the commands by name.
+type countingDoer struct {
+	redists.Doer
+	mu   sync.Mutex
+	cmds map[string]int
+}
+
+func (d *countingDoer) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	d.mu.Lock()
+	d.cmds[cmd]++
+	d.mu.Unlock()
+	return d.Doer.Do(ctx, cmd, args...)
+}
+
+func TestShardedClient_MAdd(t *testing.T) {
+	ctx := context.Background()
+	var ds []redists.Doer
+	var counters []*countingDoer
+	for i := 0; i < 3; i++ {
+		d := &countingDoer{Doer: redistest.NewDoer(), cmds: map[string]int{}}
+		ds, counters = append(ds, d), append(counters, d)
+	}
+	c := redists.NewShardedClient(ds)
+	samples := make([]redists.Sample, 1000)
+	for i := range samples {
+		samples[i] = redists.NewSample(fmt.Sprintf("cpu:%d", i), time.UnixMilli(1000), float64(i))
+		if err := c.Create(ctx, samples[i].Key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rs, err := c.MAdd(ctx, samples)
+	if err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	for i, r := range rs {
+		if r.Err() != nil || !r.Time().Equal(time.UnixMilli(1000)) {
+			t.Fatalf("MAdd() result %d = %v, %v", i, r.Time(), r.Err())
+		}
+	}
+	for i, d := range counters {
+		if n := d.cmds["TS.MADD"]; n != 1 {
+			t.Errorf("TS.MADD on shard %d = %v, want 1", i, n)
+		}
+	}
+}
+
+func TestShardedClient(t *testing.T) {
+	ctx := context.Background()
+	var ds []redists.Doer
+	for i := 0; i < 3; i++ {
+		ds = append(ds, redistest.NewDoer())
+	}
+	c := redists.NewShardedClient(ds)
+	single := redists.NewClient(redistest.NewDoer())
+
+	var samples []redists.Sample
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("cpu:%d", i)
+		labels := redists.Labels{"metric": "cpu", "host": fmt.Sprint(i % 4)}
+		for _, c := range []*redists.Client{c.Client, single} {
+			if err := c.Create(ctx, key, redists.CreateWithLabels(labels)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		samples = append(samples, redists.NewSample(key, time.UnixMilli(1000), float64(i)))
+	}
+	if _, err := c.MAdd(ctx, samples); err != nil {
+		t.Fatalf("MAdd() error = %v", err)
+	}
+	if _, err := single.MAdd(ctx, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("routing", func(t *testing.T) {
+		used := map[int]bool{}
+		for _, s := range samples {
+			shard := c.Shard(s.Key)
+			used[shard] = true
+			for i, d := range ds {
+				_, err := redists.NewClient(d).Info(ctx, s.Key)
+				if exists := err == nil; exists != (i == shard) {
+					t.Errorf("Info(%q) on shard %d error = %v, want the key on shard %d", s.Key, i, err, shard)
+				}
+			}
+			if _, err := c.Range(ctx, s.Key, redists.TSMin(), redists.TSMax()); err != nil {
+				t.Errorf("Range() error = %v", err)
+			}
+		}
+		if len(used) != len(ds) {
+			t.Errorf("shards used = %v, want %v", len(used), len(ds))
+		}
+	})
+	t.Run("fan-out", func(t *testing.T) {
+		filters := []redists.Filter{redists.FilterEqual("metric", "cpu")}
+		got, err := c.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithGroupBy("host", redists.ReducerSum))
+		if err != nil {
+			t.Fatalf("MRange() error = %v", err)
+		}
+		want, _ := single.MRange(ctx, redists.TSMin(), redists.TSMax(), filters, redists.MRangerWithGroupBy("host", redists.ReducerSum))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("MRange() got = %v, want %v", got, want)
+		}
+		keys, err := c.QueryIndex(ctx, filters)
+		if err != nil {
+			t.Fatalf("QueryIndex() error = %v", err)
+		}
+		if want, _ := single.QueryIndex(ctx, filters); !reflect.DeepEqual(keys, want) {
+			t.Errorf("QueryIndex() got = %v, want %v", keys, want)
+		}
+	})
+	t.Run("hash tags", func(t *testing.T) {
+		if a, b := c.Shard("{sensor:1}:temp"), c.Shard("{sensor:1}:temp:avg"); a != b {
+			t.Errorf("Shard() = %v and %v, want the same shard", a, b)
+		}
+		for _, key := range []string{"{sensor:1}:temp", "{sensor:1}:temp:avg"} {
+			if err := c.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.CreateRule(ctx, "{sensor:1}:temp", "{sensor:1}:temp:avg", redists.AggregationTypeAvg, time.Minute); err != nil {
+			t.Errorf("CreateRule() error = %v", err)
+		}
+	})
+	t.Run("cross shard rule", func(t *testing.T) {
+		src, dest := "cpu:0", ""
+		for _, s := range samples {
+			if c.Shard(s.Key) != c.Shard(src) {
+				dest = s.Key
+				break
+			}
+		}
+		err := c.CreateRule(ctx, src, dest, redists.AggregationTypeAvg, time.Minute)
+		if !errors.Is(err, redists.ErrCrossShard) {
+			t.Errorf("CreateRule() error = %v, want %v", err, redists.ErrCrossShard)
+		}
+	})
+	t.Run("consistent", func(t *testing.T) {
+		more := redists.NewShardedClient(append(ds, redistest.NewDoer()))
+		var moved int
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			if c.Shard(key) != more.Shard(key) {
+				moved++
+			}
+		}
+		if moved > 400 {
+			t.Errorf("moved = %v, want about 250", moved)
+		}
+	})
+}
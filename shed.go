@@ -0,0 +1,360 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded is matched by the errors of Shedder when it rejects a
+// command.
+var ErrOverloaded = errors.New("redists: overloaded")
+
+// OverloadReason is the reason Shedder rejected a command.
+type OverloadReason string
+
+const (
+	// OverloadRateLimit means that the command exceeded the rate limit of its
+	// class.
+	OverloadRateLimit = OverloadReason("rate limit")
+	// OverloadBurst means that a pipeline needs more tokens than the burst of
+	// the rate limit of its class. It is never admitted, so it must be split.
+	OverloadBurst = OverloadReason("burst exceeded")
+	// OverloadConcurrency means that too many commands are in flight.
+	OverloadConcurrency = OverloadReason("concurrency limit")
+	// OverloadCircuitOpen means that the circuit breaker is open.
+	OverloadCircuitOpen = OverloadReason("circuit open")
+)
+
+// OverloadedError is returned by Shedder when it rejects a command. It
+// matches ErrOverloaded with errors.Is.
+type OverloadedError struct {
+	// Cmd is the name of the rejected command. It is empty for pipelines.
+	Cmd string
+	// Reason is the reason of the rejection.
+	Reason OverloadReason
+	// RetryAfter is the time after which the command may be accepted. It is
+	// zero when it is unknown.
+	RetryAfter time.Duration
+}
+
+func (e *OverloadedError) Error() string {
+	msg := ErrOverloaded.Error() + ": " + string(e.Reason)
+	if e.Cmd != "" {
+		msg = e.Cmd + ": " + msg
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %v", e.RetryAfter)
+	}
+	return msg
+}
+
+func (e *OverloadedError) Is(target error) bool {
+	return target == ErrOverloaded
+}
+
+// BreakerState is the state of the circuit breaker of Shedder.
+type BreakerState int
+
+const (
+	// BreakerClosed lets commands through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects commands until the cooldown elapses.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe command through. The breaker closes
+	// when it succeeds, and it opens again when it fails.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+const (
+	// latencyAlpha is the weight of the latest command in the moving average
+	// of the latency.
+	latencyAlpha = 0.1
+	// breakerMinSamples is the number of commands after which the moving
+	// average of the latency can open the breaker.
+	breakerMinSamples = 10
+)
+
+// tokenBucket is a token bucket rate limiter. It must be used with the lock
+// of Shedder held.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// wait returns the time until n tokens are available.
+func (b *tokenBucket) wait(n int) time.Duration {
+	missing := float64(n) - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	if b.rate <= 0 {
+		return -1
+	}
+	return time.Duration(missing / b.rate * float64(time.Second))
+}
+
+// Shedder is a Doer which rejects commands with an OverloadedError instead of
+// sending them when the server or the client is overloaded. Reads and writes
+// have separate token bucket rate limits, so expensive queries cannot starve
+// ingestion. Commands are classified with ClassifyCmd, and non-idempotent
+// commands count as writes.
+type Shedder struct {
+	d   Doer
+	now func() time.Time
+
+	mu       sync.Mutex
+	read     *tokenBucket
+	write    *tokenBucket
+	max      int
+	inflight int
+
+	failures    int
+	latency     time.Duration
+	cooldown    time.Duration
+	state       BreakerState
+	openedAt    time.Time
+	probing     bool
+	consecutive int
+	samples     int
+	avg         float64
+}
+
+var _ PipelineDoer = (*Shedder)(nil)
+
+type OptionShedder func(s *Shedder)
+
+// NewShedder returns a Shedder which sends commands to d. Without options it
+// does not reject commands.
+func NewShedder(d Doer, options ...OptionShedder) *Shedder {
+	s := &Shedder{d: d, now: time.Now}
+	for i := range options {
+		options[i](s)
+	}
+	return s
+}
+
+// ShedderWithReadLimit limits the read commands to rate per second with
+// bursts of burst commands.
+func ShedderWithReadLimit(rate float64, burst int) OptionShedder {
+	return func(s *Shedder) {
+		s.read = newTokenBucket(rate, burst)
+	}
+}
+
+// ShedderWithWriteLimit limits the write commands to rate per second with
+// bursts of burst commands.
+func ShedderWithWriteLimit(rate float64, burst int) OptionShedder {
+	return func(s *Shedder) {
+		s.write = newTokenBucket(rate, burst)
+	}
+}
+
+// ShedderWithMaxConcurrency limits the number of commands in flight. A
+// pipeline counts as a single command.
+func ShedderWithMaxConcurrency(n int) OptionShedder {
+	return func(s *Shedder) {
+		s.max = n
+	}
+}
+
+// ShedderWithBreaker enables the circuit breaker. It opens after the given
+// number of consecutive failures, or when the moving average of the latency
+// exceeds latency. Zero disables either condition. Failures are the errors
+// matched by IsTransient and context.DeadlineExceeded. After cooldown, the
+// breaker lets a probe command through.
+func ShedderWithBreaker(failures int, latency time.Duration, cooldown time.Duration) OptionShedder {
+	return func(s *Shedder) {
+		s.failures = failures
+		s.latency = latency
+		s.cooldown = cooldown
+	}
+}
+
+// BreakerState returns the state of the circuit breaker.
+func (s *Shedder) BreakerState() BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == BreakerOpen && !s.now().Before(s.openedAt.Add(s.cooldown)) {
+		return BreakerHalfOpen
+	}
+	return s.state
+}
+
+// admit reserves the resources of a command with the given number of reads
+// and writes. It returns whether the command is a probe of the breaker.
+func (s *Shedder) admit(cmd string, reads, writes int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.state == BreakerOpen {
+		if wait := s.openedAt.Add(s.cooldown).Sub(now); wait > 0 {
+			return false, &OverloadedError{Cmd: cmd, Reason: OverloadCircuitOpen, RetryAfter: wait}
+		}
+		s.state = BreakerHalfOpen
+	}
+	if s.state == BreakerHalfOpen && s.probing {
+		return false, &OverloadedError{Cmd: cmd, Reason: OverloadCircuitOpen}
+	}
+	if s.max > 0 && s.inflight >= s.max {
+		return false, &OverloadedError{Cmd: cmd, Reason: OverloadConcurrency}
+	}
+	var wait time.Duration
+	for _, b := range []struct {
+		bucket *tokenBucket
+		n      int
+	}{{s.read, reads}, {s.write, writes}} {
+		if b.bucket == nil || b.n == 0 {
+			continue
+		}
+		// the bucket never holds more tokens than its burst
+		if float64(b.n) > b.bucket.burst {
+			return false, &OverloadedError{Cmd: cmd, Reason: OverloadBurst}
+		}
+		b.bucket.refill(now)
+		if w := b.bucket.wait(b.n); w != 0 {
+			if w < 0 || wait < 0 {
+				wait = -1
+			} else if w > wait {
+				wait = w
+			}
+		}
+	}
+	if wait != 0 {
+		e := &OverloadedError{Cmd: cmd, Reason: OverloadRateLimit}
+		if wait > 0 {
+			e.RetryAfter = wait
+		}
+		return false, e
+	}
+	if s.read != nil {
+		s.read.tokens -= float64(reads)
+	}
+	if s.write != nil {
+		s.write.tokens -= float64(writes)
+	}
+	s.inflight++
+	probe := s.state == BreakerHalfOpen
+	s.probing = probe
+	return probe, nil
+}
+
+// done releases the resources of a command and updates the breaker.
+func (s *Shedder) done(start time.Time, probe bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.inflight--
+	d := now.Sub(start)
+	failed := IsTransient(err) || errors.Is(err, context.DeadlineExceeded)
+	if probe {
+		s.probing = false
+		if failed || (s.latency > 0 && d > s.latency) {
+			s.open(now)
+		} else {
+			s.close()
+		}
+		return
+	}
+	if s.state != BreakerClosed {
+		return
+	}
+	if failed {
+		s.consecutive++
+	} else {
+		s.consecutive = 0
+	}
+	if s.samples == 0 {
+		s.avg = float64(d)
+	} else {
+		s.avg += latencyAlpha * (float64(d) - s.avg)
+	}
+	s.samples++
+	if (s.failures > 0 && s.consecutive >= s.failures) ||
+		(s.latency > 0 && s.samples >= breakerMinSamples && time.Duration(s.avg) > s.latency) {
+		s.open(now)
+	}
+}
+
+func (s *Shedder) open(now time.Time) {
+	s.state = BreakerOpen
+	s.openedAt = now
+}
+
+func (s *Shedder) close() {
+	s.state = BreakerClosed
+	s.consecutive = 0
+	s.samples = 0
+	s.avg = 0
+}
+
+func (s *Shedder) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	reads, writes := 1, 0
+	if ClassifyCmd(cmd, args, "") != CmdClassRead {
+		reads, writes = 0, 1
+	}
+	probe, err := s.admit(cmd, reads, writes)
+	if err != nil {
+		return nil, err
+	}
+	start := s.now()
+	res, err := s.d.Do(ctx, cmd, args...)
+	s.done(start, probe, err)
+	return res, err
+}
+
+// DoPipeline admits a pipeline as a single command which takes a token for
+// each of its commands. When the underlying Doer is not a PipelineDoer, the
+// commands are admitted one by one.
+func (s *Shedder) DoPipeline(ctx context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	pd, ok := s.d.(PipelineDoer)
+	if !ok {
+		return doEach(ctx, s, cmds)
+	}
+	var reads, writes int
+	for _, cmd := range cmds {
+		if ClassifyCmd(cmd.Name, cmd.Args, "") == CmdClassRead {
+			reads++
+		} else {
+			writes++
+		}
+	}
+	probe, err := s.admit("", reads, writes)
+	if err != nil {
+		return nil, err
+	}
+	start := s.now()
+	res, err := pd.DoPipeline(ctx, cmds)
+	s.done(start, probe, err)
+	return res, err
+}
@@ -0,0 +1,180 @@
+package redists
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// clockDoer advances a fake clock by latency on every command, and it fails
+// with err.
+type clockDoer struct {
+	now     time.Time
+	latency time.Duration
+	err     error
+	block   chan struct{}
+}
+
+func (d *clockDoer) Do(_ context.Context, _ string, _ ...interface{}) (interface{}, error) {
+	if d.block != nil {
+		<-d.block
+	}
+	d.now = d.now.Add(d.latency)
+	return nil, d.err
+}
+
+// clockPipelineDoer is a clockDoer which sends pipelines in one round trip.
+type clockPipelineDoer struct {
+	clockDoer
+}
+
+func (d *clockPipelineDoer) DoPipeline(_ context.Context, cmds []PipelineCmd) ([]interface{}, error) {
+	d.now = d.now.Add(d.latency)
+	return make([]interface{}, len(cmds)), d.err
+}
+
+func newTestShedder(d *clockDoer, options ...OptionShedder) *Shedder {
+	d.now = time.UnixMilli(0)
+	s := NewShedder(d, options...)
+	s.now = func() time.Time { return d.now }
+	return s
+}
+
+func wantOverloaded(t *testing.T, err error, reason OverloadReason) {
+	t.Helper()
+	var e *OverloadedError
+	if !errors.Is(err, ErrOverloaded) || !errors.As(err, &e) || e.Reason != reason {
+		t.Errorf("Do() error = %v, want %v", err, reason)
+	}
+}
+
+func TestShedder(t *testing.T) {
+	ctx := context.Background()
+	t.Run("rate limit", func(t *testing.T) {
+		d := &clockDoer{}
+		s := newTestShedder(d, ShedderWithReadLimit(10, 2), ShedderWithWriteLimit(1, 1))
+		for i := 0; i < 2; i++ {
+			if _, err := s.Do(ctx, "TS.MRANGE", "-", "+", "FILTER", "env=prod"); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+		}
+		_, err := s.Do(ctx, "TS.MRANGE", "-", "+", "FILTER", "env=prod")
+		wantOverloaded(t, err, OverloadRateLimit)
+		if e := err.(*OverloadedError); e.RetryAfter != 100*time.Millisecond {
+			t.Errorf("RetryAfter = %v, want %v", e.RetryAfter, 100*time.Millisecond)
+		}
+		// the writes have their own bucket
+		if _, err := s.Do(ctx, "TS.ADD", "key:any", "*", 1); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		d.now = d.now.Add(100 * time.Millisecond)
+		if _, err := s.Do(ctx, "TS.MRANGE", "-", "+", "FILTER", "env=prod"); err != nil {
+			t.Errorf("Do() error = %v", err)
+		}
+	})
+	t.Run("pipeline larger than burst", func(t *testing.T) {
+		d := &clockPipelineDoer{}
+		s := NewShedder(d, ShedderWithWriteLimit(10, 2))
+		s.now = func() time.Time { return d.now }
+		cmds := []PipelineCmd{
+			{Name: "TS.ADD", Args: []interface{}{"key:a", "*", 1}},
+			{Name: "TS.ADD", Args: []interface{}{"key:b", "*", 1}},
+			{Name: "TS.ADD", Args: []interface{}{"key:c", "*", 1}},
+		}
+		_, err := s.DoPipeline(ctx, cmds)
+		wantOverloaded(t, err, OverloadBurst)
+		if e, ok := err.(*OverloadedError); ok && e.RetryAfter != 0 {
+			t.Errorf("RetryAfter = %v, want 0", e.RetryAfter)
+		}
+		// the rejected pipeline does not take tokens
+		if _, err := s.DoPipeline(ctx, cmds[:2]); err != nil {
+			t.Errorf("DoPipeline() error = %v", err)
+		}
+	})
+	t.Run("concurrency", func(t *testing.T) {
+		d := &clockDoer{block: make(chan struct{})}
+		s := NewShedder(d, ShedderWithMaxConcurrency(1))
+		errc := make(chan error)
+		go func() {
+			_, err := s.Do(ctx, "TS.GET", "key:any")
+			errc <- err
+		}()
+		for {
+			s.mu.Lock()
+			n := s.inflight
+			s.mu.Unlock()
+			if n == 1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_, err := s.Do(ctx, "TS.GET", "key:any")
+		wantOverloaded(t, err, OverloadConcurrency)
+		close(d.block)
+		if err := <-errc; err != nil {
+			t.Errorf("Do() error = %v", err)
+		}
+	})
+	t.Run("failures", func(t *testing.T) {
+		d := &clockDoer{err: io.EOF}
+		s := newTestShedder(d, ShedderWithBreaker(3, 0, time.Second))
+		for i := 0; i < 3; i++ {
+			if _, err := s.Do(ctx, "TS.GET", "key:any"); err != io.EOF {
+				t.Fatalf("Do() error = %v, want %v", err, io.EOF)
+			}
+		}
+		if got, want := s.BreakerState(), BreakerOpen; got != want {
+			t.Fatalf("BreakerState() = %v, want %v", got, want)
+		}
+		_, err := s.Do(ctx, "TS.GET", "key:any")
+		wantOverloaded(t, err, OverloadCircuitOpen)
+
+		d.now = d.now.Add(time.Second)
+		if got, want := s.BreakerState(), BreakerHalfOpen; got != want {
+			t.Fatalf("BreakerState() = %v, want %v", got, want)
+		}
+		// the probe fails, so the breaker opens again
+		if _, err := s.Do(ctx, "TS.GET", "key:any"); err != io.EOF {
+			t.Fatalf("Do() error = %v, want %v", err, io.EOF)
+		}
+		if got, want := s.BreakerState(), BreakerOpen; got != want {
+			t.Fatalf("BreakerState() = %v, want %v", got, want)
+		}
+		d.now = d.now.Add(time.Second)
+		d.err = nil
+		if _, err := s.Do(ctx, "TS.GET", "key:any"); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if got, want := s.BreakerState(), BreakerClosed; got != want {
+			t.Errorf("BreakerState() = %v, want %v", got, want)
+		}
+	})
+	t.Run("server errors", func(t *testing.T) {
+		d := &clockDoer{err: errors.New("ERR TSDB: the key does not exist")}
+		s := newTestShedder(d, ShedderWithBreaker(1, 0, time.Second))
+		for i := 0; i < 3; i++ {
+			s.Do(ctx, "TS.GET", "key:any")
+		}
+		if got, want := s.BreakerState(), BreakerClosed; got != want {
+			t.Errorf("BreakerState() = %v, want %v", got, want)
+		}
+	})
+	t.Run("latency", func(t *testing.T) {
+		d := &clockDoer{latency: 10 * time.Millisecond}
+		s := newTestShedder(d, ShedderWithBreaker(0, 50*time.Millisecond, time.Second))
+		for i := 0; i < 20; i++ {
+			if _, err := s.Do(ctx, "TS.GET", "key:any"); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+		}
+		d.latency = 200 * time.Millisecond
+		for i := 0; i < 20 && s.BreakerState() == BreakerClosed; i++ {
+			s.Do(ctx, "TS.GET", "key:any")
+		}
+		if got, want := s.BreakerState(), BreakerOpen; got != want {
+			t.Errorf("BreakerState() = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+//go:build go1.21
+
+package redists
+
+import (
+	"context"
+	"log/slog"
+)
+
+// maxLoggedArgs is the number of arguments SlogHook logs, so commands like a
+// large TS.MADD do not produce huge log records.
+const maxLoggedArgs = 64
+
+// SlogHook is a Hook which logs every command with log/slog. Failed commands
+// are logged at error level. Label values are redacted by default.
+type SlogHook struct {
+	l           *slog.Logger
+	level       slog.Level
+	labelValues bool
+}
+
+var _ Hook = (*SlogHook)(nil)
+
+type OptionSlogHook func(h *SlogHook)
+
+// NewSlogHook returns a SlogHook which logs with l. Successful commands are
+// logged at debug level.
+func NewSlogHook(l *slog.Logger, options ...OptionSlogHook) *SlogHook {
+	h := &SlogHook{l: l, level: slog.LevelDebug}
+	for i := range options {
+		options[i](h)
+	}
+	return h
+}
+
+// SlogHookWithLevel sets the level of successful commands.
+func SlogHookWithLevel(level slog.Level) OptionSlogHook {
+	return func(h *SlogHook) {
+		h.level = level
+	}
+}
+
+// SlogHookWithLabelValues disables the redaction of label values.
+func SlogHookWithLabelValues() OptionSlogHook {
+	return func(h *SlogHook) {
+		h.labelValues = true
+	}
+}
+
+func (h *SlogHook) BeforeCmd(ctx context.Context, _ *CmdEvent) context.Context {
+	return ctx
+}
+
+func (h *SlogHook) AfterCmd(ctx context.Context, e *CmdEvent) {
+	level := h.level
+	if e.Err != nil {
+		level = slog.LevelError
+	}
+	if !h.l.Enabled(ctx, level) {
+		return
+	}
+	args := e.Args
+	if !h.labelValues {
+		args = RedactLabels(e.Name, args)
+	}
+	if len(args) > maxLoggedArgs {
+		args = append(args[:maxLoggedArgs:maxLoggedArgs], "...")
+	}
+	attrs := []slog.Attr{
+		slog.String("cmd", e.Name),
+		slog.Any("keys", e.Keys),
+		slog.Any("args", args),
+		slog.Int("arg_count", e.ArgCount),
+		slog.Duration("duration", e.Duration),
+		slog.Int("reply_size", e.ReplySize),
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.Any("err", e.Err))
+	}
+	h.l.LogAttrs(ctx, level, "redists: command", attrs...)
+}
@@ -0,0 +1,43 @@
+//go:build go1.21
+
+package redists
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHook(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	h := NewSlogHook(l)
+	h.AfterCmd(ctx, newCmdEvent("TS.ADD", []interface{}{"key:any", "*", 0.5}))
+	if buf.Len() != 0 {
+		t.Errorf("AfterCmd() logged below the level: %s", buf.String())
+	}
+	e := newCmdEvent("TS.CREATE", []interface{}{"key:any", "LABELS", "env", "prod"})
+	e.Err = errors.New("any error")
+	h.AfterCmd(ctx, e)
+	got := buf.String()
+	for _, s := range []string{"level=ERROR", "cmd=TS.CREATE", "keys=[key:any]", "env [REDACTED]", "arg_count=4", `err="any error"`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("AfterCmd() logged %q, want it to contain %q", got, s)
+		}
+	}
+
+	buf.Reset()
+	h = NewSlogHook(l, SlogHookWithLevel(slog.LevelInfo), SlogHookWithLabelValues())
+	h.AfterCmd(ctx, newCmdEvent("TS.QUERYINDEX", []interface{}{"env=prod"}))
+	got = buf.String()
+	for _, s := range []string{"level=INFO", "cmd=TS.QUERYINDEX", "env=prod"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("AfterCmd() logged %q, want it to contain %q", got, s)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package redists
+
+import "context"
+
+// StreamDoer is implemented by Doers which can read the elements of an array
+// or map reply one by one, e.g. Pool. fn is called for each element of an
+// array reply with a nil key, and for each entry of a map reply with its key.
+// When fn returns an error, DoStream stops and returns it.
+//
+// Client uses it for RangeFunc and MRangeFunc when it has no hooks, so the
+// whole reply is never in memory.
+type StreamDoer interface {
+	DoStream(ctx context.Context, cmd string, args []interface{}, fn func(key, val interface{}) error) error
+}
+
+// stream sends cmd and calls fn for each element of its reply like
+// StreamDoer. With other Doers, the reply is read as a whole, but only the
+// element passed to fn is decoded. The errors of fn are returned as they are.
+func (c *Client) stream(ctx context.Context, cmd Cmd, field string, fn func(key, val interface{}) error) error {
+	var fnErr error
+	each := func(key, val interface{}) error {
+		fnErr = fn(key, val)
+		return fnErr
+	}
+	if sd, ok := c.d.(StreamDoer); ok && len(c.hooks) == 0 {
+		err := sd.DoStream(ctx, cmd.Name(), cmd.Args(), each)
+		if err != nil && fnErr == nil {
+			return newCmdError(cmd.Name(), cmd.Args(), err)
+		}
+		return err
+	}
+	res, err := c.do(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	err = eachElement(field, res, each)
+	if err != nil && fnErr == nil {
+		return wrapError(cmd, err)
+	}
+	return err
+}
+
+// eachElement calls fn for each element of an array reply with a nil key, and
+// for each entry of a map reply with its key in order.
+func eachElement(field string, res interface{}, fn func(key, val interface{}) error) error {
+	if es, ok, err := parseMap(field, res); ok {
+		if err != nil {
+			return err
+		}
+		for i := range es {
+			if err := fn(es[i].key, es[i].val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	is, err := parseArray(field, res)
+	if err != nil {
+		return err
+	}
+	for i := range is {
+		if err := fn(nil, is[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
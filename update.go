@@ -2,11 +2,11 @@ package redists
 
 import (
 	"context"
-	"fmt"
 	"time"
 )
 
-type cmdAlter struct {
+// CmdAlter is the TS.ALTER command.
+type CmdAlter struct {
 	key             string
 	retention       Duration
 	chunkSize       *int
@@ -14,15 +14,24 @@ type cmdAlter struct {
 	labels          map[string]string
 }
 
-func newCmdAlter(key string) *cmdAlter {
-	return &cmdAlter{key: key}
+func newCmdAlter(key string) *CmdAlter {
+	return &CmdAlter{key: key}
 }
 
-func (c *cmdAlter) Name() string {
+// NewCmdAlter returns a TS.ALTER command with the given options applied.
+func NewCmdAlter(key string, options ...OptionAlter) *CmdAlter {
+	cmd := newCmdAlter(key)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdAlter) Name() string {
 	return "TS.ALTER"
 }
 
-func (c *cmdAlter) Args() []interface{} {
+func (c *CmdAlter) Args() []interface{} {
 	args := []interface{}{c.key}
 	if c.retention != nil {
 		args = append(args, optionNameRetention, c.retention.Milliseconds())
@@ -40,60 +49,67 @@ func (c *cmdAlter) Args() []interface{} {
 	return args
 }
 
-type OptionAlter func(cmd *cmdAlter)
+type OptionAlter func(cmd *CmdAlter)
 
 // Alter updates the retention, labels of an existing key.
 func (c *Client) Alter(ctx context.Context, key string, options ...OptionAlter) error {
-	cmd := newCmdAlter(key)
-	for i := range options {
-		options[i](cmd)
-	}
-	_, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdAlter(key, options...)
+	_, err := c.do(ctx, cmd)
 	return err
 }
 
 func AlterWithRetention(r Duration) OptionAlter {
-	return func(cmd *cmdAlter) {
+	return func(cmd *CmdAlter) {
 		cmd.retention = r
 	}
 }
 
 func AlterWithChunkSize(cs int) OptionAlter {
-	return func(cmd *cmdAlter) {
+	return func(cmd *CmdAlter) {
 		cmd.chunkSize = &cs
 	}
 }
 
 func AlterWithDuplicatePolicy(dp DuplicatePolicy) OptionAlter {
-	return func(cmd *cmdAlter) {
+	return func(cmd *CmdAlter) {
 		cmd.duplicatePolicy = &dp
 	}
 }
 
 func AlterWithLabels(ls Labels) OptionAlter {
-	return func(cmd *cmdAlter) {
+	return func(cmd *CmdAlter) {
 		cmd.labels = ls
 	}
 }
 
-type cmdAdd struct {
+// CmdAdd is the TS.ADD command.
+type CmdAdd struct {
 	sample          Sample
 	retention       Duration
 	encoding        *Encoding
 	chunkSize       *int
 	duplicatePolicy *DuplicatePolicy
-	labels          map[string]string
+	labels          *LabelSet
 }
 
-func newCmdAdd(s Sample) *cmdAdd {
-	return &cmdAdd{sample: s}
+func newCmdAdd(s Sample) *CmdAdd {
+	return &CmdAdd{sample: s}
 }
 
-func (c *cmdAdd) Name() string {
+// NewCmdAdd returns a TS.ADD command with the given options applied.
+func NewCmdAdd(s Sample, options ...OptionAdd) *CmdAdd {
+	cmd := newCmdAdd(s)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdAdd) Name() string {
 	return "TS.ADD"
 }
 
-func (c *cmdAdd) Args() []interface{} {
+func (c *CmdAdd) Args() []interface{} {
 	args := []interface{}{c.sample.Key, timestampArg(c.sample.Timestamp), c.sample.Value}
 	if c.retention != nil {
 		args = append(args, optionNameRetention, c.retention.Milliseconds())
@@ -109,76 +125,123 @@ func (c *cmdAdd) Args() []interface{} {
 	}
 	if c.labels != nil {
 		args = append(args, optionNameLabels)
-		args = append(args, encodeLabels(c.labels)...)
+		args = append(args, c.labels.args...)
 	}
 	return args
 }
 
-type OptionAdd func(cmd *cmdAdd)
+func (c *CmdAdd) AppendArgs(a *Appender) {
+	a.AppendString(c.sample.Key)
+	a.AppendTimestamp(c.sample.Timestamp)
+	a.AppendFloat(c.sample.Value)
+	if c.retention != nil {
+		a.AppendString(optionNameRetention)
+		a.AppendInt(c.retention.Milliseconds())
+	}
+	if c.encoding != nil {
+		a.AppendString(optionNameEncoding)
+		a.AppendString(string(*c.encoding))
+	}
+	if c.chunkSize != nil {
+		a.AppendString(optionNameChunkSize)
+		a.AppendInt(int64(*c.chunkSize))
+	}
+	if c.duplicatePolicy != nil {
+		a.AppendString(optionNameOnDuplicate)
+		a.AppendString(string(*c.duplicatePolicy))
+	}
+	if c.labels != nil {
+		a.AppendString(optionNameLabels)
+		c.labels.appendTo(a)
+	}
+}
+
+type OptionAdd func(cmd *CmdAdd)
 
 // Add updates the retention, labels of an existing key.
 func (c *Client) Add(ctx context.Context, s Sample, options ...OptionAdd) (time.Time, error) {
-	cmd := newCmdAdd(s)
-	for i := range options {
-		options[i](cmd)
-	}
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdAdd(s, options...)
+	res, err := c.do(ctx, cmd)
 	if err != nil {
 		return time.Time{}, err
 	}
-	return time.UnixMilli(res.(int64)), err
+	t, err := parseTime(res)
+	return t, wrapError(cmd, err)
 }
 
 func AddWithRetention(r Duration) OptionAdd {
-	return func(cmd *cmdAdd) {
+	return func(cmd *CmdAdd) {
 		cmd.retention = r
 	}
 }
 
 func AddWithEncoding(e Encoding) OptionAdd {
-	return func(cmd *cmdAdd) {
+	return func(cmd *CmdAdd) {
 		cmd.encoding = &e
 	}
 }
 
 func AddWithChunkSize(cs int) OptionAdd {
-	return func(cmd *cmdAdd) {
+	return func(cmd *CmdAdd) {
 		cmd.chunkSize = &cs
 	}
 }
 
 func AddWithOnDuplicate(dp DuplicatePolicy) OptionAdd {
-	return func(cmd *cmdAdd) {
+	return func(cmd *CmdAdd) {
 		cmd.duplicatePolicy = &dp
 	}
 }
 
+// AddWithLabels sets the labels of the series when it is created. The labels
+// are encoded when the option is created, so reusing the option avoids
+// encoding them for every sample.
 func AddWithLabels(ls Labels) OptionAdd {
-	return func(cmd *cmdAdd) {
+	return AddWithLabelSet(NewLabelSet(ls))
+}
+
+// AddWithLabelSet is like AddWithLabels, with labels which are already
+// encoded.
+func AddWithLabelSet(ls *LabelSet) OptionAdd {
+	return func(cmd *CmdAdd) {
 		cmd.labels = ls
 	}
 }
 
-type cmdMAdd struct {
+// CmdMAdd is the TS.MADD command.
+type CmdMAdd struct {
 	samples []Sample
 }
 
-func newCmdMAdd(s []Sample) *cmdMAdd {
-	return &cmdMAdd{samples: s}
+func newCmdMAdd(s []Sample) *CmdMAdd {
+	return &CmdMAdd{samples: s}
+}
+
+// NewCmdMAdd returns a TS.MADD command.
+func NewCmdMAdd(s []Sample) *CmdMAdd {
+	return newCmdMAdd(s)
 }
 
-func (c *cmdMAdd) Name() string {
+func (c *CmdMAdd) Name() string {
 	return "TS.MADD"
 }
 
-func (c *cmdMAdd) Args() []interface{} {
-	var args []interface{}
+func (c *CmdMAdd) Args() []interface{} {
+	args := make([]interface{}, 0, 3*len(c.samples))
 	for _, s := range c.samples {
 		args = append(args, s.Key, timestampArg(s.Timestamp), s.Value)
 	}
 	return args
 }
 
+func (c *CmdMAdd) AppendArgs(a *Appender) {
+	for _, s := range c.samples {
+		a.AppendString(s.Key)
+		a.AppendTimestamp(s.Timestamp)
+		a.AppendFloat(s.Value)
+	}
+}
+
 // MultiResult contains an error when a specific Sample triggers an error.
 type MultiResult struct {
 	t   time.Time
@@ -195,25 +258,61 @@ func (r MultiResult) Err() error {
 
 // MAdd appends new samples to a list of series.
 func (c *Client) MAdd(ctx context.Context, s []Sample) ([]MultiResult, error) {
-	cmd := newCmdMAdd(s)
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+	cmd := NewCmdMAdd(s)
+	_, cluster := c.d.(ClusterDoer)
+	if _, split := c.d.(mAddDoer); cluster && !split && len(s) > 0 {
+		return c.clusterMAdd(ctx, cmd)
+	}
+	res, err := c.do(ctx, cmd)
 	if err != nil {
 		return nil, err
 	}
-	var rs []MultiResult
-	if is, ok := res.([]interface{}); ok {
-		for i := range is {
-			switch v := is[i].(type) {
-			case error:
-				rs = append(rs, MultiResult{err: v})
-			case int64:
-				rs = append(rs, MultiResult{t: time.UnixMilli(v)})
-			default:
-				panic(fmt.Sprintf("val %T not convertible to MultiResult", v))
-			}
+	rs, err := parseMultiResults(res)
+	if err != nil {
+		return nil, wrapError(cmd, err)
+	}
+	wrapMultiResults(cmd, rs)
+	return rs, nil
+}
+
+// wrapMultiResults wraps the error of each failed sample in a CmdError with
+// the key of the sample. The arguments of cmd are only built when a sample
+// failed.
+func wrapMultiResults(cmd Cmd, rs []MultiResult) {
+	var name string
+	var args []interface{}
+	for i := range rs {
+		if rs[i].err == nil {
+			continue
+		}
+		if args == nil {
+			name, args = cmd.Name(), cmd.Args()
+		}
+		var sargs []interface{}
+		if 3*i < len(args) {
+			sargs = args[3*i:]
+		}
+		rs[i].err = newCmdError(name, sargs, rs[i].err)
+	}
+}
+
+func parseMultiResults(res interface{}) ([]MultiResult, error) {
+	is, err := parseArray("MultiResult", res)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]MultiResult, 0, len(is))
+	for i := range is {
+		switch v := is[i].(type) {
+		case error:
+			rs = append(rs, MultiResult{err: v})
+		case int64:
+			rs = append(rs, MultiResult{t: time.UnixMilli(v)})
+		default:
+			return nil, newDecodeError("MultiResult", v)
 		}
 	}
-	return rs, err
+	return rs, nil
 }
 
 const (
@@ -223,7 +322,8 @@ const (
 
 type nameCounter string
 
-type cmdCounter struct {
+// CmdCounter is the TS.INCRBY or TS.DECRBY command.
+type CmdCounter struct {
 	name      nameCounter
 	key       string
 	value     float64
@@ -231,18 +331,36 @@ type cmdCounter struct {
 	retention Duration
 	encoding  *Encoding
 	chunkSize *int
-	labels    map[string]string
+	labels    *LabelSet
 }
 
-func newCmdCounter(name nameCounter, key string, value float64) *cmdCounter {
-	return &cmdCounter{name: name, key: key, value: value}
+func newCmdCounter(name nameCounter, key string, value float64) *CmdCounter {
+	return &CmdCounter{name: name, key: key, value: value}
 }
 
-func (c *cmdCounter) Name() string {
+// NewCmdIncrBy returns a TS.INCRBY command with the given options applied.
+func NewCmdIncrBy(key string, value float64, options ...OptionCounter) *CmdCounter {
+	cmd := newCmdCounter(nameIncrBy, key, value)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+// NewCmdDecrBy returns a TS.DECRBY command with the given options applied.
+func NewCmdDecrBy(key string, value float64, options ...OptionCounter) *CmdCounter {
+	cmd := newCmdCounter(nameDecrBy, key, value)
+	for i := range options {
+		options[i](cmd)
+	}
+	return cmd
+}
+
+func (c *CmdCounter) Name() string {
 	return string(c.name)
 }
 
-func (c *cmdCounter) Args() []interface{} {
+func (c *CmdCounter) Args() []interface{} {
 	args := []interface{}{c.key, c.value}
 	if c.timestamp != nil {
 		args = append(args, optionNameTimestamp, c.timestamp.UnixMilli())
@@ -258,61 +376,98 @@ func (c *cmdCounter) Args() []interface{} {
 	}
 	if c.labels != nil {
 		args = append(args, optionNameLabels)
-		args = append(args, encodeLabels(c.labels)...)
+		args = append(args, c.labels.args...)
 	}
 	return args
 }
 
-type OptionCounter func(cmd *cmdCounter)
+func (c *CmdCounter) AppendArgs(a *Appender) {
+	a.AppendString(c.key)
+	a.AppendFloat(c.value)
+	if c.timestamp != nil {
+		a.AppendString(optionNameTimestamp)
+		a.AppendInt(c.timestamp.UnixMilli())
+	}
+	if c.retention != nil {
+		a.AppendString(optionNameRetention)
+		a.AppendInt(c.retention.Milliseconds())
+	}
+	if c.encoding != nil && *c.encoding == EncodingUncompressed {
+		a.AppendString(optionNameUncompressed)
+	}
+	if c.chunkSize != nil {
+		a.AppendString(optionNameChunkSize)
+		a.AppendInt(int64(*c.chunkSize))
+	}
+	if c.labels != nil {
+		a.AppendString(optionNameLabels)
+		c.labels.appendTo(a)
+	}
+}
+
+type OptionCounter func(cmd *CmdCounter)
 
 // IncrBy creates a new sample that increments the latest sample's value.
 func (c *Client) IncrBy(ctx context.Context, key string, value float64, options ...OptionCounter) (time.Time, error) {
-	return c.counter(ctx, nameIncrBy, key, value, options...)
+	return c.counter(ctx, NewCmdIncrBy(key, value, options...))
 }
 
 // DecrBy creates a new sample that decrements the latest sample's value.
 func (c *Client) DecrBy(ctx context.Context, key string, value float64, options ...OptionCounter) (time.Time, error) {
-	return c.counter(ctx, nameDecrBy, key, value, options...)
+	return c.counter(ctx, NewCmdDecrBy(key, value, options...))
 }
 
-func (c *Client) counter(ctx context.Context, name nameCounter, key string, value float64, options ...OptionCounter) (time.Time, error) {
-	cmd := newCmdCounter(name, key, value)
-	for i := range options {
-		options[i](cmd)
-	}
-	res, err := c.d.Do(ctx, cmd.Name(), cmd.Args()...)
+func (c *Client) counter(ctx context.Context, cmd *CmdCounter) (time.Time, error) {
+	res, err := c.do(ctx, cmd)
 	if err != nil {
 		return time.Time{}, err
 	}
-	return time.UnixMilli(res.(int64)), err
+	t, err := parseTime(res)
+	return t, wrapError(cmd, err)
 }
 
 func CounterWithRetention(r Duration) OptionCounter {
-	return func(cmd *cmdCounter) {
+	return func(cmd *CmdCounter) {
 		cmd.retention = r
 	}
 }
 
 func CounterWithTimestamp(t time.Time) OptionCounter {
-	return func(cmd *cmdCounter) {
+	return func(cmd *CmdCounter) {
 		cmd.timestamp = &t
 	}
 }
 
 func CounterWithEncoding(e Encoding) OptionCounter {
-	return func(cmd *cmdCounter) {
+	return func(cmd *CmdCounter) {
 		cmd.encoding = &e
 	}
 }
 
 func CounterWithChunkSize(cs int) OptionCounter {
-	return func(cmd *cmdCounter) {
+	return func(cmd *CmdCounter) {
 		cmd.chunkSize = &cs
 	}
 }
 
+// CounterWithLabels sets the labels of the series when it is created. The
+// labels are encoded when the option is created.
 func CounterWithLabels(ls Labels) OptionCounter {
-	return func(cmd *cmdCounter) {
+	return CounterWithLabelSet(NewLabelSet(ls))
+}
+
+// CounterWithLabelSet is like CounterWithLabels, with labels which are
+// already encoded.
+func CounterWithLabelSet(ls *LabelSet) OptionCounter {
+	return func(cmd *CmdCounter) {
 		cmd.labels = ls
 	}
 }
+
+func parseTime(res interface{}) (time.Time, error) {
+	ts, err := parseInt64("Timestamp", res)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ts), nil
+}
@@ -187,6 +187,13 @@ func TestCmdAdd(t *testing.T) {
 			t.Errorf("Args() = %v, want %v", got, want)
 		}
 	})
+	t.Run("nil labels", func(t *testing.T) {
+		cmd := newCmdAdd(NewSample("key:any", time.UnixMilli(1001), 0.5))
+		AddWithLabels(nil)(cmd)
+		if got, want := cmd.Args(), []interface{}{"key:any", int64(1001), 0.5}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Args() = %v, want %v", got, want)
+		}
+	})
 	t.Run("args order", func(t *testing.T) {
 		cmd := newCmdAdd(NewSample("key:any", time.UnixMilli(1001), 0.5))
 		want := []interface{}{
@@ -342,6 +349,13 @@ func TestCmdCounter(t *testing.T) {
 			t.Errorf("Args() = %v, want %v", got, want)
 		}
 	})
+	t.Run("nil labels", func(t *testing.T) {
+		cmd := newCmdCounter(nameIncrBy, "key:any", 0.5)
+		CounterWithLabels(nil)(cmd)
+		if got, want := cmd.Args(), []interface{}{"key:any", 0.5}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Args() = %v, want %v", got, want)
+		}
+	})
 	t.Run("timestamp", func(t *testing.T) {
 		cmd := newCmdCounter(nameIncrBy, "key:any", 0.5)
 		CounterWithTimestamp(time.UnixMilli(1001))(cmd)